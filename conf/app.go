@@ -30,6 +30,98 @@ type Config struct {
 		MaxSize  int64    `mapstructure:"max_size"`
 		AllowExt []string `mapstructure:"allow_ext"`
 	} `mapstructure:"upload"`
+	Order struct {
+		CheckinEarlyMinutes     int    `mapstructure:"checkin_early_minutes"`     // 最早可提前签到的分钟数
+		CheckinLateMinutes      int    `mapstructure:"checkin_late_minutes"`      // 最晚可延后签到的分钟数
+		CheckinMaxSkewSeconds   int    `mapstructure:"checkin_max_skew_seconds"`  // 客户端时间与服务器时间允许的最大偏差（秒）
+		CheckinMaxAttempts      int    `mapstructure:"checkin_max_attempts"`      // 签到码连续错误次数上限，达到后锁定签到（0表示不限）
+		CheckinLockMinutes      int    `mapstructure:"checkin_lock_minutes"`      // 签到码错误次数过多时的锁定时长（分钟）
+		AutoSettleHours         int    `mapstructure:"auto_settle_hours"`         // 待结算订单超过该小时数未确认则自动确认
+		ApproveTimeoutMinutes   int    `mapstructure:"approve_timeout_minutes"`   // 陪诊师接单后患者确认的时限（分钟）
+		ApproveTimeoutAction    string `mapstructure:"approve_timeout_action"`    // 患者超时未确认的处理：approve-自动确认，release-退回订单大厅
+		DesignateTimeoutMinutes int    `mapstructure:"designate_timeout_minutes"` // 指定陪诊师的需求，陪诊师处理时限（分钟）
+	} `mapstructure:"order"`
+	Job struct {
+		AutoSettleInterval       int `mapstructure:"auto_settle_interval"`       // 订单自动结算扫描间隔（秒）
+		DemandExpireInterval     int `mapstructure:"demand_expire_interval"`     // 过期需求关闭扫描间隔（秒）
+		ApproveTimeoutInterval   int `mapstructure:"approve_timeout_interval"`   // 接单确认超时扫描间隔（秒）
+		DesignateReleaseInterval int `mapstructure:"designate_release_interval"` // 指定需求超时公开扫描间隔（秒）
+		IdempotencyCleanInterval int `mapstructure:"idempotency_clean_interval"` // 过期幂等记录清理间隔（秒）
+		IncomeReleaseInterval    int `mapstructure:"income_release_interval"`    // 冻结收入到期解冻扫描间隔（秒）
+		PayTimeoutInterval       int `mapstructure:"pay_timeout_interval"`       // 待支付订单超时取消扫描间隔（秒）
+		RefundRetryInterval      int `mapstructure:"refund_retry_interval"`      // 退款重试扫描间隔（秒）
+		ReconcileInterval        int `mapstructure:"reconcile_interval"`         // 陪诊师余额对账间隔（秒）
+	} `mapstructure:"job"`
+	Idempotency struct {
+		TtlMinutes int `mapstructure:"ttl_minutes"` // 幂等记录有效期（分钟），有效期内相同 Idempotency-Key 的请求重放首次响应
+	} `mapstructure:"idempotency"`
+	CancelPolicy struct {
+		Patient   []CancelRule `mapstructure:"patient"`   // 患者取消待服务订单的违约规则
+		Companion []CancelRule `mapstructure:"companion"` // 陪诊师取消待服务订单的违约规则
+	} `mapstructure:"cancel_policy"`
+	Withdraw struct {
+		MinAmount        float64 `mapstructure:"min_amount"`         // 单笔最低提现金额（元，0表示不限）
+		MaxAmount        float64 `mapstructure:"max_amount"`         // 单笔最高提现金额（元，0表示不限）
+		DailyCount       int     `mapstructure:"daily_count"`        // 每日最多提现笔数（0表示不限）
+		DailyAmount      float64 `mapstructure:"daily_amount"`       // 每日累计提现金额上限（元，0表示不限）
+		MonthlyCount     int     `mapstructure:"monthly_count"`      // 每月最多提现笔数（0表示不限）
+		MonthlyAmount    float64 `mapstructure:"monthly_amount"`     // 每月累计提现金额上限（元，0表示不限）
+		FeeAmount        float64 `mapstructure:"fee_amount"`         // 固定手续费（元/笔），与比例手续费累加
+		FeeRate          float64 `mapstructure:"fee_rate"`           // 比例手续费（按提现金额计算，0~1）
+		IncomeFreezeDays int     `mapstructure:"income_freeze_days"` // 服务收入冻结天数（T+N，0表示结算后立即可提现）
+	} `mapstructure:"withdraw"`
+	Payment struct {
+		Provider       string `mapstructure:"provider"`        // 启用的支付渠道（必须配置；mock-模拟渠道，仅用于本地开发，需同时开启 mock.enabled）
+		NotifyUrl      string `mapstructure:"notify_url"`      // 支付结果回调地址前缀（实际地址为 前缀 + 渠道名称）
+		TimeoutMinutes int    `mapstructure:"timeout_minutes"` // 订单进入待支付后的支付时限（分钟），逾期自动取消
+		Mock           struct {
+			Enabled  bool   `mapstructure:"enabled"`   // 是否允许使用模拟渠道（仅限本地开发，默认关闭）
+			Secret   string `mapstructure:"secret"`    // 模拟渠道回调签名密钥
+			AutoPaid bool   `mapstructure:"auto_paid"` // 主动查询时直接视为支付成功
+		} `mapstructure:"mock"`
+	} `mapstructure:"payment"`
+	Refund struct {
+		MaxAttempts      int `mapstructure:"max_attempts"`       // 渠道退款最大调用次数，超过后标记为退款失败待人工处理
+		RetryBaseSeconds int `mapstructure:"retry_base_seconds"` // 首次重试间隔（秒），之后每次翻倍，最长一天
+	} `mapstructure:"refund"`
+	Payout struct {
+		ExportHeader  bool           `mapstructure:"export_header"`  // 打款文件是否输出表头行
+		ExportColumns []PayoutColumn `mapstructure:"export_columns"` // 打款文件列布局（按顺序输出）
+		Result        struct {
+			SerialNoColumn string   `mapstructure:"serial_no_column"` // 结果文件中提现编号所在列的表头
+			StatusColumn   string   `mapstructure:"status_column"`    // 结果文件中处理结果所在列的表头
+			ReasonColumn   string   `mapstructure:"reason_column"`    // 结果文件中失败原因所在列的表头（可选）
+			AmountColumn   string   `mapstructure:"amount_column"`    // 结果文件中打款金额所在列的表头（可选，配置后与实际到账金额核对）
+			SuccessValues  []string `mapstructure:"success_values"`   // 表示打款成功的处理结果取值
+			FailValues     []string `mapstructure:"fail_values"`      // 表示打款失败的处理结果取值（其他取值视为银行处理中，暂不处理）
+		} `mapstructure:"result"`
+	} `mapstructure:"payout"`
+	Reconcile struct {
+		AutoFix bool `mapstructure:"auto_fix"` // 定时对账发现不一致时是否自动修正（默认仅报告，修正方式见 ReconciliationService）
+	} `mapstructure:"reconcile"`
+	Tip struct {
+		MinAmount float64 `mapstructure:"min_amount"` // 单笔最低打赏金额（元）
+		MaxAmount float64 `mapstructure:"max_amount"` // 单笔最高打赏金额（元，0表示不限）
+	} `mapstructure:"tip"`
+	Statement struct {
+		Secret string `mapstructure:"secret"` // 对账单校验码密钥（修改后历史对账单的校验码将无法核验）
+	} `mapstructure:"statement"`
+}
+
+// PayoutColumn 银行批量转账文件的一列
+// Field 取值：serial_no-提现编号，account-收款账号，real_name-收款人姓名，amount-打款金额（实际到账金额），companion_id-陪诊师ID，fixed-固定值（取 Value）
+type PayoutColumn struct {
+	Header string `mapstructure:"header"` // 表头
+	Field  string `mapstructure:"field"`  // 取值字段
+	Value  string `mapstructure:"value"`  // 固定值（Field 为 fixed 时使用）
+}
+
+// CancelRule 取消违约规则：距服务时间不足 WithinHours 小时取消时适用；多条规则命中时取时间窗口最小的一条
+type CancelRule struct {
+	WithinHours   float64 `mapstructure:"within_hours"`   // 时间窗口（距服务时间的小时数）
+	PenaltyRate   float64 `mapstructure:"penalty_rate"`   // 违约金比例（按订单金额计算，0~1）
+	PenaltyAmount float64 `mapstructure:"penalty_amount"` // 固定违约金（元），与比例违约金累加
+	CreditDeduct  int     `mapstructure:"credit_deduct"`  // 扣减信用分（仅对陪诊师生效）
 }
 
 // LoadConfig 加载配置文件
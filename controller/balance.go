@@ -10,7 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// BalanceController 余额控制器（仅陪诊师访问）
+// BalanceController 余额控制器（余额与提现申请仅陪诊师访问，提现审核仅管理员访问）
 type BalanceController struct{}
 
 // GetBalance 查询当前陪诊师账户余额
@@ -36,10 +36,8 @@ func (b *BalanceController) GetBalance(c *gin.Context) {
 		return
 	}
 
-	// 4. 返回余额信息
-	utils.Success(c, gin.H{
-		"balance": balance, // 保留两位小数的余额
-	})
+	// 4. 返回余额信息（balance-可提现余额，frozen_balance-冻结期内的收入）
+	utils.Success(c, balance)
 }
 
 // GetBalanceRecordList 查询余额明细列表（带分页、类型筛选）
@@ -61,11 +59,11 @@ func (b *BalanceController) GetBalanceRecordList(c *gin.Context) {
 	// 3. 接收分页参数与类型筛选
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
-	recordTypeStr := c.DefaultQuery("type", "") // 筛选类型：1-收入，2-提现成功，3-提现失败
+	recordTypeStr := c.DefaultQuery("type", "") // 筛选类型：1-收入，2-提现成功，3-提现失败，4-提现中，5-取消违约金，6-取消补偿，8-期初余额，9-打赏，10-余额调整
 	var recordType int
 	if recordTypeStr != "" {
 		t, err := strconv.Atoi(recordTypeStr)
-		if err == nil && (t == 1 || t == 2 || t == 3) {
+		if err == nil && t >= 1 && t <= 10 {
 			recordType = t
 		}
 	}
@@ -109,9 +107,9 @@ func (b *BalanceController) ApplyWithdraw(c *gin.Context) {
 
 	// 3. 接收提现参数
 	var req struct {
-		Amount   float64 `json:"amount" binding:"required,gt=0"` // 提现金额（大于0）
-		Account  string  `json:"account" binding:"required"`     // 提现账户（如微信/支付宝账号）
-		RealName string  `json:"real_name" binding:"required"`   // 真实姓名（与账户一致）
+		Amount   utils.Money `json:"amount" binding:"required,gt=0"` // 提现金额（大于0）
+		Account  string      `json:"account" binding:"required"`     // 提现账户（如微信/支付宝账号）
+		RealName string      `json:"real_name" binding:"required"`   // 真实姓名（与账户一致）
 	}
 
 	// 4. 参数绑定与校验
@@ -121,7 +119,7 @@ func (b *BalanceController) ApplyWithdraw(c *gin.Context) {
 	}
 
 	// 5. 调用服务层申请提现
-	serialNo, err := (&service.BalanceService{}).ApplyWithdraw(
+	withdrawal, err := (&service.BalanceService{}).ApplyWithdraw(
 		companionId.(uint64),
 		req.Amount,
 		req.Account,
@@ -132,9 +130,100 @@ func (b *BalanceController) ApplyWithdraw(c *gin.Context) {
 		return
 	}
 
-	// 6. 返回提现明细编号
+	// 6. 返回提现单编号与手续费
+	utils.Success(c, gin.H{
+		"serial_no":     withdrawal.SerialNo,     // 提现单编号（用于查询提现状态）
+		"fee":           withdrawal.Fee,          // 手续费
+		"actual_amount": withdrawal.ActualAmount, // 实际到账金额
+		"msg":           "提现申请已提交，等待审核处理",
+	})
+}
+
+// GetWithdrawList 管理员查询提现单列表（status：2-提现成功，3-提现失败，4-提现中，不传查询全部）
+func (b *BalanceController) GetWithdrawList(c *gin.Context) {
+	// 1. 接收分页参数与状态筛选
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	status, err := strconv.Atoi(c.DefaultQuery("status", "0"))
+	if err != nil {
+		utils.Fail(c, "参数格式错误：status无效")
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 10
+	}
+
+	// 2. 调用服务层查询
+	withdrawList, total, err := (&service.BalanceService{}).GetWithdrawList(status, page, size)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	// 返回分页结果
 	utils.Success(c, gin.H{
-		"serial_no": serialNo, // 提现明细编号（用于查询提现状态）
-		"msg":       "提现申请已提交，等待审核处理",
+		"list":  withdrawList,
+		"total": total,
+		"page":  page,
+		"size":  size,
 	})
 }
+
+// ApproveWithdraw 管理员审核通过提现（审核通过后导出批量打款，按银行结果确认打款成功或失败）
+func (b *BalanceController) ApproveWithdraw(c *gin.Context) {
+	// 1. 获取当前管理员ID
+	adminId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收审核参数
+	var req struct {
+		SerialNo string `json:"serial_no" binding:"required"` // 提现编号
+		Remark   string `json:"remark" binding:"max=255"`     // 审核说明（可选）
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	if err := (&service.BalanceService{}).ApproveWithdraw(req.SerialNo, adminId.(uint64), req.Remark); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
+
+// RejectWithdraw 管理员驳回提现（金额退回陪诊师余额）
+func (b *BalanceController) RejectWithdraw(c *gin.Context) {
+	// 1. 获取当前管理员ID
+	adminId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收驳回参数
+	var req struct {
+		SerialNo string `json:"serial_no" binding:"required"`      // 提现编号
+		Reason   string `json:"reason" binding:"required,max=255"` // 驳回原因
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	if err := (&service.BalanceService{}).RejectWithdraw(req.SerialNo, adminId.(uint64), req.Reason); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
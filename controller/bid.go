@@ -0,0 +1,165 @@
+// controller/bid.go
+package controller
+
+import (
+	"strconv"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BidController 竞价控制器
+type BidController struct{}
+
+// -------------------------- 陪诊师专属接口 --------------------------
+
+// Submit 提交报价（同一需求重复提交时更新报价，仅陪诊师访问）
+func (b *BidController) Submit(c *gin.Context) {
+	// 1. 获取当前陪诊师ID
+	companionId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收报价参数
+	var req struct {
+		DemandId uint64      `json:"demand_id" binding:"required,gt=0"` // 需求ID
+		Price    utils.Money `json:"price" binding:"required,gt=0"`     // 报价金额
+		Message  string      `json:"message" binding:"max=255"`         // 报价留言（可选）
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	bidId, err := (&service.BidService{}).SubmitBid(req.DemandId, companionId.(uint64), req.Price, req.Message)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"bid_id": bidId,
+		"msg":    "报价已提交，等待患者选择",
+	})
+}
+
+// Withdraw 撤回报价（仅陪诊师访问）
+func (b *BidController) Withdraw(c *gin.Context) {
+	// 1. 获取当前陪诊师ID
+	companionId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收报价ID
+	var req struct {
+		BidId uint64 `json:"bid_id" binding:"required,gt=0"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	if err := (&service.BidService{}).WithdrawBid(req.BidId, companionId.(uint64)); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "报价已撤回")
+}
+
+// GetMyBidList 查询我的报价列表（仅陪诊师访问）
+func (b *BidController) GetMyBidList(c *gin.Context) {
+	// 1. 获取当前陪诊师ID
+	companionId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收分页参数
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	// 3. 调用服务层查询
+	bidList, total, err := (&service.BidService{}).GetCompanionBidList(companionId.(uint64), page, size)
+	if err != nil {
+		utils.Fail(c, "查询报价列表失败："+err.Error())
+		return
+	}
+
+	// 返回分页结果
+	utils.Success(c, gin.H{
+		"list":  bidList,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// -------------------------- 患者专属接口 --------------------------
+
+// GetDemandBidList 查询需求收到的报价（附带陪诊师评分，仅患者访问）
+func (b *BidController) GetDemandBidList(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收需求ID
+	demandId, err := strconv.ParseUint(c.Query("demand_id"), 10, 64)
+	if err != nil || demandId == 0 {
+		utils.Fail(c, "参数格式错误：demand_id无效")
+		return
+	}
+
+	// 3. 调用服务层查询
+	bidList, err := (&service.BidService{}).GetDemandBidList(demandId, patientId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": bidList,
+	})
+}
+
+// Accept 选定报价（按报价金额生成订单，仅患者访问）
+func (b *BidController) Accept(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收报价ID
+	var req struct {
+		BidId uint64 `json:"bid_id" binding:"required,gt=0"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	if err := (&service.BidService{}).AcceptBid(req.BidId, patientId.(uint64), c.ClientIP()); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "已选定陪诊师，订单已生成")
+}
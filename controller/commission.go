@@ -0,0 +1,163 @@
+// controller/commission.go
+package controller
+
+import (
+	"strconv"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CommissionController 佣金规则控制器（仅管理员访问）
+type CommissionController struct{}
+
+// commissionRuleReq 新增/修改佣金规则的请求参数（条件为空或0表示不限）
+type commissionRuleReq struct {
+	Name         string      `json:"name" binding:"required,max=32"` // 规则名称
+	City         string      `json:"city" binding:"max=32"`          // 城市（医院地址包含该城市名称时命中）
+	Hospital     string      `json:"hospital" binding:"max=100"`     // 医院（与需求就诊医院一致时命中）
+	MinScore     float64     `json:"min_score"`                      // 陪诊师最低平均评分
+	MinEvalCount int         `json:"min_eval_count"`                 // 陪诊师最少评价数
+	MinAmount    utils.Money `json:"min_amount"`                     // 订单金额下限（含）
+	MaxAmount    utils.Money `json:"max_amount"`                     // 订单金额上限（不含）
+	StartAt      string      `json:"start_at"`                       // 生效开始时间（格式：2006-01-02 15:04:05）
+	EndAt        string      `json:"end_at"`                         // 生效结束时间（格式：2006-01-02 15:04:05）
+	Rate         float64     `json:"rate" binding:"gte=0,lte=1"`     // 佣金比例（0~1）
+	Priority     int         `json:"priority"`                       // 优先级（数值越大越优先）
+}
+
+// toInput 转换为服务层参数
+func (r *commissionRuleReq) toInput() service.CommissionRuleInput {
+	return service.CommissionRuleInput{
+		Name:         r.Name,
+		City:         r.City,
+		Hospital:     r.Hospital,
+		MinScore:     r.MinScore,
+		MinEvalCount: r.MinEvalCount,
+		MinAmount:    r.MinAmount,
+		MaxAmount:    r.MaxAmount,
+		StartAt:      r.StartAt,
+		EndAt:        r.EndAt,
+		Rate:         r.Rate,
+		Priority:     r.Priority,
+	}
+}
+
+// GetList 管理员查询佣金规则列表（status：0-停用，1-启用，不传查询全部）
+func (cc *CommissionController) GetList(c *gin.Context) {
+	// 1. 接收分页参数与状态筛选
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	status, err := strconv.Atoi(c.DefaultQuery("status", "-1"))
+	if err != nil {
+		utils.Fail(c, "参数格式错误：status无效")
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 10
+	}
+
+	// 2. 调用服务层查询
+	ruleList, total, err := (&service.CommissionService{}).GetRuleList(status, page, size)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	// 返回分页结果
+	utils.Success(c, gin.H{
+		"list":  ruleList,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// Create 管理员新增佣金规则
+func (cc *CommissionController) Create(c *gin.Context) {
+	// 1. 获取当前管理员ID
+	adminId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收规则参数
+	var req commissionRuleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	ruleId, err := (&service.CommissionService{}).CreateRule(req.toInput(), adminId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"rule_id": ruleId,
+	})
+}
+
+// Update 管理员修改佣金规则（已生成的订单不受影响）
+func (cc *CommissionController) Update(c *gin.Context) {
+	// 1. 获取当前管理员ID
+	adminId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收规则参数
+	var req struct {
+		RuleId uint64 `json:"rule_id" binding:"required,gt=0"`
+		commissionRuleReq
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	if err := (&service.CommissionService{}).UpdateRule(req.RuleId, req.toInput(), adminId.(uint64)); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
+
+// UpdateStatus 管理员启用/停用佣金规则（status：0-停用，1-启用）
+func (cc *CommissionController) UpdateStatus(c *gin.Context) {
+	// 1. 获取当前管理员ID
+	adminId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收规则ID与状态
+	var req struct {
+		RuleId uint64 `json:"rule_id" binding:"required,gt=0"`
+		Status *int   `json:"status" binding:"required,oneof=0 1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	if err := (&service.CommissionService{}).UpdateRuleStatus(req.RuleId, *req.Status, adminId.(uint64)); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
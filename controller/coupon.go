@@ -0,0 +1,282 @@
+// controller/coupon.go
+package controller
+
+import (
+	"strconv"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CouponController 优惠券控制器（领取/使用由患者访问，模板管理与发放仅管理员访问）
+type CouponController struct{}
+
+// GetCampaignList 患者查询可领取的活动优惠券
+func (cc *CouponController) GetCampaignList(c *gin.Context) {
+	// 1. 接收分页参数
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 10
+	}
+
+	// 2. 调用服务层查询
+	templateList, total, err := (&service.CouponService{}).GetCampaignList(page, size)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	// 返回分页结果
+	utils.Success(c, gin.H{
+		"list":  templateList,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// Claim 患者领取活动优惠券
+func (cc *CouponController) Claim(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收优惠券ID
+	var req struct {
+		TemplateId uint64 `json:"template_id" binding:"required,gt=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	if err := (&service.CouponService{}).ClaimCoupon(req.TemplateId, patientId.(uint64)); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
+
+// GetMyCouponList 患者查询我的优惠券（status：0-未使用，1-已锁定，2-已使用，3-已过期，不传查询全部）
+func (cc *CouponController) GetMyCouponList(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收分页参数与状态筛选
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	status, err := strconv.Atoi(c.DefaultQuery("status", "-1"))
+	if err != nil {
+		utils.Fail(c, "参数格式错误：status无效")
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 10
+	}
+
+	// 3. 调用服务层查询
+	couponList, total, err := (&service.CouponService{}).GetMyCouponList(patientId.(uint64), status, page, size)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	// 返回分页结果
+	utils.Success(c, gin.H{
+		"list":  couponList,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// ApplyOrderCoupon 患者为待确认/待支付订单选用优惠券（coupon_id 传0表示取消使用）
+func (cc *CouponController) ApplyOrderCoupon(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID与优惠券ID
+	var req struct {
+		OrderId  uint64 `json:"order_id" binding:"required,gt=0"`
+		CouponId uint64 `json:"coupon_id"` // 我的优惠券ID
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	order, err := (&service.CouponService{}).ApplyOrderCoupon(req.OrderId, patientId.(uint64), req.CouponId)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"order_amount":    order.OrderAmount,
+		"discount_amount": order.DiscountAmount,
+		"pay_amount":      order.PayAmount(),
+	})
+}
+
+// GetTemplateList 管理员查询优惠券模板列表（status：0-停用，1-启用，不传查询全部）
+func (cc *CouponController) GetTemplateList(c *gin.Context) {
+	// 1. 接收分页参数与状态筛选
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	status, err := strconv.Atoi(c.DefaultQuery("status", "-1"))
+	if err != nil {
+		utils.Fail(c, "参数格式错误：status无效")
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 10
+	}
+
+	// 2. 调用服务层查询
+	templateList, total, err := (&service.CouponService{}).GetTemplateList(status, page, size)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	// 返回分页结果
+	utils.Success(c, gin.H{
+		"list":  templateList,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// CreateTemplate 管理员新增优惠券模板
+func (cc *CouponController) CreateTemplate(c *gin.Context) {
+	// 1. 获取当前管理员ID
+	adminId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收模板参数（时间格式：2006-01-02 15:04:05，为空表示不限）
+	var req struct {
+		Name         string      `json:"name" binding:"required,max=32"`
+		Type         int         `json:"type" binding:"required,oneof=1 2 3"`     // 1-立减券，2-折扣券，3-满减券
+		Amount       utils.Money `json:"amount"`                                  // 优惠金额（立减券、满减券）
+		Rate         float64     `json:"rate"`                                    // 优惠比例（折扣券，如0.2表示减免20%）
+		MaxDiscount  utils.Money `json:"max_discount"`                            // 折扣券优惠上限
+		MinAmount    utils.Money `json:"min_amount"`                              // 使用门槛
+		City         string      `json:"city" binding:"max=32"`                   // 限定城市
+		Hospital     string      `json:"hospital" binding:"max=100"`              // 限定医院
+		IssueType    int         `json:"issue_type" binding:"required,oneof=1 2"` // 1-管理员发放，2-活动领取
+		ClaimStartAt string      `json:"claim_start_at"`                          // 活动领取开始时间
+		ClaimEndAt   string      `json:"claim_end_at"`                            // 活动领取结束时间
+		ValidStartAt string      `json:"valid_start_at"`                          // 使用有效期开始时间
+		ValidEndAt   string      `json:"valid_end_at"`                            // 使用有效期结束时间
+		ValidDays    int         `json:"valid_days"`                              // 领取后有效天数
+		TotalCount   int         `json:"total_count"`                             // 发放总量
+		PerUserLimit int         `json:"per_user_limit"`                          // 每位患者最多持有张数
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	templateId, err := (&service.CouponService{}).CreateTemplate(service.CouponTemplateInput{
+		Name:         req.Name,
+		Type:         req.Type,
+		Amount:       req.Amount,
+		Rate:         req.Rate,
+		MaxDiscount:  req.MaxDiscount,
+		MinAmount:    req.MinAmount,
+		City:         req.City,
+		Hospital:     req.Hospital,
+		IssueType:    req.IssueType,
+		ClaimStartAt: req.ClaimStartAt,
+		ClaimEndAt:   req.ClaimEndAt,
+		ValidStartAt: req.ValidStartAt,
+		ValidEndAt:   req.ValidEndAt,
+		ValidDays:    req.ValidDays,
+		TotalCount:   req.TotalCount,
+		PerUserLimit: req.PerUserLimit,
+	}, adminId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"template_id": templateId,
+	})
+}
+
+// UpdateTemplateStatus 管理员启用/停用优惠券模板（status：0-停用，1-启用）
+func (cc *CouponController) UpdateTemplateStatus(c *gin.Context) {
+	// 1. 接收模板ID与状态
+	var req struct {
+		TemplateId uint64 `json:"template_id" binding:"required,gt=0"`
+		Status     *int   `json:"status" binding:"required,oneof=0 1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 2. 调用服务层方法
+	if err := (&service.CouponService{}).UpdateTemplateStatus(req.TemplateId, *req.Status); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
+
+// Issue 管理员向指定患者发放优惠券（已达持有上限的患者跳过）
+func (cc *CouponController) Issue(c *gin.Context) {
+	// 1. 接收模板ID与发放对象
+	var req struct {
+		TemplateId uint64   `json:"template_id" binding:"required,gt=0"`
+		PatientIds []uint64 `json:"patient_ids" binding:"required,min=1,max=100,unique,dive,gt=0"` // 患者ID（单次最多100个）
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 2. 调用服务层方法
+	issued, err := (&service.CouponService{}).IssueCoupons(req.TemplateId, req.PatientIds)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"issued": issued,
+	})
+}
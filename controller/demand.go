@@ -24,13 +24,15 @@ func (d *DemandController) Publish(c *gin.Context) {
 
 	// 2. 接收前端提交的参数（与前端请求体字段对应）
 	var req struct {
-		Hospital       string  `json:"hospital" binding:"required,max=100"`      // 就诊医院
-		HospitalAddr   string  `json:"hospital_addr" binding:"required,max=255"` // 医院地址
-		ServiceTime    string  `json:"service_time" binding:"required"`          // 服务时间（前端传格式化字符串，如：2025-12-25 09:30:00）
-		ExpectedPrice  float64 `json:"expected_price" binding:"required,gt=0"`   // 期望价格（大于0）
-		ServiceContent string  `json:"service_content" binding:"required"`       // 服务内容
-		ContactName    string  `json:"contact_name" binding:"required,max=16"`   // 联系人姓名
-		ContactPhone   string  `json:"contact_phone" binding:"required,len=11"`  // 联系人电话
+		Hospital       string      `json:"hospital" binding:"required,max=100"`      // 就诊医院
+		HospitalAddr   string      `json:"hospital_addr" binding:"required,max=255"` // 医院地址
+		ServiceTime    string      `json:"service_time" binding:"required"`          // 服务时间（前端传格式化字符串，如：2025-12-25 09:30:00）
+		ExpectedPrice  utils.Money `json:"expected_price" binding:"required,gt=0"`   // 期望价格（大于0）
+		ServiceContent string      `json:"service_content" binding:"required"`       // 服务内容
+		ContactName    string      `json:"contact_name" binding:"required,max=16"`   // 联系人姓名
+		ContactPhone   string      `json:"contact_phone" binding:"required,len=11"`  // 联系人电话
+		BidMode        int         `json:"bid_mode" binding:"oneof=0 1"`             // 接单模式（0-抢单，1-竞价，默认抢单）
+		DesignatedId   uint64      `json:"designated_id"`                            // 指定陪诊师ID（可选，指定后仅该陪诊师可见）
 	}
 
 	// 3. 参数校验（绑定失败返回错误）
@@ -49,6 +51,8 @@ func (d *DemandController) Publish(c *gin.Context) {
 		req.ServiceContent,
 		req.ContactName,
 		req.ContactPhone,
+		req.BidMode,
+		req.DesignatedId,
 	)
 
 	// 5. 处理业务结果
@@ -71,14 +75,14 @@ func (d *DemandController) Update(c *gin.Context) {
 
 	// 接收参数
 	var req struct {
-		ID             uint64  `json:"id" binding:"required,gt=0"`               // 需求ID
-		Hospital       string  `json:"hospital" binding:"required,max=100"`      // 就诊医院
-		HospitalAddr   string  `json:"hospital_addr" binding:"required,max=255"` // 医院地址
-		ServiceTime    string  `json:"service_time" binding:"required"`          // 服务时间
-		ExpectedPrice  float64 `json:"expected_price" binding:"required,gt=0"`   // 期望价格
-		ServiceContent string  `json:"service_content" binding:"required"`       // 服务内容
-		ContactName    string  `json:"contact_name" binding:"required,max=16"`   // 联系人姓名
-		ContactPhone   string  `json:"contact_phone" binding:"required,len=11"`  // 联系人电话
+		ID             uint64      `json:"id" binding:"required,gt=0"`               // 需求ID
+		Hospital       string      `json:"hospital" binding:"required,max=100"`      // 就诊医院
+		HospitalAddr   string      `json:"hospital_addr" binding:"required,max=255"` // 医院地址
+		ServiceTime    string      `json:"service_time" binding:"required"`          // 服务时间
+		ExpectedPrice  utils.Money `json:"expected_price" binding:"required,gt=0"`   // 期望价格
+		ServiceContent string      `json:"service_content" binding:"required"`       // 服务内容
+		ContactName    string      `json:"contact_name" binding:"required,max=16"`   // 联系人姓名
+		ContactPhone   string      `json:"contact_phone" binding:"required,len=11"`  // 联系人电话
 	}
 
 	// 参数绑定
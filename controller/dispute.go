@@ -0,0 +1,137 @@
+// controller/dispute.go
+package controller
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DisputeController 订单争议控制器（发起/查询由订单参与方访问，裁决仅管理员访问）
+type DisputeController struct{}
+
+// Open 发起争议（仅服务中/待结算订单，发起后订单冻结结算）
+func (d *DisputeController) Open(c *gin.Context) {
+	// 1. 获取当前用户ID
+	userId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收争议参数
+	var req struct {
+		OrderId      uint64   `json:"order_id" binding:"required,gt=0"`
+		Description  string   `json:"description" binding:"required,min=5,max=500"` // 争议描述
+		EvidenceUrls []string `json:"evidence_urls" binding:"max=9"`                // 证据图片（先调用上传接口，传图片地址数组）
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	disputeId, err := (&service.DisputeService{}).OpenDispute(req.OrderId, userId.(uint64), req.Description, strings.Join(req.EvidenceUrls, ","), c.ClientIP())
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"dispute_id": disputeId,
+	})
+}
+
+// GetOrderDispute 查询订单的争议记录（仅订单参与方访问）
+func (d *DisputeController) GetOrderDispute(c *gin.Context) {
+	// 1. 获取当前用户ID
+	userId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID
+	orderId, err := strconv.ParseUint(c.Query("order_id"), 10, 64)
+	if err != nil || orderId == 0 {
+		utils.Fail(c, "参数格式错误：order_id无效")
+		return
+	}
+
+	// 3. 调用服务层查询
+	dispute, err := (&service.DisputeService{}).GetOrderDispute(orderId, userId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, dispute)
+}
+
+// GetList 管理员查询争议列表（status：0-待裁决，1-已裁决，不传查询全部）
+func (d *DisputeController) GetList(c *gin.Context) {
+	// 1. 接收分页参数与状态筛选
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	status, err := strconv.Atoi(c.DefaultQuery("status", "-1"))
+	if err != nil {
+		utils.Fail(c, "参数格式错误：status无效")
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 10
+	}
+
+	// 2. 调用服务层查询
+	disputeList, total, err := (&service.DisputeService{}).GetDisputeList(status, page, size)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	// 返回分页结果
+	utils.Success(c, gin.H{
+		"list":  disputeList,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// Resolve 管理员裁决争议
+func (d *DisputeController) Resolve(c *gin.Context) {
+	// 1. 获取当前管理员ID
+	adminId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收裁决参数
+	var req struct {
+		DisputeId  uint64  `json:"dispute_id" binding:"required,gt=0"`
+		Result     int     `json:"result" binding:"required,oneof=1 2 3"` // 1-全额结算，2-部分结算，3-全额退款
+		PayoutRate float64 `json:"payout_rate"`                           // 部分结算时陪诊师获得的比例（0~1）
+		Remark     string  `json:"remark" binding:"required,max=255"`     // 裁决说明
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	err := (&service.DisputeService{}).ResolveDispute(req.DisputeId, adminId.(uint64), req.Result, req.PayoutRate, req.Remark, c.ClientIP())
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
@@ -0,0 +1,71 @@
+// controller/notification.go
+package controller
+
+import (
+	"strconv"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationController 站内通知控制器（所有登录用户均可访问）
+type NotificationController struct{}
+
+// GetNotificationList 查询当前用户的通知列表
+func (n *NotificationController) GetNotificationList(c *gin.Context) {
+	// 1. 获取当前用户ID
+	userId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收分页参数与未读筛选
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	unreadOnly := c.DefaultQuery("unread", "") == "1" // 传1时仅查询未读通知
+
+	// 3. 调用服务层查询
+	noticeList, total, err := (&service.NotificationService{}).GetUserNotificationList(userId.(uint64), unreadOnly, page, size)
+	if err != nil {
+		utils.Fail(c, "查询通知列表失败："+err.Error())
+		return
+	}
+
+	// 返回分页结果
+	utils.Success(c, gin.H{
+		"list":  noticeList,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// MarkRead 标记通知已读（不传通知ID时全部标记已读）
+func (n *NotificationController) MarkRead(c *gin.Context) {
+	// 1. 获取当前用户ID
+	userId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收通知ID（可选）
+	var req struct {
+		NoticeId uint64 `json:"notice_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	if err := (&service.NotificationService{}).MarkNotificationRead(userId.(uint64), req.NoticeId); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
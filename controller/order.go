@@ -3,6 +3,7 @@ package controller
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/X-Colder/companion-backend/service"
 	"github.com/X-Colder/companion-backend/utils"
@@ -58,7 +59,7 @@ func (o *OrderController) TakeOrder(c *gin.Context) {
 	}
 
 	// 4. 调用服务层接单方法
-	err := (&service.OrderService{}).TakeOrder(req.DemandId, companionId.(uint64))
+	err := (&service.OrderService{}).TakeOrder(req.DemandId, companionId.(uint64), c.ClientIP())
 	if err != nil {
 		utils.Fail(c, err.Error())
 		return
@@ -67,6 +68,84 @@ func (o *OrderController) TakeOrder(c *gin.Context) {
 	utils.Success(c, "接单成功，等待患者确认")
 }
 
+// GetInviteList 查询患者指定给我的待处理邀请（仅陪诊师访问）
+func (o *OrderController) GetInviteList(c *gin.Context) {
+	// 1. 获取当前陪诊师ID
+	companionId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 调用服务层查询
+	demandList, err := (&service.OrderService{}).GetInviteDemandList(companionId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": demandList,
+	})
+}
+
+// AcceptInvite 接受患者的指定邀请（生成订单，仅陪诊师访问）
+func (o *OrderController) AcceptInvite(c *gin.Context) {
+	// 1. 获取当前陪诊师ID
+	companionId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收需求ID
+	var req struct {
+		DemandId uint64 `json:"demand_id" binding:"required,gt=0"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	if err := (&service.OrderService{}).AcceptInvite(req.DemandId, companionId.(uint64), c.ClientIP()); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "已接受邀请，订单已生成")
+}
+
+// DeclineInvite 拒绝患者的指定邀请（需求公开到订单大厅，仅陪诊师访问）
+func (o *OrderController) DeclineInvite(c *gin.Context) {
+	// 1. 获取当前陪诊师ID
+	companionId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收需求ID与拒绝原因
+	var req struct {
+		DemandId uint64 `json:"demand_id" binding:"required,gt=0"`
+		Reason   string `json:"reason" binding:"required,max=255"` // 拒绝原因
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	if err := (&service.DemandService{}).DeclineInvite(req.DemandId, companionId.(uint64), req.Reason); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "已拒绝邀请")
+}
+
 // GetCompanionServiceList 获取陪诊师服务列表（自己接的订单，仅陪诊师访问）
 func (o *OrderController) GetCompanionServiceList(c *gin.Context) {
 	// 1. 获取当前陪诊师ID
@@ -79,7 +158,7 @@ func (o *OrderController) GetCompanionServiceList(c *gin.Context) {
 	// 2. 接收分页参数与状态筛选
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
-	statusStr := c.DefaultQuery("status", "") // 可选筛选：1-待服务，2-服务中，3-待结算，4-已完成，5-已取消
+	statusStr := c.DefaultQuery("status", "") // 可选筛选：1-待服务，2-服务中，3-待结算，4-已完成，5-已取消，6-待确认，7-争议中，8-待支付
 	var status int
 	if statusStr != "" {
 		s, err := strconv.Atoi(statusStr)
@@ -124,7 +203,7 @@ func (o *OrderController) CompanionConfirmComplete(c *gin.Context) {
 	}
 
 	// 3. 调用服务层方法
-	err := (&service.OrderService{}).CompanionConfirmOrderComplete(req.OrderId, companionId.(uint64))
+	err := (&service.OrderService{}).CompanionConfirmOrderComplete(req.OrderId, companionId.(uint64), c.ClientIP())
 	if err != nil {
 		utils.Fail(c, err.Error())
 		return
@@ -133,7 +212,7 @@ func (o *OrderController) CompanionConfirmComplete(c *gin.Context) {
 	utils.Success(c, "已确认服务完成，等待患者确认")
 }
 
-// CompanionCancelOrder 陪诊师取消订单（仅待服务状态可取消，仅陪诊师访问）
+// CompanionCancelOrder 陪诊师取消订单（仅待支付/待服务状态可取消，仅陪诊师访问）
 func (o *OrderController) CompanionCancelOrder(c *gin.Context) {
 	// 1. 获取当前陪诊师ID
 	companionId, exists := c.Get("user_id")
@@ -154,7 +233,7 @@ func (o *OrderController) CompanionCancelOrder(c *gin.Context) {
 	}
 
 	// 3. 调用服务层方法
-	err := (&service.OrderService{}).CompanionCancelOrder(req.OrderId, companionId.(uint64), req.Reason)
+	err := (&service.OrderService{}).CompanionCancelOrder(req.OrderId, companionId.(uint64), req.Reason, c.ClientIP())
 	if err != nil {
 		utils.Fail(c, err.Error())
 		return
@@ -163,6 +242,47 @@ func (o *OrderController) CompanionCancelOrder(c *gin.Context) {
 	utils.Success(c, "订单取消成功")
 }
 
+// CompanionCheckIn 陪诊师到场签到（核验患者出示的签到码，订单进入服务中，仅陪诊师访问）
+func (o *OrderController) CompanionCheckIn(c *gin.Context) {
+	// 1. 获取当前陪诊师ID
+	companionId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收签到参数
+	var req struct {
+		OrderId     uint64   `json:"order_id" binding:"required,gt=0"`
+		CheckinCode string   `json:"checkin_code" binding:"required,len=6"`         // 患者出示的签到码
+		Latitude    *float64 `json:"latitude" binding:"required,min=-90,max=90"`    // 签到纬度（指针类型，允许传0）
+		Longitude   *float64 `json:"longitude" binding:"required,min=-180,max=180"` // 签到经度（指针类型，允许传0）
+		Timestamp   int64    `json:"timestamp" binding:"required,gt=0"`             // 签到时间戳（秒）
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	err := (&service.OrderService{}).CompanionCheckIn(
+		req.OrderId,
+		companionId.(uint64),
+		req.CheckinCode,
+		*req.Latitude,
+		*req.Longitude,
+		time.Unix(req.Timestamp, 0),
+		c.ClientIP(),
+	)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "签到成功，服务已开始")
+}
+
 // -------------------------- 患者专属接口 --------------------------
 
 // GetPatientOrderList 获取患者订单列表（自己发布的需求对应的订单，仅患者访问）
@@ -222,7 +342,7 @@ func (o *OrderController) PatientConfirmComplete(c *gin.Context) {
 	}
 
 	// 3. 调用服务层方法
-	err := (&service.OrderService{}).PatientConfirmOrderComplete(req.OrderId, patientId.(uint64))
+	err := (&service.OrderService{}).PatientConfirmOrderComplete(req.OrderId, patientId.(uint64), c.ClientIP())
 	if err != nil {
 		utils.Fail(c, err.Error())
 		return
@@ -231,7 +351,7 @@ func (o *OrderController) PatientConfirmComplete(c *gin.Context) {
 	utils.Success(c, "已确认服务完成，订单已结算")
 }
 
-// PatientCancelOrder 患者取消订单（仅待服务状态可取消，仅患者访问）
+// PatientCancelOrder 患者取消订单（仅待支付/待服务状态可取消，仅患者访问）
 func (o *OrderController) PatientCancelOrder(c *gin.Context) {
 	// 1. 获取当前患者ID
 	patientId, exists := c.Get("user_id")
@@ -252,7 +372,7 @@ func (o *OrderController) PatientCancelOrder(c *gin.Context) {
 	}
 
 	// 3. 调用服务层方法
-	err := (&service.OrderService{}).PatientCancelOrder(req.OrderId, patientId.(uint64), req.Reason)
+	err := (&service.OrderService{}).PatientCancelOrder(req.OrderId, patientId.(uint64), req.Reason, c.ClientIP())
 	if err != nil {
 		utils.Fail(c, err.Error())
 		return
@@ -260,3 +380,146 @@ func (o *OrderController) PatientCancelOrder(c *gin.Context) {
 
 	utils.Success(c, "订单取消成功")
 }
+
+// PatientApprove 患者确认陪诊师接单（仅患者访问）
+func (o *OrderController) PatientApprove(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID
+	var req struct {
+		OrderId uint64 `json:"order_id" binding:"required,gt=0"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	err := (&service.OrderService{}).PatientApproveOrder(req.OrderId, patientId.(uint64), c.ClientIP())
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "已确认陪诊师，请在支付时限内完成支付")
+}
+
+// PatientReject 患者拒绝陪诊师接单（需求退回订单大厅，仅患者访问）
+func (o *OrderController) PatientReject(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID与拒绝原因
+	var req struct {
+		OrderId uint64 `json:"order_id" binding:"required,gt=0"`
+		Reason  string `json:"reason" binding:"required,max=255"` // 拒绝原因
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	err := (&service.OrderService{}).PatientRejectOrder(req.OrderId, patientId.(uint64), req.Reason, c.ClientIP())
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "已拒绝该陪诊师，需求已重新进入订单大厅")
+}
+
+// GetCheckinCode 获取订单签到码（陪诊师到场后由患者出示，仅患者访问）
+func (o *OrderController) GetCheckinCode(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID
+	orderId, err := strconv.ParseUint(c.Query("order_id"), 10, 64)
+	if err != nil || orderId == 0 {
+		utils.Fail(c, "参数格式错误：order_id无效")
+		return
+	}
+
+	// 3. 调用服务层方法
+	code, err := (&service.OrderService{}).GetOrderCheckinCode(orderId, patientId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"checkin_code": code,
+	})
+}
+
+// -------------------------- 通用接口 --------------------------
+
+// GetOrderEvents 查询订单流转时间线（谁在何时做了什么、原因是什么，仅订单参与方访问）
+func (o *OrderController) GetOrderEvents(c *gin.Context) {
+	// 1. 获取当前用户ID
+	userId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID
+	orderId, err := strconv.ParseUint(c.Query("order_id"), 10, 64)
+	if err != nil || orderId == 0 {
+		utils.Fail(c, "参数格式错误：order_id无效")
+		return
+	}
+
+	// 3. 调用服务层查询
+	eventList, err := (&service.OrderService{}).GetOrderEventList(orderId, userId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": eventList,
+	})
+}
+
+// GetCancelPenalty 预估取消订单的违约金（取消前提示用户，仅订单参与方访问）
+func (o *OrderController) GetCancelPenalty(c *gin.Context) {
+	// 1. 获取当前用户ID
+	userId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID
+	orderId, err := strconv.ParseUint(c.Query("order_id"), 10, 64)
+	if err != nil || orderId == 0 {
+		utils.Fail(c, "参数格式错误：order_id无效")
+		return
+	}
+
+	// 3. 调用服务层预估
+	penalty, err := (&service.OrderService{}).PreviewCancelPenalty(orderId, userId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, penalty)
+}
@@ -0,0 +1,91 @@
+// controller/payment.go
+package controller
+
+import (
+	"strconv"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentController 支付控制器（发起支付/查询结果仅患者访问，支付回调由支付渠道调用）
+type PaymentController struct{}
+
+// Pay 患者为待支付订单发起支付
+func (p *PaymentController) Pay(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID与优惠券
+	var req struct {
+		OrderId  uint64 `json:"order_id" binding:"required,gt=0"`
+		CouponId uint64 `json:"coupon_id"` // 使用的优惠券ID（可选，取自我的优惠券列表）
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层发起支付
+	params, err := (&service.PaymentService{}).CreateOrderPayment(req.OrderId, patientId.(uint64), req.CouponId)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, params)
+}
+
+// QueryPayResult 患者查询订单支付结果（回调未到达时向支付渠道主动查询）
+func (p *PaymentController) QueryPayResult(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID
+	orderId, err := strconv.ParseUint(c.Query("order_id"), 10, 64)
+	if err != nil || orderId == 0 {
+		utils.Fail(c, "参数格式错误：order_id无效")
+		return
+	}
+
+	// 3. 调用服务层查询
+	order, err := (&service.PaymentService{}).SyncOrderPayment(orderId, patientId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"order_id": order.ID,
+		"status":   order.Status, // 订单状态（1-待服务表示已支付成功，8-待支付）
+		"paid_at":  order.PaidAt,
+	})
+}
+
+// Callback 支付渠道回调（无需登录，由渠道签名保证来源可信）
+func (p *PaymentController) Callback(c *gin.Context) {
+	// 1. 读取原始报文（验签需使用未经解析的原文）
+	body, err := c.GetRawData()
+	if err != nil {
+		utils.Fail(c, "读取回调报文失败")
+		return
+	}
+
+	// 2. 调用服务层处理回调
+	if err := (&service.PaymentService{}).HandleCallback(c.Param("provider"), body, c.Request.Header); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
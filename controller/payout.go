@@ -0,0 +1,118 @@
+// controller/payout.go
+package controller
+
+import (
+	"strconv"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PayoutController 提现批量打款控制器（仅管理员访问）
+type PayoutController struct{}
+
+// Export 导出尚未打款的提现中提现单为银行批量转账文件（生成打款批次）
+func (p *PayoutController) Export(c *gin.Context) {
+	// 1. 获取当前管理员ID
+	adminId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 调用服务层生成打款批次
+	batch, data, err := (&service.PayoutService{}).ExportBatch(adminId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	writePayoutFile(c, batch.BatchNo, data)
+}
+
+// GetBatchFile 重新下载打款批次的银行批量转账文件
+func (p *PayoutController) GetBatchFile(c *gin.Context) {
+	// 1. 接收批次编号
+	batchNo := c.Query("batch_no")
+	if batchNo == "" {
+		utils.Fail(c, "参数格式错误：batch_no不能为空")
+		return
+	}
+
+	// 2. 调用服务层生成文件
+	batch, data, err := (&service.PayoutService{}).GetBatchFile(batchNo)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	writePayoutFile(c, batch.BatchNo, data)
+}
+
+// GetBatchList 管理员查询打款批次列表
+func (p *PayoutController) GetBatchList(c *gin.Context) {
+	// 1. 接收分页参数
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 10
+	}
+
+	// 2. 调用服务层查询
+	batchList, total, err := (&service.PayoutService{}).GetBatchList(page, size)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":  batchList,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// Import 导入银行结果文件（表单字段名：file），逐行确认提现打款结果
+func (p *PayoutController) Import(c *gin.Context) {
+	// 1. 获取当前管理员ID
+	adminId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收结果文件
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.Fail(c, "获取上传文件失败："+err.Error())
+		return
+	}
+	fileReader, err := file.Open()
+	if err != nil {
+		utils.Fail(c, "打开上传文件失败："+err.Error())
+		return
+	}
+	defer fileReader.Close()
+
+	// 3. 调用服务层导入
+	result, err := (&service.PayoutService{}).ImportResult(fileReader, adminId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, result)
+}
+
+// writePayoutFile 以附件形式返回打款文件
+func writePayoutFile(c *gin.Context, batchNo string, data []byte) {
+	c.Header("Content-Disposition", "attachment; filename=payout_"+batchNo+".csv")
+	c.Header("X-Payout-Batch-No", batchNo)
+	c.Data(200, "text/csv; charset=utf-8", data)
+}
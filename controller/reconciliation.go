@@ -0,0 +1,23 @@
+// controller/reconciliation.go
+package controller
+
+import (
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReconciliationController 余额对账控制器（仅管理员访问）
+type ReconciliationController struct{}
+
+// GetLatest 管理员查询最近一次余额对账结果（批次汇总及余额不一致的陪诊师明细）
+func (r *ReconciliationController) GetLatest(c *gin.Context) {
+	report, err := (&service.ReconciliationService{}).GetLatestReport()
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, report)
+}
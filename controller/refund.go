@@ -0,0 +1,93 @@
+// controller/refund.go
+package controller
+
+import (
+	"strconv"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefundController 退款控制器（退款进度由患者查询，退款单管理仅管理员访问）
+type RefundController struct{}
+
+// GetOrderRefundList 患者查询订单退款进度
+func (r *RefundController) GetOrderRefundList(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID
+	orderId, err := strconv.ParseUint(c.Query("order_id"), 10, 64)
+	if err != nil || orderId == 0 {
+		utils.Fail(c, "参数格式错误：order_id无效")
+		return
+	}
+
+	// 3. 调用服务层查询
+	refundList, err := (&service.RefundService{}).GetOrderRefundList(orderId, patientId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, refundList)
+}
+
+// GetList 管理员查询退款单列表（status：0-待退款，1-退款处理中，2-退款成功，3-退款失败，不传查询全部）
+func (r *RefundController) GetList(c *gin.Context) {
+	// 1. 接收分页参数与状态筛选
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	status, err := strconv.Atoi(c.DefaultQuery("status", "-1"))
+	if err != nil {
+		utils.Fail(c, "参数格式错误：status无效")
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 10
+	}
+
+	// 2. 调用服务层查询
+	refundList, total, err := (&service.RefundService{}).GetRefundList(status, page, size)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	// 返回分页结果
+	utils.Success(c, gin.H{
+		"list":  refundList,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// Retry 管理员重新发起退款失败的退款单
+func (r *RefundController) Retry(c *gin.Context) {
+	// 1. 接收退款单号
+	var req struct {
+		RefundNo string `json:"refund_no" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 2. 调用服务层方法
+	if err := (&service.RefundService{}).RetryFailedRefund(req.RefundNo); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, nil)
+}
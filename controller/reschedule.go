@@ -0,0 +1,150 @@
+// controller/reschedule.go
+package controller
+
+import (
+	"strconv"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RescheduleController 改约控制器（患者与陪诊师共用，按订单参与方校验权限）
+type RescheduleController struct{}
+
+// Propose 发起改约申请
+func (r *RescheduleController) Propose(c *gin.Context) {
+	// 1. 获取当前用户ID
+	userId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收改约参数
+	var req struct {
+		OrderId         uint64 `json:"order_id" binding:"required,gt=0"`
+		NewServiceTime  string `json:"new_service_time" binding:"required"` // 新服务时间（如：2025-12-25 09:30:00）
+		NewHospitalAddr string `json:"new_hospital_addr" binding:"max=255"` // 新医院地址（可选）
+		Reason          string `json:"reason" binding:"required,max=255"`   // 改约原因
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	requestId, err := (&service.RescheduleService{}).ProposeReschedule(
+		req.OrderId,
+		userId.(uint64),
+		req.NewServiceTime,
+		req.NewHospitalAddr,
+		req.Reason,
+	)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"request_id": requestId,
+		"msg":        "改约申请已提交，等待对方处理",
+	})
+}
+
+// Respond 处理对方的改约申请（同意或拒绝）
+func (r *RescheduleController) Respond(c *gin.Context) {
+	// 1. 获取当前用户ID
+	userId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收处理参数
+	var req struct {
+		RequestId uint64 `json:"request_id" binding:"required,gt=0"`
+		Accept    bool   `json:"accept"`                   // true-同意，false-拒绝
+		Reason    string `json:"reason" binding:"max=255"` // 处理说明（拒绝时必填）
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+	if !req.Accept && utils.IsEmptyString(req.Reason) {
+		utils.Fail(c, "拒绝改约时请填写原因")
+		return
+	}
+
+	// 3. 调用服务层方法
+	err := (&service.RescheduleService{}).RespondReschedule(req.RequestId, userId.(uint64), req.Accept, req.Reason, c.ClientIP())
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	if req.Accept {
+		utils.Success(c, "已同意改约，服务时间已更新")
+		return
+	}
+	utils.Success(c, "已拒绝改约")
+}
+
+// Withdraw 撤回自己发起的改约申请
+func (r *RescheduleController) Withdraw(c *gin.Context) {
+	// 1. 获取当前用户ID
+	userId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收申请ID
+	var req struct {
+		RequestId uint64 `json:"request_id" binding:"required,gt=0"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层方法
+	if err := (&service.RescheduleService{}).WithdrawReschedule(req.RequestId, userId.(uint64)); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, "改约申请已撤回")
+}
+
+// GetList 查询订单的改约记录
+func (r *RescheduleController) GetList(c *gin.Context) {
+	// 1. 获取当前用户ID
+	userId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID
+	orderId, err := strconv.ParseUint(c.Query("order_id"), 10, 64)
+	if err != nil || orderId == 0 {
+		utils.Fail(c, "参数格式错误：order_id无效")
+		return
+	}
+
+	// 3. 调用服务层查询
+	requestList, err := (&service.RescheduleService{}).GetOrderRescheduleList(orderId, userId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list": requestList,
+	})
+}
@@ -0,0 +1,100 @@
+// controller/statement.go
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatementController 收入对账单控制器（陪诊师查询本人对账单，管理员可查询任意陪诊师，校验码核验公开访问）
+type StatementController struct{}
+
+// GetStatement 陪诊师查询本人月度收入对账单（format：json-默认，csv/pdf-下载文件）
+func (s *StatementController) GetStatement(c *gin.Context) {
+	// 1. 获取当前陪诊师ID
+	companionId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 生成对账单并按格式返回
+	statement, err := (&service.StatementService{}).GetCompanionStatement(companionId.(uint64), c.Query("month"))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+	writeStatement(c, statement, c.DefaultQuery("format", "json"))
+}
+
+// AdminGetStatement 管理员查询陪诊师月度收入对账单（format：json-默认，csv/pdf-下载文件）
+func (s *StatementController) AdminGetStatement(c *gin.Context) {
+	// 1. 接收陪诊师ID
+	companionId, err := strconv.ParseUint(c.Query("companion_id"), 10, 64)
+	if err != nil || companionId == 0 {
+		utils.Fail(c, "参数格式错误：companion_id无效")
+		return
+	}
+
+	// 2. 生成对账单并按格式返回
+	statement, err := (&service.StatementService{}).GetCompanionStatement(companionId, c.Query("month"))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+	writeStatement(c, statement, c.DefaultQuery("format", "json"))
+}
+
+// Verify 核验对账单校验码（公开接口，供收到对账单的第三方核验真伪）
+func (s *StatementController) Verify(c *gin.Context) {
+	// 1. 接收核验参数
+	companionId, err := strconv.ParseUint(c.Query("companion_id"), 10, 64)
+	if err != nil || companionId == 0 {
+		utils.Fail(c, "参数格式错误：companion_id无效")
+		return
+	}
+	checksum := c.Query("checksum")
+	if checksum == "" {
+		utils.Fail(c, "参数格式错误：checksum不能为空")
+		return
+	}
+
+	// 2. 调用服务层核验（校验通过时返回对账单内容，便于比对）
+	valid, statement, err := (&service.StatementService{}).VerifyStatement(companionId, c.Query("month"), checksum)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"valid":     valid,
+		"statement": statement,
+	})
+}
+
+// writeStatement 按格式返回对账单（json 直接返回，csv/pdf 以附件下载）
+func writeStatement(c *gin.Context, statement *service.EarningsStatement, format string) {
+	filename := fmt.Sprintf("statement_%d_%s.%s", statement.CompanionId, statement.Month, format)
+	switch format {
+	case "json":
+		utils.Success(c, statement)
+	case "csv":
+		data, err := service.RenderStatementCSV(statement)
+		if err != nil {
+			utils.Fail(c, err.Error())
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Data(200, "text/csv; charset=utf-8", data)
+	case "pdf":
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Data(200, "application/pdf", service.RenderStatementPDF(statement))
+	default:
+		utils.Fail(c, "参数格式错误：format仅支持json、csv、pdf")
+	}
+}
@@ -0,0 +1,96 @@
+// controller/tip.go
+package controller
+
+import (
+	"strconv"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TipController 打赏控制器（仅患者访问，订单完成后向陪诊师打赏）
+type TipController struct{}
+
+// Create 患者为已完成订单发起打赏
+func (t *TipController) Create(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收打赏参数
+	var req struct {
+		OrderId uint64      `json:"order_id" binding:"required,gt=0"`
+		Amount  utils.Money `json:"amount" binding:"required,gt=0"`
+		Message string      `json:"message" binding:"max=100"` // 打赏留言（可选）
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数格式错误："+err.Error())
+		return
+	}
+
+	// 3. 调用服务层发起打赏支付
+	params, err := (&service.TipService{}).CreateTip(req.OrderId, patientId.(uint64), req.Amount, req.Message)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, params)
+}
+
+// QueryResult 患者查询打赏支付结果（回调未到达时向支付渠道主动查询）
+func (t *TipController) QueryResult(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收打赏编号
+	tipNo := c.Query("tip_no")
+	if tipNo == "" {
+		utils.Fail(c, "参数格式错误：tip_no不能为空")
+		return
+	}
+
+	// 3. 调用服务层查询
+	tip, err := (&service.TipService{}).SyncTipPayment(tipNo, patientId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, tip)
+}
+
+// GetOrderTipList 患者查询订单的打赏记录
+func (t *TipController) GetOrderTipList(c *gin.Context) {
+	// 1. 获取当前患者ID
+	patientId, exists := c.Get("user_id")
+	if !exists {
+		utils.Unauthorized(c, "用户身份验证失败")
+		return
+	}
+
+	// 2. 接收订单ID
+	orderId, err := strconv.ParseUint(c.Query("order_id"), 10, 64)
+	if err != nil || orderId == 0 {
+		utils.Fail(c, "参数格式错误：order_id无效")
+		return
+	}
+
+	// 3. 调用服务层查询
+	tipList, err := (&service.TipService{}).GetOrderTipList(orderId, patientId.(uint64))
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.Success(c, tipList)
+}
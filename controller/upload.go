@@ -45,13 +45,29 @@ func (u *UploadController) UploadAvatar(c *gin.Context) {
 
 // UploadEvalImgs 批量上传评价图片接口
 func (u *UploadController) UploadEvalImgs(c *gin.Context) {
-	// 1. 控制器层：用Gin接收批量上传的文件（表单字段名：eval_imgs）
+	// 表单字段名：eval_imgs
+	u.batchUploadImgs(c, "eval_imgs", (&service.UploadService{}).UploadEvalImgs)
+}
+
+// UploadDisputeImgs 批量上传争议证据图片接口
+func (u *UploadController) UploadDisputeImgs(c *gin.Context) {
+	// 表单字段名：dispute_imgs
+	u.batchUploadImgs(c, "dispute_imgs", (&service.UploadService{}).UploadDisputeImgs)
+}
+
+// batchUploadImgs 批量上传图片通用逻辑（formField：表单字段名，save：对应业务的服务层批量保存方法）
+func (u *UploadController) batchUploadImgs(c *gin.Context, formField string, save func(fileInfos []struct {
+	FileName   string
+	FileSize   int64
+	FileReader io.Reader
+}) ([]string, error)) {
+	// 1. 控制器层：用Gin接收批量上传的文件
 	form, err := c.MultipartForm()
 	if err != nil {
 		utils.Fail(c, "获取批量上传文件失败："+err.Error())
 		return
 	}
-	files := form.File["eval_imgs"]
+	files := form.File[formField]
 	if len(files) == 0 {
 		utils.Fail(c, "请选择要上传的图片")
 		return
@@ -109,7 +125,7 @@ func (u *UploadController) UploadEvalImgs(c *gin.Context) {
 			FileReader: info.FileReader,
 		}
 	}
-	imgUrls, err := (&service.UploadService{}).UploadEvalImgs(uploadFileInfos)
+	imgUrls, err := save(uploadFileInfos)
 	if err != nil {
 		utils.Fail(c, err.Error())
 		return
@@ -0,0 +1,17 @@
+// job/balance.go
+package job
+
+import (
+	"log"
+
+	"github.com/X-Colder/companion-backend/service"
+)
+
+// releaseFrozenIncome 将冻结期已满的服务收入转入陪诊师可提现余额
+func releaseFrozenIncome() error {
+	released, err := (&service.BalanceService{}).ReleaseFrozenIncome()
+	if released > 0 {
+		log.Printf("冻结收入解冻：本次解冻%d笔收入", released)
+	}
+	return err
+}
@@ -0,0 +1,26 @@
+// job/demand.go
+package job
+
+import (
+	"log"
+
+	"github.com/X-Colder/companion-backend/service"
+)
+
+// releaseDesignatedDemands 将指定陪诊师逾期未处理的需求公开到订单大厅，并通知患者
+func releaseDesignatedDemands() error {
+	released, err := (&service.DemandService{}).ReleaseOverdueDesignations()
+	if released > 0 {
+		log.Printf("指定需求超时公开：本次公开%d条需求", released)
+	}
+	return err
+}
+
+// expireDemands 关闭服务时间已过仍未被接单的需求，并通知患者
+func expireDemands() error {
+	expired, err := (&service.DemandService{}).ExpireStaleDemands()
+	if expired > 0 {
+		log.Printf("过期需求关闭：本次关闭%d条需求", expired)
+	}
+	return err
+}
@@ -0,0 +1,17 @@
+// job/idempotency.go
+package job
+
+import (
+	"log"
+
+	"github.com/X-Colder/companion-backend/service"
+)
+
+// cleanIdempotencyRecords 清理已过期的幂等请求记录
+func cleanIdempotencyRecords() error {
+	cleaned, err := (&service.IdempotencyService{}).CleanExpired()
+	if cleaned > 0 {
+		log.Printf("幂等记录清理：本次清理%d条记录", cleaned)
+	}
+	return err
+}
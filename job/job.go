@@ -0,0 +1,78 @@
+// job/job.go
+package job
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/conf"
+)
+
+// InitScheduler 初始化定时任务调度器（按配置注册所有任务）
+func InitScheduler() *Scheduler {
+	s := NewScheduler()
+
+	// 待结算订单超时自动确认
+	s.Register(Job{
+		Name:     "订单自动结算",
+		Interval: time.Duration(conf.AppConfig.Job.AutoSettleInterval) * time.Second,
+		Run:      autoSettleOrders,
+	})
+
+	// 服务时间已过且未被接单的需求自动关闭
+	s.Register(Job{
+		Name:     "过期需求关闭",
+		Interval: time.Duration(conf.AppConfig.Job.DemandExpireInterval) * time.Second,
+		Run:      expireDemands,
+	})
+
+	// 陪诊师接单后患者超时未确认的处理
+	s.Register(Job{
+		Name:     "接单确认超时处理",
+		Interval: time.Duration(conf.AppConfig.Job.ApproveTimeoutInterval) * time.Second,
+		Run:      resolveApproveTimeouts,
+	})
+
+	// 指定陪诊师的需求超时未处理则公开到订单大厅
+	s.Register(Job{
+		Name:     "指定需求超时公开",
+		Interval: time.Duration(conf.AppConfig.Job.DesignateReleaseInterval) * time.Second,
+		Run:      releaseDesignatedDemands,
+	})
+
+	// 清理过期的幂等请求记录
+	s.Register(Job{
+		Name:     "幂等记录清理",
+		Interval: time.Duration(conf.AppConfig.Job.IdempotencyCleanInterval) * time.Second,
+		Run:      cleanIdempotencyRecords,
+	})
+
+	// 超过支付时限仍未支付的订单自动取消
+	s.Register(Job{
+		Name:     "待支付订单超时取消",
+		Interval: time.Duration(conf.AppConfig.Job.PayTimeoutInterval) * time.Second,
+		Run:      cancelUnpaidOrders,
+	})
+
+	// 渠道退款失败重试
+	s.Register(Job{
+		Name:     "退款重试",
+		Interval: time.Duration(conf.AppConfig.Job.RefundRetryInterval) * time.Second,
+		Run:      retryRefunds,
+	})
+
+	// 到期冻结收入转入可提现余额
+	s.Register(Job{
+		Name:     "冻结收入解冻",
+		Interval: time.Duration(conf.AppConfig.Job.IncomeReleaseInterval) * time.Second,
+		Run:      releaseFrozenIncome,
+	})
+
+	// 陪诊师余额对账
+	s.Register(Job{
+		Name:     "余额对账",
+		Interval: time.Duration(conf.AppConfig.Job.ReconcileInterval) * time.Second,
+		Run:      reconcileBalances,
+	})
+
+	return s
+}
@@ -0,0 +1,33 @@
+// job/order.go
+package job
+
+import (
+	"log"
+	"time"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/service"
+)
+
+// resolveApproveTimeouts 处理患者超时未确认的接单（按配置自动确认或退回订单大厅）
+func resolveApproveTimeouts() error {
+	resolved, err := (&service.OrderService{}).ResolveApproveTimeouts()
+	if resolved > 0 {
+		log.Printf("接单确认超时处理：本次处理%d笔订单", resolved)
+	}
+	return err
+}
+
+// autoSettleOrders 待结算订单超时自动确认完成（陪诊师入账）
+func autoSettleOrders() error {
+	timeout := time.Duration(conf.AppConfig.Order.AutoSettleHours) * time.Hour
+	if timeout <= 0 {
+		return nil
+	}
+
+	settled, err := (&service.OrderService{}).AutoSettleOrders(timeout)
+	if settled > 0 {
+		log.Printf("订单自动结算：本次自动确认%d笔订单", settled)
+	}
+	return err
+}
@@ -0,0 +1,17 @@
+// job/payment.go
+package job
+
+import (
+	"log"
+
+	"github.com/X-Colder/companion-backend/service"
+)
+
+// cancelUnpaidOrders 取消超过支付时限仍未支付的订单，并通知双方
+func cancelUnpaidOrders() error {
+	cancelled, err := (&service.OrderService{}).CancelUnpaidOrders()
+	if cancelled > 0 {
+		log.Printf("待支付订单超时取消：本次取消%d笔订单", cancelled)
+	}
+	return err
+}
@@ -0,0 +1,18 @@
+// job/reconciliation.go
+package job
+
+import (
+	"log"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/service"
+)
+
+// reconcileBalances 核对陪诊师余额（配置 reconcile.auto_fix 开启时自动修正不一致的余额）
+func reconcileBalances() error {
+	run, err := (&service.ReconciliationService{}).Reconcile(service.ReconcileTriggerJob, conf.AppConfig.Reconcile.AutoFix)
+	if run != nil && (run.MismatchCount > 0 || run.TrialBalance != 0 || run.UnbalancedEntryCount > 0) {
+		log.Printf("余额对账：批次%s 核对%d人，不一致账户%d个，已修正%d个，试算差额%s", run.RunNo, run.CheckedCount, run.MismatchCount, run.FixedCount, run.TrialBalance.String())
+	}
+	return err
+}
@@ -0,0 +1,17 @@
+// job/refund.go
+package job
+
+import (
+	"log"
+
+	"github.com/X-Colder/companion-backend/service"
+)
+
+// retryRefunds 重试到期的渠道退款（失败的按指数退避继续重试）
+func retryRefunds() error {
+	succeeded, err := (&service.RefundService{}).RetryRefunds()
+	if succeeded > 0 {
+		log.Printf("退款重试：本次退款成功%d笔", succeeded)
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+// job/scheduler.go
+package job
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Job 定时任务定义
+type Job struct {
+	Name     string        // 任务名称（用于日志）
+	Interval time.Duration // 执行间隔（<=0 表示禁用）
+	Run      func() error  // 任务逻辑（需保证多实例并发执行安全）
+}
+
+// Scheduler 定时任务调度器（每个任务独立协程，按固定间隔执行）
+type Scheduler struct {
+	jobs []Job
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewScheduler 创建调度器
+func NewScheduler() *Scheduler {
+	return &Scheduler{stop: make(chan struct{})}
+}
+
+// Register 注册定时任务（需在 Start 之前调用）
+func (s *Scheduler) Register(job Job) {
+	if job.Interval <= 0 {
+		log.Printf("定时任务[%s]未配置执行间隔，已禁用", job.Name)
+		return
+	}
+	s.jobs = append(s.jobs, job)
+}
+
+// Start 启动所有已注册的定时任务
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(job)
+		log.Printf("定时任务[%s]已启动，执行间隔：%s", job.Name, job.Interval)
+	}
+}
+
+// Stop 停止所有定时任务（等待正在执行的任务结束）
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+	log.Println("定时任务已全部停止")
+}
+
+// loop 单个任务的执行循环
+func (s *Scheduler) loop(job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.runOnce(job)
+		}
+	}
+}
+
+// runOnce 执行一次任务（捕获 panic，避免单个任务异常导致服务崩溃）
+func (s *Scheduler) runOnce(job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("定时任务[%s]执行异常：%v", job.Name, r)
+		}
+	}()
+
+	if err := job.Run(); err != nil {
+		log.Printf("定时任务[%s]执行失败：%s", job.Name, err)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -10,8 +11,11 @@ import (
 	"time"
 
 	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/job"
 	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/payment"
 	"github.com/X-Colder/companion-backend/router"
+	"github.com/X-Colder/companion-backend/service"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
@@ -24,6 +28,26 @@ func main() {
 	// 初始化数据库连接
 	initDB()
 
+	// 将账本上线前的历史余额记为期初余额
+	if err := (&service.LedgerService{}).OpenLegacyBalances(); err != nil {
+		log.Fatalf("账本期初余额初始化失败：%s", err)
+	}
+
+	// 命令行对账：companion-backend reconcile [-fix]
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		runReconcileCommand(os.Args[2:])
+		return
+	}
+
+	// 校验支付渠道配置（未配置或渠道不可用时拒绝启动）
+	if _, err := payment.Current(); err != nil {
+		log.Fatalf("支付渠道配置无效（payment.provider=%q）：%s", conf.AppConfig.Payment.Provider, err)
+	}
+
+	// 启动定时任务
+	scheduler := job.InitScheduler()
+	scheduler.Start()
+
 	// 初始化路由
 	r := router.InitRouter()
 
@@ -54,9 +78,23 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("服务关闭失败：%s", err)
 	}
+	scheduler.Stop()
 	log.Println("服务已关闭")
 }
 
+// runReconcileCommand 执行一次陪诊师余额对账并输出结果（-fix 修正不一致的余额）
+func runReconcileCommand(args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "修正不一致的余额（账户余额改回分录行之和，users.balance 的差异补记余额调整凭证）")
+	flags.Parse(args)
+
+	run, err := (&service.ReconciliationService{}).Reconcile(service.ReconcileTriggerCommand, *fix)
+	if err != nil {
+		log.Fatalf("余额对账失败：%s", err)
+	}
+	log.Printf("余额对账完成：批次%s 核对%d人，不一致账户%d个，已修正%d个，试算差额%s", run.RunNo, run.CheckedCount, run.MismatchCount, run.FixedCount, run.TrialBalance.String())
+}
+
 // initDB 初始化数据库连接
 func initDB() {
 	// 连接MySQL
@@ -82,6 +120,27 @@ func initDB() {
 		&model.Order{},
 		&model.Evaluation{},
 		&model.BalanceRecord{},
+		&model.OrderEvent{},
+		&model.Notification{},
+		&model.Bid{},
+		&model.RescheduleRequest{},
+		&model.Dispute{},
+		&model.IdempotencyRecord{},
+		&model.LedgerAccount{},
+		&model.LedgerEntry{},
+		&model.LedgerPosting{},
+		&model.Withdrawal{},
+		&model.IncomeFreeze{},
+		&model.Payment{},
+		&model.Refund{},
+		&model.CommissionRule{},
+		&model.CouponTemplate{},
+		&model.UserCoupon{},
+		&model.Tip{},
+		&model.ReconciliationRun{},
+		&model.ReconciliationItem{},
+		&model.PayoutBatch{},
+		&model.IssuedStatement{},
 	)
 
 	// 全局保存DB实例
@@ -10,8 +10,8 @@ func Cors() gin.HandlerFunc {
 		// 允许所有来源跨域（生产环境可指定具体域名）
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Idempotency-Key")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, Idempotent-Replayed")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		// 预检请求直接返回
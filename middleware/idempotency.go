@@ -0,0 +1,101 @@
+// middleware/idempotency.go
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+
+	"github.com/X-Colder/companion-backend/service"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyHeader 幂等键请求头
+const IdempotencyHeader = "Idempotency-Key"
+
+// responseRecorder 记录响应内容的 ResponseWriter（用于保存原始响应）
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency 幂等中间件（需在JWT认证之后使用）：
+// 请求携带 Idempotency-Key 时，同一用户在有效期内的重复请求直接返回首次请求的响应，不再执行业务逻辑；
+// 同一 Key 携带不同请求体时拒绝。未携带该请求头的请求不受影响
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 64 {
+			utils.Fail(c, IdempotencyHeader+"长度不能超过64")
+			c.Abort()
+			return
+		}
+		userId, exists := c.Get("user_id")
+		if !exists {
+			utils.Unauthorized(c, "用户身份验证失败")
+			c.Abort()
+			return
+		}
+
+		// 1. 读取请求体计算摘要（读取后回填，供后续处理器绑定参数）
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.Fail(c, "读取请求内容失败")
+			c.Abort()
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+		requestHash := hex.EncodeToString(sum[:])
+
+		// 2. 登记请求：重复请求直接重放原始响应
+		idem := &service.IdempotencyService{}
+		record, err := idem.Begin(userId.(uint64), key, c.Request.Method, c.Request.URL.Path, requestHash)
+		if err != nil {
+			utils.Fail(c, err.Error())
+			c.Abort()
+			return
+		}
+		if record != nil {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.StatusCode, "application/json; charset=utf-8", []byte(record.ResponseBody))
+			c.Abort()
+			return
+		}
+
+		// 3. 执行业务逻辑并保存响应（处理器异常时释放记录，允许客户端重试）
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+		completed := false
+		defer func() {
+			if !completed {
+				idem.Release(userId.(uint64), key)
+			}
+		}()
+
+		c.Next()
+
+		if c.Writer.Status() < 500 && recorder.body.Len() > 0 {
+			if err := idem.Complete(userId.(uint64), key, c.Writer.Status(), recorder.body.String()); err == nil {
+				completed = true
+			}
+		}
+	}
+}
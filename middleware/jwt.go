@@ -12,45 +12,19 @@ import (
 // JwtAuth JWT认证中间件
 func JwtAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 获取请求头中的Authorization
-		authHeader := c.Request.Header.Get("Authorization")
-		if authHeader == "" {
-			utils.Unauthorized(c, "请先登录")
-			c.Abort()
-			return
-		}
-
-		// 校验格式：Bearer xxx
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			utils.Unauthorized(c, "token格式错误")
-			c.Abort()
-			return
-		}
-
-		// 解析token
-		tokenStr := parts[1]
-		claims, err := utils.ParseToken(tokenStr, conf.AppConfig.Jwt.Secret)
-		if err != nil {
-			utils.Unauthorized(c, "token已过期或无效")
-			c.Abort()
+		if !parseToken(c) {
 			return
 		}
 
-		// 将用户信息存入上下文
-		c.Set("user_id", claims.UserID)
-		c.Set("user_type", claims.UserType)
-
 		c.Next()
 	}
 }
 
-// JwtAuthByRole 按角色认证（如：仅患者/仅陪诊师）
+// JwtAuthByRole 按角色认证（如：仅患者/仅陪诊师/仅管理员）
 func JwtAuthByRole(role int) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 先执行通用JWT认证
-		JwtAuth()(c)
-		if c.IsAborted() {
+		// 未经过通用JWT认证时先解析token（不能直接调用 JwtAuth()(c)，其内部的 c.Next() 会在角色校验前执行后续处理器）
+		if _, exists := c.Get("user_type"); !exists && !parseToken(c) {
 			return
 		}
 
@@ -65,3 +39,36 @@ func JwtAuthByRole(role int) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// parseToken 解析请求头中的token并将用户信息存入上下文（失败时写入响应、中止请求并返回false）
+func parseToken(c *gin.Context) bool {
+	// 获取请求头中的Authorization
+	authHeader := c.Request.Header.Get("Authorization")
+	if authHeader == "" {
+		utils.Unauthorized(c, "请先登录")
+		c.Abort()
+		return false
+	}
+
+	// 校验格式：Bearer xxx
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		utils.Unauthorized(c, "token格式错误")
+		c.Abort()
+		return false
+	}
+
+	// 解析token
+	tokenStr := parts[1]
+	claims, err := utils.ParseToken(tokenStr, conf.AppConfig.Jwt.Secret)
+	if err != nil {
+		utils.Unauthorized(c, "token已过期或无效")
+		c.Abort()
+		return false
+	}
+
+	// 将用户信息存入上下文
+	c.Set("user_id", claims.UserID)
+	c.Set("user_type", claims.UserType)
+	return true
+}
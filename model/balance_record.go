@@ -2,18 +2,21 @@ package model
 
 import (
 	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
 )
 
 // BalanceRecord 余额明细实体（对应数据库表：balance_records）
+// 自账本上线后不再写入新数据，仅保留历史明细；余额明细接口由账本分录行生成（见 LedgerPosting），并合并本表的历史明细
 type BalanceRecord struct {
-	ID          uint64    `gorm:"primary_key;auto_increment" json:"id"`
-	SerialNo    string    `gorm:"type:varchar(32);unique_index;not null" json:"serial_no"` // 明细编号（唯一）
-	CompanionId uint64    `gorm:"not null" json:"companion_id"`                            // 陪诊师ID（仅陪诊师有余额）
-	Type        int       `gorm:"type:tinyint;not null;comment:'1-服务收入，2-提现成功，3-提现失败'" json:"type"`
-	Amount      float64   `gorm:"type:decimal(10,2);not null" json:"amount"`            // 金额（收入为正，提现为负）
-	Remark      string    `gorm:"type:varchar(255);default:''" json:"remark"`           // 明细备注（如“订单XXX收入”“提现至微信”）
-	CreateTime  time.Time `gorm:"autoCreateTime;column:create_time" json:"create_time"` // 发生时间（字段名与SQL一致）
-	DeletedAt   time.Time `gorm:"soft_delete;index" json:"-"`                           // GORM v1 软删除配置
+	ID          uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	SerialNo    string      `gorm:"type:varchar(32);unique_index;not null" json:"serial_no"` // 明细编号（唯一）
+	CompanionId uint64      `gorm:"not null" json:"companion_id"`                            // 账户所属用户ID（陪诊师收支；患者仅有违约金扣款）
+	Type        int         `gorm:"type:tinyint;not null;comment:'1-服务收入，2-提现成功，3-提现失败，4-提现中，5-取消违约金，6-取消补偿，7-退款，8-期初余额，9-打赏，10-余额调整'" json:"type"`
+	Amount      utils.Money `gorm:"type:decimal(10,2);not null" json:"amount"`            // 金额（收入为正，提现为负）
+	Remark      string      `gorm:"type:varchar(255);default:''" json:"remark"`           // 明细备注（如“订单XXX收入”“提现至微信”）
+	CreateTime  time.Time   `gorm:"autoCreateTime;column:create_time" json:"create_time"` // 发生时间（字段名与SQL一致）
+	DeletedAt   time.Time   `gorm:"soft_delete;index" json:"-"`                           // GORM v1 软删除配置
 }
 
 // TableName 指定余额明细表名
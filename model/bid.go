@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// Bid 竞价报价实体（对应数据库表：bids）
+type Bid struct {
+	ID          uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	DemandId    uint64      `gorm:"not null;unique_index:idx_bid_demand_companion" json:"demand_id"`    // 需求ID
+	CompanionId uint64      `gorm:"not null;unique_index:idx_bid_demand_companion" json:"companion_id"` // 报价陪诊师ID（同一需求每位陪诊师一条报价）
+	Price       utils.Money `gorm:"type:decimal(10,2);not null" json:"price"`                           // 报价金额
+	Message     string      `gorm:"type:varchar(255);default:''" json:"message"`                        // 报价留言
+	Status      int         `gorm:"type:tinyint;default:0;comment:'0-待选择，1-已中标，2-未中标，3-已撤回，4-已失效'" json:"status"`
+	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定竞价报价表名
+func (b *Bid) TableName() string {
+	return "bids"
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// CommissionRule 平台佣金规则（对应数据库表：commission_rules）
+// 匹配条件为空（或0）表示不限；同时命中多条规则时取优先级最高的一条，优先级相同取最新创建的
+type CommissionRule struct {
+	ID           uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	Name         string      `gorm:"type:varchar(32);not null" json:"name"`             // 规则名称（如「北京地区」「金牌陪诊师」「春节活动」）
+	City         string      `gorm:"type:varchar(32);default:''" json:"city"`           // 城市（医院地址包含该城市名称时命中）
+	Hospital     string      `gorm:"type:varchar(100);default:''" json:"hospital"`      // 医院（与需求就诊医院完全一致时命中）
+	MinScore     float64     `gorm:"type:decimal(3,2);default:0" json:"min_score"`      // 陪诊师最低平均评分（1-5星，按陪诊师等级区分费率）
+	MinEvalCount int         `gorm:"type:int;default:0" json:"min_eval_count"`          // 陪诊师最少收到的评价数（与最低评分配合，避免评价过少时误判）
+	MinAmount    utils.Money `gorm:"type:decimal(10,2);default:0.00" json:"min_amount"` // 订单金额下限（含）
+	MaxAmount    utils.Money `gorm:"type:decimal(10,2);default:0.00" json:"max_amount"` // 订单金额上限（不含，0表示不限）
+	StartAt      *time.Time  `json:"start_at"`                                          // 生效开始时间（活动规则使用，为空表示立即生效）
+	EndAt        *time.Time  `json:"end_at"`                                            // 生效结束时间（为空表示长期有效）
+	Rate         float64     `gorm:"type:decimal(5,4);not null" json:"rate"`            // 佣金比例（按订单金额计算，0~1）
+	Priority     int         `gorm:"type:int;default:0;index" json:"priority"`          // 优先级（数值越大越优先）
+	Status       int         `gorm:"type:tinyint;default:1;comment:'0-停用，1-启用'" json:"status"`
+	AdminId      uint64      `gorm:"default:0" json:"admin_id"` // 最后修改的管理员ID
+	CreatedAt    time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt    time.Time   `gorm:"soft_delete;index" json:"-"` // GORM v1 软删除配置
+}
+
+// TableName 指定佣金规则表名
+func (c *CommissionRule) TableName() string {
+	return "commission_rules"
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// CouponTemplate 优惠券模板（对应数据库表：coupon_templates），由管理员创建，按模板发放或由患者在活动期间领取
+type CouponTemplate struct {
+	ID           uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	Name         string      `gorm:"type:varchar(32);not null" json:"name"`                            // 优惠券名称
+	Type         int         `gorm:"type:tinyint;not null;comment:'1-立减券，2-折扣券，3-满减券'" json:"type"`    // 优惠类型
+	Amount       utils.Money `gorm:"type:decimal(10,2);default:0.00" json:"amount"`                    // 优惠金额（立减券、满减券有效）
+	Rate         float64     `gorm:"type:decimal(5,4);default:0" json:"rate"`                          // 优惠比例（折扣券有效，如0.2表示减免订单金额的20%）
+	MaxDiscount  utils.Money `gorm:"type:decimal(10,2);default:0.00" json:"max_discount"`              // 折扣券优惠上限（0表示不限）
+	MinAmount    utils.Money `gorm:"type:decimal(10,2);default:0.00" json:"min_amount"`                // 使用门槛（订单金额不低于该金额时可用，满减券必填）
+	City         string      `gorm:"type:varchar(32);default:''" json:"city"`                          // 限定城市（医院地址包含该城市名称时可用，为空不限）
+	Hospital     string      `gorm:"type:varchar(100);default:''" json:"hospital"`                     // 限定医院（与需求就诊医院一致时可用，为空不限）
+	IssueType    int         `gorm:"type:tinyint;not null;comment:'1-管理员发放，2-活动领取'" json:"issue_type"` // 发放方式
+	ClaimStartAt *time.Time  `json:"claim_start_at"`                                                   // 活动领取开始时间（活动领取有效，为空表示立即开始）
+	ClaimEndAt   *time.Time  `json:"claim_end_at"`                                                     // 活动领取结束时间（活动领取有效，为空表示长期）
+	ValidStartAt *time.Time  `json:"valid_start_at"`                                                   // 使用有效期开始时间（为空表示发放后立即可用）
+	ValidEndAt   *time.Time  `json:"valid_end_at"`                                                     // 使用有效期结束时间（为空时按领取后有效天数计算）
+	ValidDays    int         `gorm:"type:int;default:0" json:"valid_days"`                             // 领取后有效天数（与有效期结束时间同时设置时取较早者，0表示不限）
+	TotalCount   int         `gorm:"type:int;default:0" json:"total_count"`                            // 发放总量（0表示不限）
+	IssuedCount  int         `gorm:"type:int;default:0" json:"issued_count"`                           // 已发放数量
+	PerUserLimit int         `gorm:"type:int;default:1" json:"per_user_limit"`                         // 每位患者最多持有张数（0表示不限）
+	Status       int         `gorm:"type:tinyint;default:1;comment:'0-停用，1-启用'" json:"status"`         // 停用后不可再发放或领取，已发放的券不受影响
+	AdminId      uint64      `gorm:"default:0" json:"admin_id"`                                        // 创建的管理员ID
+	CreatedAt    time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt    time.Time   `gorm:"soft_delete;index" json:"-"` // GORM v1 软删除配置
+}
+
+// TableName 指定优惠券模板表名
+func (c *CouponTemplate) TableName() string {
+	return "coupon_templates"
+}
+
+// UserCoupon 患者持有的优惠券（对应数据库表：user_coupons）
+type UserCoupon struct {
+	ID         uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	TemplateId uint64      `gorm:"not null;index" json:"template_id"`                                      // 优惠券模板ID
+	PatientId  uint64      `gorm:"not null;index" json:"patient_id"`                                       // 持有患者ID
+	Source     int         `gorm:"type:tinyint;not null;comment:'1-管理员发放，2-活动领取'" json:"source"`           // 获得方式
+	Status     int         `gorm:"type:tinyint;default:0;index;comment:'0-未使用，1-已锁定，2-已使用'" json:"status"` // 已锁定：已用于待支付订单，订单支付后变为已使用，未支付取消时退回
+	ValidFrom  *time.Time  `json:"valid_from"`                                                             // 可用开始时间（为空表示立即可用）
+	ValidUntil *time.Time  `json:"valid_until"`                                                            // 过期时间（为空表示长期有效）
+	OrderId    uint64      `gorm:"default:0" json:"order_id"`                                              // 使用的订单ID
+	Discount   utils.Money `gorm:"type:decimal(10,2);default:0.00" json:"discount"`                        // 实际优惠金额
+	UsedAt     *time.Time  `json:"used_at"`                                                                // 使用时间（订单支付成功时间）
+	CreatedAt  time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定患者优惠券表名
+func (u *UserCoupon) TableName() string {
+	return "user_coupons"
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// Dispute 订单争议实体（对应数据库表：disputes）
+type Dispute struct {
+	ID              uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	OrderId         uint64      `gorm:"not null;index" json:"order_id"`                                   // 关联订单ID
+	InitiatorId     uint64      `gorm:"not null" json:"initiator_id"`                                     // 发起人ID
+	InitiatorRole   int         `gorm:"type:tinyint;not null;comment:'1-患者，2-陪诊师'" json:"initiator_role"` // 发起人角色
+	OrderStatus     int         `gorm:"type:tinyint;not null" json:"order_status"`                        // 发起时的订单状态（2-服务中，3-待结算）
+	Description     string      `gorm:"type:varchar(500);not null" json:"description"`                    // 争议描述
+	EvidenceUrls    string      `gorm:"type:varchar(1024);default:''" json:"evidence_urls"`               // 证据图片地址（逗号分隔）
+	Status          int         `gorm:"type:tinyint;default:0;comment:'0-待裁决，1-已裁决'" json:"status"`
+	Result          int         `gorm:"type:tinyint;default:0;comment:'0-未裁决，1-全额结算，2-部分结算，3-全额退款'" json:"result"`
+	PayoutRate      float64     `gorm:"type:decimal(5,4);default:0" json:"payout_rate"`          // 结算比例（部分结算时有效）
+	CompanionAmount utils.Money `gorm:"type:decimal(10,2);default:0.00" json:"companion_amount"` // 陪诊师入账金额
+	RefundAmount    utils.Money `gorm:"type:decimal(10,2);default:0.00" json:"refund_amount"`    // 退还金额（按订单金额计算，使用优惠券的订单其中优惠部分退回平台，其余退还患者）
+	AdminId         uint64      `gorm:"default:0" json:"admin_id"`                               // 裁决管理员ID
+	ResolveRemark   string      `gorm:"type:varchar(255);default:''" json:"resolve_remark"`      // 裁决说明
+	ResolvedAt      *time.Time  `json:"resolved_at"`                                             // 裁决时间
+	CreatedAt       time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt       time.Time   `gorm:"soft_delete;index" json:"-"` // GORM v1 软删除配置
+}
+
+// TableName 指定争议表名
+func (d *Dispute) TableName() string {
+	return "disputes"
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+)
+
+// IdempotencyRecord 幂等请求记录（对应数据库表：idempotency_records）
+type IdempotencyRecord struct {
+	ID           uint64    `gorm:"primary_key;auto_increment" json:"id"`
+	UserId       uint64    `gorm:"not null;unique_index:idx_idem_user_key" json:"user_id"`                   // 请求用户ID
+	IdemKey      string    `gorm:"type:varchar(64);not null;unique_index:idx_idem_user_key" json:"idem_key"` // 客户端传入的 Idempotency-Key
+	Method       string    `gorm:"type:varchar(8);not null" json:"method"`                                   // 请求方法
+	Path         string    `gorm:"type:varchar(255);not null" json:"path"`                                   // 请求路径
+	RequestHash  string    `gorm:"type:char(64);not null" json:"request_hash"`                               // 请求摘要（方法+路径+请求体的SHA-256）
+	Status       int       `gorm:"type:tinyint;default:0;comment:'0-处理中，1-已完成'" json:"status"`
+	StatusCode   int       `gorm:"default:0" json:"status_code"`    // 原始响应HTTP状态码
+	ResponseBody string    `gorm:"type:text" json:"response_body"`  // 原始响应内容（重复请求时原样返回）
+	ExpireAt     time.Time `gorm:"not null;index" json:"expire_at"` // 过期时间（过期后同一Key可重新使用）
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定幂等记录表名
+func (r *IdempotencyRecord) TableName() string {
+	return "idempotency_records"
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// IncomeFreeze 冻结收入（对应数据库表：income_freezes），服务收入结算后先计入冻结余额，到期后转入可用余额
+type IncomeFreeze struct {
+	ID          uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	CompanionId uint64      `gorm:"not null;index" json:"companion_id"`              // 陪诊师ID
+	OrderNo     string      `gorm:"type:varchar(32);not null;index" json:"order_no"` // 关联订单编号
+	Amount      utils.Money `gorm:"type:decimal(10,2);not null" json:"amount"`       // 冻结金额
+	ReleaseAt   time.Time   `gorm:"not null;index" json:"release_at"`                // 预计解冻时间
+	ReleasedAt  *time.Time  `json:"released_at"`                                     // 实际解冻时间（未解冻为空，用于防止重复解冻）
+	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定冻结收入表名
+func (f *IncomeFreeze) TableName() string {
+	return "income_freezes"
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// LedgerAccount 账本账户（对应数据库表：ledger_accounts）
+// 平台账户的 OwnerId 为0；用户账户的 OwnerId 为用户ID
+type LedgerAccount struct {
+	ID          uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	AccountType string      `gorm:"type:varchar(32);not null;unique_index:idx_ledger_account" json:"account_type"` // 账户类型（见 service 层账户类型常量）
+	OwnerId     uint64      `gorm:"not null;default:0;unique_index:idx_ledger_account" json:"owner_id"`            // 账户所属用户ID（平台账户为0）
+	Balance     utils.Money `gorm:"type:decimal(12,2);not null;default:0.00" json:"balance"`                       // 账户余额（等于该账户全部分录行金额之和）
+	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定账本账户表名
+func (a *LedgerAccount) TableName() string {
+	return "ledger_accounts"
+}
+
+// LedgerEntry 记账凭证（对应数据库表：ledger_entries），一笔资金变动对应一张凭证，凭证下各分录行金额之和为0
+type LedgerEntry struct {
+	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
+	EntryNo   string    `gorm:"type:varchar(32);unique_index;not null" json:"entry_no"` // 凭证编号（唯一，即明细编号）
+	BizType   string    `gorm:"type:varchar(32);not null;index" json:"biz_type"`        // 业务类型（订单结算、提现申请等）
+	BizNo     string    `gorm:"type:varchar(32);default:'';index" json:"biz_no"`        // 业务单号（订单编号、提现编号等）
+	Remark    string    `gorm:"type:varchar(255);default:''" json:"remark"`             // 凭证摘要
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定记账凭证表名
+func (e *LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
+
+// LedgerPosting 分录行（对应数据库表：ledger_postings），金额为正表示账户增加、为负表示减少
+type LedgerPosting struct {
+	ID         uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	EntryId    uint64      `gorm:"not null;index" json:"entry_id"`                                                                   // 所属凭证ID
+	AccountId  uint64      `gorm:"not null;index" json:"account_id"`                                                                 // 账户ID
+	Amount     utils.Money `gorm:"type:decimal(12,2);not null" json:"amount"`                                                        // 变动金额（增加为正，减少为负）
+	RecordType int         `gorm:"type:tinyint;not null;default:0;comment:'对用户展示的明细类型，取值同 balance_records.type'" json:"record_type"` // 明细类型（平台账户为0）
+	CreatedAt  time.Time   `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定分录行表名
+func (p *LedgerPosting) TableName() string {
+	return "ledger_postings"
+}
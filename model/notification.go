@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+// Notification 站内通知实体（对应数据库表：notifications）
+type Notification struct {
+	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
+	UserId    uint64    `gorm:"not null;index" json:"user_id"`                             // 接收人ID
+	Type      string    `gorm:"type:varchar(32);not null" json:"type"`                     // 通知类型（如 demand_expired）
+	Title     string    `gorm:"type:varchar(64);not null" json:"title"`                    // 通知标题
+	Content   string    `gorm:"type:varchar(512);default:''" json:"content"`               // 通知内容
+	RelateId  uint64    `gorm:"default:0" json:"relate_id"`                                // 关联业务ID（如需求ID/订单ID）
+	IsRead    int       `gorm:"type:tinyint;default:0;comment:'0-未读，1-已读'" json:"is_read"` // 是否已读
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定站内通知表名
+func (n *Notification) TableName() string {
+	return "notifications"
+}
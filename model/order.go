@@ -2,23 +2,44 @@ package model
 
 import (
 	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
 )
 
 // Order 订单实体（对应数据库表：orders）
 type Order struct {
-	ID               uint64    `gorm:"primary_key;auto_increment" json:"id"`
-	OrderNo          string    `gorm:"type:varchar(32);unique_index;not null" json:"order_no"` // 订单编号（唯一）
-	DemandId         uint64    `gorm:"not null" json:"demand_id"`                              // 关联需求ID
-	PatientId        uint64    `gorm:"not null" json:"patient_id"`                             // 患者ID
-	CompanionId      uint64    `gorm:"not null" json:"companion_id"`                           // 陪诊师ID
-	OrderAmount      float64   `gorm:"type:decimal(10,2);not null" json:"order_amount"`        // 订单金额（与需求期望价格一致）
-	CompanionIncome  float64   `gorm:"type:decimal(10,2);not null" json:"companion_income"`    // 陪诊师实际收入（扣除佣金后）
-	Status           int       `gorm:"type:tinyint;default:1;comment:'1-待服务，2-服务中，3-待结算，4-已完成，5-已取消'" json:"status"`
-	HasPatientEval   int       `gorm:"type:tinyint;default:0;comment:'0-未评价，1-已评价'" json:"has_patient_eval"`   // 患者是否评价
-	HasCompanionEval int       `gorm:"type:tinyint;default:0;comment:'0-未评价，1-已评价'" json:"has_companion_eval"` // 陪诊师是否评价
-	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt        time.Time `gorm:"soft_delete;index" json:"-"` // GORM v1 软删除配置
+	ID                 uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	OrderNo            string      `gorm:"type:varchar(32);unique_index;not null" json:"order_no"` // 订单编号（唯一）
+	DemandId           uint64      `gorm:"not null" json:"demand_id"`                              // 关联需求ID
+	PatientId          uint64      `gorm:"not null" json:"patient_id"`                             // 患者ID
+	CompanionId        uint64      `gorm:"not null" json:"companion_id"`                           // 陪诊师ID
+	OrderAmount        utils.Money `gorm:"type:decimal(10,2);not null" json:"order_amount"`        // 订单金额（与需求期望价格一致）
+	CompanionIncome    utils.Money `gorm:"type:decimal(10,2);not null" json:"companion_income"`    // 陪诊师实际收入（扣除佣金后）
+	CommissionRuleId   uint64      `gorm:"default:0" json:"commission_rule_id"`                    // 接单时命中的佣金规则ID（0表示未命中规则，按默认比例）
+	CommissionRate     float64     `gorm:"type:decimal(5,4);default:0" json:"commission_rate"`     // 接单时适用的佣金比例快照（规则后续修改不影响已生成订单）
+	CouponId           uint64      `gorm:"default:0" json:"coupon_id"`                             // 使用的患者优惠券ID（0表示未使用）
+	DiscountAmount     utils.Money `gorm:"type:decimal(10,2);default:0.00" json:"discount_amount"` // 优惠金额（由平台承担，患者实付 = 订单金额 - 优惠金额，陪诊师收入不受影响）
+	Status             int         `gorm:"type:tinyint;default:1;comment:'1-待服务，2-服务中，3-待结算，4-已完成，5-已取消，6-待确认，7-争议中，8-待支付'" json:"status"`
+	HasPatientEval     int         `gorm:"type:tinyint;default:0;comment:'0-未评价，1-已评价'" json:"has_patient_eval"`   // 患者是否评价
+	HasCompanionEval   int         `gorm:"type:tinyint;default:0;comment:'0-未评价，1-已评价'" json:"has_companion_eval"` // 陪诊师是否评价
+	ApproveDeadline    *time.Time  `json:"approve_deadline"`                                                       // 患者确认截止时间（待确认状态有效）
+	PayDeadline        *time.Time  `json:"pay_deadline"`                                                           // 患者支付截止时间（待支付状态有效）
+	PaidAt             *time.Time  `json:"paid_at"`                                                                // 患者预付完成时间（订单金额由平台托管，为空表示未经预付的历史订单）
+	CheckinCode        string      `gorm:"type:varchar(8);default:''" json:"-"`                                    // 签到码（患者出示给陪诊师，使用后作废）
+	CheckinFailCount   int         `gorm:"default:0" json:"-"`                                                     // 签到码连续错误次数（达到上限后锁定签到）
+	CheckinLockedUntil *time.Time  `json:"-"`                                                                      // 签到码错误次数过多时的锁定截止时间
+	CheckinAt          *time.Time  `json:"checkin_at"`                                                             // 陪诊师签到时间
+	CheckinLat         float64     `gorm:"type:decimal(10,6);default:0" json:"checkin_lat"`                        // 签到纬度
+	CheckinLng         float64     `gorm:"type:decimal(10,6);default:0" json:"checkin_lng"`                        // 签到经度
+	FinishAt           *time.Time  `json:"finish_at"`                                                              // 陪诊师确认完成时间（进入待结算）
+	CreatedAt          time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt          time.Time   `gorm:"soft_delete;index" json:"-"` // GORM v1 软删除配置
+}
+
+// PayAmount 患者实付金额（订单金额扣除优惠）
+func (o *Order) PayAmount() utils.Money {
+	return o.OrderAmount - o.DiscountAmount
 }
 
 // TableName 指定订单表名
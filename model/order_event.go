@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+)
+
+// OrderEvent 订单流转事件实体（对应数据库表：order_events）
+type OrderEvent struct {
+	ID         uint64    `gorm:"primary_key;auto_increment" json:"id"`
+	OrderId    uint64    `gorm:"not null;index" json:"order_id"`                                    // 订单ID
+	Event      string    `gorm:"type:varchar(32);not null" json:"event"`                            // 事件标识（如 patient_cancel）
+	EventName  string    `gorm:"type:varchar(32);not null" json:"event_name"`                       // 事件名称（如 患者取消订单）
+	FromStatus int       `gorm:"type:tinyint;not null" json:"from_status"`                          // 流转前订单状态
+	ToStatus   int       `gorm:"type:tinyint;not null" json:"to_status"`                            // 流转后订单状态
+	ActorId    uint64    `gorm:"not null;default:0" json:"actor_id"`                                // 操作人ID（系统操作为0）
+	ActorRole  int       `gorm:"type:tinyint;not null;comment:'1-患者，2-陪诊师，9-系统'" json:"actor_role"` // 操作人角色
+	Reason     string    `gorm:"type:varchar(255);default:''" json:"reason"`                        // 操作原因（如取消原因）
+	ClientIp   string    `gorm:"type:varchar(64);default:''" json:"client_ip"`                      // 操作人客户端IP
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`                                  // 发生时间
+}
+
+// TableName 指定订单流转事件表名
+func (e *OrderEvent) TableName() string {
+	return "order_events"
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// Payment 支付单实体（对应数据库表：payments），一笔订单可能有多张支付单（如重新发起支付），至多一张支付成功；
+// 打赏支付单的 OrderId 为0，通过 TipId 关联打赏
+type Payment struct {
+	ID        uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	PaymentNo string      `gorm:"type:varchar(32);unique_index;not null" json:"payment_no"`                       // 平台支付单号（唯一）
+	OrderId   uint64      `gorm:"not null;index" json:"order_id"`                                                 // 关联订单ID（打赏支付单为0）
+	TipId     uint64      `gorm:"default:0;index" json:"tip_id"`                                                  // 关联打赏ID（订单支付单为0）
+	PatientId uint64      `gorm:"not null;index" json:"patient_id"`                                               // 付款患者ID
+	Amount    utils.Money `gorm:"type:decimal(10,2);not null" json:"amount"`                                      // 支付金额
+	Provider  string      `gorm:"type:varchar(32);not null" json:"provider"`                                      // 支付渠道
+	TradeNo   string      `gorm:"type:varchar(64);default:''" json:"trade_no"`                                    // 第三方交易号
+	Status    int         `gorm:"type:tinyint;default:0;comment:'0-待支付，1-支付成功，2-已关闭，3-支付成功但订单已关闭'" json:"status"` // 支付状态
+	PaidAt    *time.Time  `json:"paid_at"`                                                                        // 支付成功时间
+	CreatedAt time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定支付单表名
+func (p *Payment) TableName() string {
+	return "payments"
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// PayoutBatch 提现批量打款批次（对应数据库表：payout_batches），每次导出银行批量转账文件生成一条
+type PayoutBatch struct {
+	ID          uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	BatchNo     string      `gorm:"type:varchar(32);unique_index;not null" json:"batch_no"` // 打款批次编号
+	AdminId     uint64      `gorm:"not null" json:"admin_id"`                               // 导出管理员ID
+	Count       int         `gorm:"not null" json:"count"`                                  // 提现笔数
+	TotalAmount utils.Money `gorm:"type:decimal(12,2);not null" json:"total_amount"`        // 打款总额（实际到账金额之和）
+	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定打款批次表名
+func (b *PayoutBatch) TableName() string {
+	return "payout_batches"
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// ReconciliationRun 余额对账批次（对应数据库表：reconciliation_runs），每次对账（定时任务或命令行）生成一条
+type ReconciliationRun struct {
+	ID                   uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	RunNo                string      `gorm:"type:varchar(32);unique_index;not null" json:"run_no"`            // 对账批次编号
+	Trigger              string      `gorm:"type:varchar(16);not null" json:"trigger"`                        // 触发方式（job-定时任务，command-命令行）
+	Fix                  bool        `gorm:"default:false" json:"fix"`                                        // 是否修正不一致的余额
+	Status               int         `gorm:"type:tinyint;default:0;comment:'0-对账中，1-已完成，2-失败'" json:"status"` // 对账状态
+	CheckedCount         int         `gorm:"default:0" json:"checked_count"`                                  // 已核对陪诊师数
+	MismatchCount        int         `gorm:"default:0" json:"mismatch_count"`                                 // 余额不一致的账户数
+	FixedCount           int         `gorm:"default:0" json:"fixed_count"`                                    // 已修正的账户数
+	TrialBalance         utils.Money `gorm:"type:decimal(14,2);default:0" json:"trial_balance"`               // 账本试算平衡：全部分录行金额之和（应为0）
+	UnbalancedEntryCount int         `gorm:"default:0" json:"unbalanced_entry_count"`                         // 借贷不平衡的记账凭证数（应为0）
+	ErrorMsg             string      `gorm:"type:varchar(255);default:''" json:"error_msg"`                   // 对账失败原因
+	StartedAt            time.Time   `json:"started_at"`                                                      // 开始时间
+	FinishedAt           *time.Time  `json:"finished_at"`                                                     // 结束时间
+	CreatedAt            time.Time   `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定对账批次表名
+func (r *ReconciliationRun) TableName() string {
+	return "reconciliation_runs"
+}
+
+// ReconciliationItem 对账差异明细（对应数据库表：reconciliation_items），仅记录余额不一致的陪诊师账户（可用余额或冻结余额）
+// 应有余额为账户全部分录行之和，账户余额（及可用余额对应的 users.balance）为其冗余
+type ReconciliationItem struct {
+	ID              uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	RunId           uint64      `gorm:"not null;index" json:"run_id"`                        // 所属对账批次ID
+	CompanionId     uint64      `gorm:"not null;index" json:"companion_id"`                  // 陪诊师ID
+	AccountType     string      `gorm:"type:varchar(32);not null" json:"account_type"`       // 账户类型（companion_available/companion_frozen）
+	ExpectedBalance utils.Money `gorm:"type:decimal(12,2);not null" json:"expected_balance"` // 应有余额（分录行之和）
+	AccountBalance  utils.Money `gorm:"type:decimal(12,2);not null" json:"account_balance"`  // 账本账户余额
+	UserBalance     utils.Money `gorm:"type:decimal(10,2);not null" json:"user_balance"`     // users.balance（仅可用余额账户）
+	AccountDrift    utils.Money `gorm:"type:decimal(12,2);not null" json:"account_drift"`    // 账户余额差异（账户余额 - 应有余额）
+	UserDrift       utils.Money `gorm:"type:decimal(12,2);not null" json:"user_drift"`       // users.balance 差异（users.balance - 应有余额）
+	PostingCount    int         `gorm:"default:0" json:"posting_count"`                      // 分录行笔数
+	Fixed           bool        `gorm:"default:false" json:"fixed"`                          // 是否已修正
+	AdjustEntryNo   string      `gorm:"type:varchar(32);default:''" json:"adjust_entry_no"`  // 修正时补记的余额调整凭证编号（users.balance 不一致时）
+	CreatedAt       time.Time   `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定对账差异明细表名
+func (i *ReconciliationItem) TableName() string {
+	return "reconciliation_items"
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// Refund 退款单实体（对应数据库表：refunds），按原支付单原路退回
+type Refund struct {
+	ID               uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	RefundNo         string      `gorm:"type:varchar(32);unique_index;not null" json:"refund_no"`                          // 平台退款单号（唯一，重试时沿用，渠道据此保证只退一次）
+	OrderId          uint64      `gorm:"not null;index" json:"order_id"`                                                   // 关联订单ID
+	OrderNo          string      `gorm:"type:varchar(32);not null" json:"order_no"`                                        // 关联订单编号
+	PatientId        uint64      `gorm:"not null;index" json:"patient_id"`                                                 // 收款患者ID
+	PaymentNo        string      `gorm:"type:varchar(32);not null;index" json:"payment_no"`                                // 原支付单号
+	Provider         string      `gorm:"type:varchar(32);not null" json:"provider"`                                        // 支付渠道
+	Amount           utils.Money `gorm:"type:decimal(10,2);not null" json:"amount"`                                        // 退款金额
+	Reason           string      `gorm:"type:varchar(255);default:''" json:"reason"`                                       // 退款原因
+	Status           int         `gorm:"type:tinyint;default:0;index;comment:'0-待退款，1-退款处理中，2-退款成功，3-退款失败'" json:"status"` // 退款状态
+	ProviderRefundNo string      `gorm:"type:varchar(64);default:''" json:"provider_refund_no"`                            // 第三方退款单号
+	Attempts         int         `gorm:"default:0" json:"attempts"`                                                        // 已调用渠道次数
+	NextRetryAt      time.Time   `gorm:"index" json:"next_retry_at"`                                                       // 下次调用渠道时间
+	FailReason       string      `gorm:"type:varchar(255);default:''" json:"fail_reason"`                                  // 最近一次失败原因
+	SucceededAt      *time.Time  `json:"succeeded_at"`                                                                     // 退款成功时间
+	CreatedAt        time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定退款单表名
+func (r *Refund) TableName() string {
+	return "refunds"
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+// RescheduleRequest 改约申请实体（对应数据库表：reschedule_requests）
+type RescheduleRequest struct {
+	ID              uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	OrderId         uint64     `gorm:"not null;index" json:"order_id"`                                  // 订单ID
+	ProposerId      uint64     `gorm:"not null" json:"proposer_id"`                                     // 发起人ID
+	ProposerRole    int        `gorm:"type:tinyint;not null;comment:'1-患者，2-陪诊师'" json:"proposer_role"` // 发起人角色
+	OldServiceTime  time.Time  `gorm:"not null" json:"old_service_time"`                                // 原服务时间
+	NewServiceTime  time.Time  `gorm:"not null" json:"new_service_time"`                                // 新服务时间
+	OldHospitalAddr string     `gorm:"type:varchar(255);default:''" json:"old_hospital_addr"`           // 原医院地址
+	NewHospitalAddr string     `gorm:"type:varchar(255);default:''" json:"new_hospital_addr"`           // 新医院地址（为空表示不变）
+	Reason          string     `gorm:"type:varchar(255);default:''" json:"reason"`                      // 改约原因
+	Status          int        `gorm:"type:tinyint;default:0;comment:'0-待处理，1-已同意，2-已拒绝，3-已撤回，4-已失效'" json:"status"`
+	ResponderId     uint64     `gorm:"default:0" json:"responder_id"`                      // 处理人ID
+	RespondReason   string     `gorm:"type:varchar(255);default:''" json:"respond_reason"` // 处理说明（如拒绝原因）
+	RespondAt       *time.Time `json:"respond_at"`                                         // 处理时间
+	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定改约申请表名
+func (r *RescheduleRequest) TableName() string {
+	return "reschedule_requests"
+}
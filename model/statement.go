@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// IssuedStatement 已签发的收入对账单（对应数据库表：issued_statements），每份校验码不同的对账单首次生成时保存一条
+// 核验时以保存的内容为准，账本事后变动（如余额调整）不影响已签发对账单的核验结果
+type IssuedStatement struct {
+	ID              uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	CompanionId     uint64      `gorm:"not null;unique_index:idx_issued_statement" json:"companion_id"`              // 陪诊师ID
+	Month           string      `gorm:"type:varchar(7);not null;unique_index:idx_issued_statement" json:"month"`     // 对账月份（格式：2006-01）
+	Checksum        string      `gorm:"type:varchar(64);not null;unique_index:idx_issued_statement" json:"checksum"` // 校验码
+	ChecksumVersion int         `gorm:"not null" json:"checksum_version"`                                            // 校验码格式版本
+	PeriodStart     time.Time   `gorm:"not null" json:"period_start"`                                                // 对账期间开始（含）
+	PeriodEnd       time.Time   `gorm:"not null" json:"period_end"`                                                  // 对账期间结束（不含）
+	OpeningBalance  utils.Money `gorm:"type:decimal(12,2);not null" json:"opening_balance"`                          // 期初余额
+	ClosingBalance  utils.Money `gorm:"type:decimal(12,2);not null" json:"closing_balance"`                          // 期末余额
+	TotalIncome     utils.Money `gorm:"type:decimal(12,2);not null" json:"total_income"`                             // 服务收入合计
+	TotalTips       utils.Money `gorm:"type:decimal(12,2);not null" json:"total_tips"`                               // 打赏合计
+	TotalWithdrawn  utils.Money `gorm:"type:decimal(12,2);not null" json:"total_withdrawn"`                          // 提现合计
+	TotalOther      utils.Money `gorm:"type:decimal(12,2);not null" json:"total_other"`                              // 其他变动合计
+	Content         string      `gorm:"type:mediumtext;not null" json:"-"`                                           // 对账单完整内容（JSON）
+	IssuedAt        time.Time   `gorm:"not null" json:"issued_at"`                                                   // 首次生成时间
+	CreatedAt       time.Time   `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定已签发对账单表名
+func (s *IssuedStatement) TableName() string {
+	return "issued_statements"
+}
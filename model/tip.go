@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// Tip 打赏（对应数据库表：tips），患者在订单完成后向陪诊师打赏，支付成功后全额计入陪诊师可用余额
+type Tip struct {
+	ID          uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	TipNo       string      `gorm:"type:varchar(32);unique_index;not null" json:"tip_no"`       // 打赏编号（唯一）
+	OrderId     uint64      `gorm:"not null;index" json:"order_id"`                             // 关联订单ID
+	OrderNo     string      `gorm:"type:varchar(32);not null" json:"order_no"`                  // 关联订单编号
+	PatientId   uint64      `gorm:"not null;index" json:"patient_id"`                           // 打赏患者ID
+	CompanionId uint64      `gorm:"not null;index" json:"companion_id"`                         // 受赏陪诊师ID
+	Amount      utils.Money `gorm:"type:decimal(10,2);not null" json:"amount"`                  // 打赏金额
+	Message     string      `gorm:"type:varchar(100);default:''" json:"message"`                // 打赏留言
+	PaymentNo   string      `gorm:"type:varchar(32);default:''" json:"payment_no"`              // 支付单号
+	Status      int         `gorm:"type:tinyint;default:0;comment:'0-待支付，1-已支付'" json:"status"` // 打赏状态
+	PaidAt      *time.Time  `json:"paid_at"`                                                    // 支付成功时间
+	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定打赏表名
+func (t *Tip) TableName() string {
+	return "tips"
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// Withdrawal 提现单（对应数据库表：withdrawals）
+type Withdrawal struct {
+	ID           uint64      `gorm:"primary_key;auto_increment" json:"id"`
+	SerialNo     string      `gorm:"type:varchar(32);unique_index;not null" json:"serial_no"`                 // 提现编号（与提现记账凭证编号一致）
+	CompanionId  uint64      `gorm:"not null;index" json:"companion_id"`                                      // 陪诊师ID
+	Amount       utils.Money `gorm:"type:decimal(10,2);not null" json:"amount"`                               // 提现金额（从可用余额扣除的金额）
+	Fee          utils.Money `gorm:"type:decimal(10,2);not null;default:0.00" json:"fee"`                     // 手续费（打款成功时归平台，提现失败时随本金退回）
+	ActualAmount utils.Money `gorm:"type:decimal(10,2);not null" json:"actual_amount"`                        // 实际到账金额（提现金额 - 手续费）
+	Account      string      `gorm:"type:varchar(64);not null" json:"account"`                                // 收款账号
+	RealName     string      `gorm:"type:varchar(32);not null" json:"real_name"`                              // 收款人姓名
+	Status       int         `gorm:"type:tinyint;not null;index;comment:'2-提现成功，3-提现失败，4-提现中'" json:"status"` // 提现状态（取值同 balance_records.type）
+	ApprovedAt   *time.Time  `json:"approved_at"`                                                             // 审核通过时间（审核通过后方可导出批量打款）
+	BatchNo      string      `gorm:"type:varchar(32);default:'';index" json:"batch_no"`                       // 打款批次编号（导出银行批量转账文件后记录，未导出为空）
+	AdminId      uint64      `gorm:"default:0" json:"admin_id"`                                               // 审核管理员ID（支付回调确认时为0）
+	Remark       string      `gorm:"type:varchar(255);default:''" json:"remark"`                              // 审核说明（驳回时为驳回原因）
+	SettledAt    *time.Time  `json:"settled_at"`                                                              // 资金结清时间（打款确认或失败退回后记录，用于防止重复处理）
+	CreatedAt    time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定提现单表名
+func (w *Withdrawal) TableName() string {
+	return "withdrawals"
+}
@@ -0,0 +1,124 @@
+// payment/mock.go
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// MockSignatureHeader 模拟渠道回调签名请求头
+const MockSignatureHeader = "X-Mock-Signature"
+
+// mockPayment 模拟渠道内部的支付单
+type mockPayment struct {
+	tradeNo string
+	amount  utils.Money
+	status  Status
+}
+
+// mockCallback 模拟渠道回调报文
+type mockCallback struct {
+	PaymentNo string      `json:"payment_no"`
+	TradeNo   string      `json:"trade_no"`
+	Amount    utils.Money `json:"amount"`
+	Status    Status      `json:"status"`
+}
+
+// MockProvider 模拟支付渠道（仅用于本地开发联调，支付单保存在内存中，服务重启后丢失）：
+// 创建支付单时返回已签名的回调报文，客户端将其原样 POST 到回调地址即模拟支付成功；
+// 配置 auto_paid 时主动查询直接返回支付成功。仅在配置 payment.mock.enabled 开启时可用（见 Get）
+type MockProvider struct {
+	mu       sync.Mutex
+	payments map[string]*mockPayment
+}
+
+func init() {
+	Register(&MockProvider{payments: map[string]*mockPayment{}})
+}
+
+// Name 渠道名称
+func (m *MockProvider) Name() string {
+	return "mock"
+}
+
+// CreatePayment 创建模拟支付单，返回可直接回调的已签名报文
+func (m *MockProvider) CreatePayment(req CreateRequest) (*CreateResult, error) {
+	tradeNo := "MOCK" + req.PaymentNo
+	m.mu.Lock()
+	m.payments[req.PaymentNo] = &mockPayment{tradeNo: tradeNo, amount: req.Amount, status: StatusPending}
+	m.mu.Unlock()
+
+	body, err := json.Marshal(mockCallback{PaymentNo: req.PaymentNo, TradeNo: tradeNo, Amount: req.Amount, Status: StatusPaid})
+	if err != nil {
+		return nil, errors.New("生成模拟回调报文失败")
+	}
+	return &CreateResult{
+		TradeNo: tradeNo,
+		PayParams: map[string]string{
+			"notify_url":     req.NotifyUrl,
+			"mock_callback":  string(body),
+			"mock_signature": m.sign(body),
+		},
+	}, nil
+}
+
+// QueryPayment 查询模拟支付单状态
+func (m *MockProvider) QueryPayment(paymentNo string) (*QueryResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.payments[paymentNo]
+	if !ok {
+		return nil, errors.New("模拟渠道支付单不存在")
+	}
+	if conf.AppConfig.Payment.Mock.AutoPaid && p.status == StatusPending {
+		p.status = StatusPaid
+	}
+	return &QueryResult{PaymentNo: paymentNo, TradeNo: p.tradeNo, Status: p.status, Amount: p.amount}, nil
+}
+
+// Refund 模拟退款（立即成功）
+func (m *MockProvider) Refund(req RefundRequest) (*RefundResult, error) {
+	return &RefundResult{RefundNo: req.RefundNo, ProviderRefundNo: "MOCK" + req.RefundNo, Status: RefundSucceeded}, nil
+}
+
+// VerifyCallback 校验模拟回调签名（HMAC-SHA256）并解析报文
+func (m *MockProvider) VerifyCallback(body []byte, header http.Header) (*Notification, error) {
+	signature, err := hex.DecodeString(header.Get(MockSignatureHeader))
+	if err != nil || !hmac.Equal(signature, m.mac(body)) {
+		return nil, errors.New("回调签名校验失败")
+	}
+
+	var callback mockCallback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		return nil, errors.New("回调报文格式错误")
+	}
+
+	m.mu.Lock()
+	if p, ok := m.payments[callback.PaymentNo]; ok {
+		p.status = callback.Status
+	}
+	m.mu.Unlock()
+
+	return &Notification{PaymentNo: callback.PaymentNo, TradeNo: callback.TradeNo, Status: callback.Status, Amount: callback.Amount}, nil
+}
+
+// sign 计算报文签名（十六进制）
+func (m *MockProvider) sign(body []byte) string {
+	return hex.EncodeToString(m.mac(body))
+}
+
+// mac 计算报文的 HMAC-SHA256
+func (m *MockProvider) mac(body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(conf.AppConfig.Payment.Mock.Secret))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,120 @@
+// payment/provider.go
+package payment
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/utils"
+)
+
+// Status 第三方支付单状态
+type Status int
+
+const (
+	StatusPending Status = 0 // 待支付
+	StatusPaid    Status = 1 // 支付成功
+	StatusClosed  Status = 2 // 已关闭（超时未支付或已撤销）
+)
+
+// RefundStatus 第三方退款状态
+type RefundStatus int
+
+const (
+	RefundProcessing RefundStatus = 0 // 退款处理中（需稍后查询结果）
+	RefundSucceeded  RefundStatus = 1 // 退款成功
+	RefundFailed     RefundStatus = 2 // 退款失败
+)
+
+// CreateRequest 创建支付请求
+type CreateRequest struct {
+	PaymentNo string      // 平台支付单号（商户订单号）
+	Amount    utils.Money // 支付金额
+	Subject   string      // 支付标题（展示给付款人）
+	NotifyUrl string      // 支付结果回调地址
+	ExpireAt  time.Time   // 支付截止时间
+}
+
+// CreateResult 创建支付结果
+type CreateResult struct {
+	TradeNo   string            // 第三方交易号（部分渠道在支付完成后才返回，可为空）
+	PayParams map[string]string // 客户端拉起支付所需的参数（各渠道不同）
+}
+
+// QueryResult 支付单查询结果
+type QueryResult struct {
+	PaymentNo string      // 平台支付单号
+	TradeNo   string      // 第三方交易号
+	Status    Status      // 支付状态
+	Amount    utils.Money // 实付金额
+}
+
+// RefundRequest 退款请求
+type RefundRequest struct {
+	PaymentNo string      // 原平台支付单号
+	TradeNo   string      // 原第三方交易号
+	RefundNo  string      // 平台退款单号（同一退款单号重复请求，渠道应保证只退一次）
+	Amount    utils.Money // 本次退款金额
+	Total     utils.Money // 原支付金额
+	Reason    string      // 退款原因
+}
+
+// RefundResult 退款结果
+type RefundResult struct {
+	RefundNo         string       // 平台退款单号
+	ProviderRefundNo string       // 第三方退款单号
+	Status           RefundStatus // 退款状态
+	FailReason       string       // 失败原因（退款失败时有效）
+}
+
+// Notification 支付结果回调通知（验签通过后解析得到）
+type Notification struct {
+	PaymentNo string      // 平台支付单号
+	TradeNo   string      // 第三方交易号
+	Status    Status      // 支付状态
+	Amount    utils.Money // 实付金额
+}
+
+// Provider 支付渠道（新增渠道实现该接口并在 init 中调用 Register 注册）
+type Provider interface {
+	// Name 渠道名称（与配置 payment.provider 对应）
+	Name() string
+	// CreatePayment 创建支付单，返回客户端拉起支付的参数
+	CreatePayment(req CreateRequest) (*CreateResult, error)
+	// QueryPayment 主动查询支付状态（回调丢失时兜底）
+	QueryPayment(paymentNo string) (*QueryResult, error)
+	// Refund 发起退款
+	Refund(req RefundRequest) (*RefundResult, error)
+	// VerifyCallback 校验支付回调签名并解析通知内容
+	VerifyCallback(body []byte, header http.Header) (*Notification, error)
+}
+
+// ErrUnknownProvider 未注册的支付渠道
+var ErrUnknownProvider = errors.New("支付渠道不存在或未启用")
+
+// providers 已注册的支付渠道
+var providers = map[string]Provider{}
+
+// Register 注册支付渠道（重复注册同名渠道时后者覆盖前者）
+func Register(p Provider) {
+	providers[p.Name()] = p
+}
+
+// Get 按名称获取支付渠道（模拟渠道仅在配置 payment.mock.enabled 开启时可用，下单、查询与回调均受限）
+func Get(name string) (Provider, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if _, isMock := p.(*MockProvider); isMock && !conf.AppConfig.Payment.Mock.Enabled {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Current 获取当前配置启用的支付渠道
+func Current() (Provider, error) {
+	return Get(conf.AppConfig.Payment.Provider)
+}
@@ -36,6 +36,12 @@ func InitRouter() *gin.Engine {
 			userPublic.GET("/captcha", (&controller.UserController{}).GetCaptcha) // 获取验证码（可选）
 		}
 
+		// 支付渠道回调（由渠道签名校验来源）
+		publicGroup.POST("/payment/callback/:provider", (&controller.PaymentController{}).Callback)
+
+		// 收入对账单校验码核验（供收到对账单的第三方核验真伪）
+		publicGroup.GET("/statement/verify", (&controller.StatementController{}).Verify)
+
 		// 健康检查接口（用于服务监控）
 		publicGroup.GET("/health", func(c *gin.Context) {
 			c.JSON(200, gin.H{
@@ -53,18 +59,21 @@ func InitRouter() *gin.Engine {
 		// -------------------------- 通用用户接口（所有登录用户均可访问） --------------------------
 		userGroup := authGroup.Group("/user")
 		{
-			userGroup.GET("/info", (&controller.UserController{}).GetUserInfo)              // 获取当前用户信息
-			userGroup.POST("/info/update", (&controller.UserController{}).UpdateProfile)    // 修改用户信息
-			userGroup.POST("/password/reset", (&controller.UserController{}).ResetPassword) // 重置密码
-			userGroup.GET("/eval/list", (&controller.EvalController{}).GetUserEvalList)     // 查询用户收到的评价列表
+			userGroup.GET("/info", (&controller.UserController{}).GetUserInfo)                        // 获取当前用户信息
+			userGroup.POST("/info/update", (&controller.UserController{}).UpdateProfile)              // 修改用户信息
+			userGroup.POST("/password/reset", (&controller.UserController{}).ResetPassword)           // 重置密码
+			userGroup.GET("/eval/list", (&controller.EvalController{}).GetUserEvalList)               // 查询用户收到的评价列表
+			userGroup.GET("/notice/list", (&controller.NotificationController{}).GetNotificationList) // 查询站内通知列表
+			userGroup.POST("/notice/read", (&controller.NotificationController{}).MarkRead)           // 标记通知已读
 		}
 
 		// -------------------------- 文件上传接口（所有登录用户均可访问） --------------------------
 		uploadGroup := authGroup.Group("/upload")
 		{
-			uploadGroup.POST("/avatar", (&controller.UploadController{}).UploadAvatar)      // 上传用户头像
-			uploadGroup.POST("/eval/imgs", (&controller.UploadController{}).UploadEvalImgs) // 批量上传评价图片
-			uploadGroup.POST("/file/delete", (&controller.UploadController{}).DeleteFile)   // 删除单个文件
+			uploadGroup.POST("/avatar", (&controller.UploadController{}).UploadAvatar)            // 上传用户头像
+			uploadGroup.POST("/eval/imgs", (&controller.UploadController{}).UploadEvalImgs)       // 批量上传评价图片
+			uploadGroup.POST("/dispute/imgs", (&controller.UploadController{}).UploadDisputeImgs) // 批量上传争议证据图片
+			uploadGroup.POST("/file/delete", (&controller.UploadController{}).DeleteFile)         // 删除单个文件
 		}
 
 		// -------------------------- 患者专属接口 --------------------------
@@ -81,9 +90,42 @@ func InitRouter() *gin.Engine {
 			// 订单相关
 			patientOrder := patientGroup.Group("/order")
 			{
-				patientOrder.GET("/list", (&controller.OrderController{}).GetPatientOrderList)        // 查询我的订单列表
-				patientOrder.POST("/confirm", (&controller.OrderController{}).PatientConfirmComplete) // 确认服务完成
-				patientOrder.POST("/cancel", (&controller.OrderController{}).PatientCancelOrder)      // 取消订单
+				patientOrder.GET("/list", (&controller.OrderController{}).GetPatientOrderList)                                     // 查询我的订单列表
+				patientOrder.POST("/confirm", middleware.Idempotency(), (&controller.OrderController{}).PatientConfirmComplete)    // 确认服务完成
+				patientOrder.POST("/cancel", middleware.Idempotency(), (&controller.OrderController{}).PatientCancelOrder)         // 取消订单
+				patientOrder.GET("/cancel/penalty", (&controller.OrderController{}).GetCancelPenalty)                              // 预估取消违约金
+				patientOrder.POST("/approve", middleware.Idempotency(), (&controller.OrderController{}).PatientApprove)            // 确认陪诊师接单
+				patientOrder.POST("/coupon/apply", middleware.Idempotency(), (&controller.CouponController{}).ApplyOrderCoupon)    // 选用优惠券
+				patientOrder.POST("/pay", middleware.Idempotency(), (&controller.PaymentController{}).Pay)                         // 发起支付
+				patientOrder.GET("/pay/result", (&controller.PaymentController{}).QueryPayResult)                                  // 查询支付结果
+				patientOrder.GET("/refund/list", (&controller.RefundController{}).GetOrderRefundList)                              // 查询退款进度
+				patientOrder.POST("/tip", middleware.Idempotency(), (&controller.TipController{}).Create)                          // 打赏陪诊师
+				patientOrder.GET("/tip/result", (&controller.TipController{}).QueryResult)                                         // 查询打赏支付结果
+				patientOrder.GET("/tip/list", (&controller.TipController{}).GetOrderTipList)                                       // 查询订单打赏记录
+				patientOrder.POST("/reject", middleware.Idempotency(), (&controller.OrderController{}).PatientReject)              // 拒绝陪诊师接单
+				patientOrder.GET("/checkin/code", (&controller.OrderController{}).GetCheckinCode)                                  // 获取签到码
+				patientOrder.GET("/events", (&controller.OrderController{}).GetOrderEvents)                                        // 查询订单流转时间线
+				patientOrder.POST("/reschedule/propose", middleware.Idempotency(), (&controller.RescheduleController{}).Propose)   // 发起改约
+				patientOrder.POST("/reschedule/respond", middleware.Idempotency(), (&controller.RescheduleController{}).Respond)   // 处理改约
+				patientOrder.POST("/reschedule/withdraw", middleware.Idempotency(), (&controller.RescheduleController{}).Withdraw) // 撤回改约
+				patientOrder.GET("/reschedule/list", (&controller.RescheduleController{}).GetList)                                 // 查询改约记录
+				patientOrder.POST("/dispute/open", middleware.Idempotency(), (&controller.DisputeController{}).Open)               // 发起争议
+				patientOrder.GET("/dispute", (&controller.DisputeController{}).GetOrderDispute)                                    // 查询订单争议
+			}
+
+			// 竞价相关
+			patientBid := patientGroup.Group("/bid")
+			{
+				patientBid.GET("/list", (&controller.BidController{}).GetDemandBidList)                    // 查询需求收到的报价
+				patientBid.POST("/accept", middleware.Idempotency(), (&controller.BidController{}).Accept) // 选定报价
+			}
+
+			// 优惠券相关
+			patientCoupon := patientGroup.Group("/coupon")
+			{
+				patientCoupon.GET("/campaign/list", (&controller.CouponController{}).GetCampaignList)          // 查询可领取的活动优惠券
+				patientCoupon.POST("/claim", middleware.Idempotency(), (&controller.CouponController{}).Claim) // 领取活动优惠券
+				patientCoupon.GET("/my/list", (&controller.CouponController{}).GetMyCouponList)                // 查询我的优惠券
 			}
 
 			// 评价相关
@@ -99,19 +141,40 @@ func InitRouter() *gin.Engine {
 			// 订单大厅/接单相关
 			companionOrder := companionGroup.Group("/order")
 			{
-				companionOrder.GET("/hall", (&controller.OrderController{}).GetOrderHall)                 // 查询订单大厅（待接单需求）
-				companionOrder.POST("/take", (&controller.OrderController{}).TakeOrder)                   // 接单操作
-				companionOrder.GET("/list", (&controller.OrderController{}).GetCompanionServiceList)      // 查询我的服务列表
-				companionOrder.POST("/confirm", (&controller.OrderController{}).CompanionConfirmComplete) // 确认服务完成
-				companionOrder.POST("/cancel", (&controller.OrderController{}).CompanionCancelOrder)      // 取消订单
+				companionOrder.GET("/hall", (&controller.OrderController{}).GetOrderHall)                                            // 查询订单大厅（待接单需求）
+				companionOrder.POST("/take", middleware.Idempotency(), (&controller.OrderController{}).TakeOrder)                    // 接单操作
+				companionOrder.GET("/invite/list", (&controller.OrderController{}).GetInviteList)                                    // 查询患者指定给我的邀请
+				companionOrder.POST("/invite/accept", middleware.Idempotency(), (&controller.OrderController{}).AcceptInvite)        // 接受指定邀请
+				companionOrder.POST("/invite/decline", middleware.Idempotency(), (&controller.OrderController{}).DeclineInvite)      // 拒绝指定邀请
+				companionOrder.GET("/list", (&controller.OrderController{}).GetCompanionServiceList)                                 // 查询我的服务列表
+				companionOrder.POST("/confirm", middleware.Idempotency(), (&controller.OrderController{}).CompanionConfirmComplete)  // 确认服务完成
+				companionOrder.POST("/cancel", middleware.Idempotency(), (&controller.OrderController{}).CompanionCancelOrder)       // 取消订单
+				companionOrder.GET("/cancel/penalty", (&controller.OrderController{}).GetCancelPenalty)                              // 预估取消违约金
+				companionOrder.POST("/checkin", middleware.Idempotency(), (&controller.OrderController{}).CompanionCheckIn)          // 到场签到（开始服务）
+				companionOrder.GET("/events", (&controller.OrderController{}).GetOrderEvents)                                        // 查询订单流转时间线
+				companionOrder.POST("/reschedule/propose", middleware.Idempotency(), (&controller.RescheduleController{}).Propose)   // 发起改约
+				companionOrder.POST("/reschedule/respond", middleware.Idempotency(), (&controller.RescheduleController{}).Respond)   // 处理改约
+				companionOrder.POST("/reschedule/withdraw", middleware.Idempotency(), (&controller.RescheduleController{}).Withdraw) // 撤回改约
+				companionOrder.GET("/reschedule/list", (&controller.RescheduleController{}).GetList)                                 // 查询改约记录
+				companionOrder.POST("/dispute/open", middleware.Idempotency(), (&controller.DisputeController{}).Open)               // 发起争议
+				companionOrder.GET("/dispute", (&controller.DisputeController{}).GetOrderDispute)                                    // 查询订单争议
+			}
+
+			// 竞价相关
+			companionBid := companionGroup.Group("/bid")
+			{
+				companionBid.POST("/submit", middleware.Idempotency(), (&controller.BidController{}).Submit)     // 提交报价
+				companionBid.POST("/withdraw", middleware.Idempotency(), (&controller.BidController{}).Withdraw) // 撤回报价
+				companionBid.GET("/my/list", (&controller.BidController{}).GetMyBidList)                         // 查询我的报价列表
 			}
 
 			// 余额相关
 			companionBalance := companionGroup.Group("/balance")
 			{
-				companionBalance.GET("", (&controller.BalanceController{}).GetBalance)                   // 查询账户余额
-				companionBalance.GET("/records", (&controller.BalanceController{}).GetBalanceRecordList) // 查询余额明细
-				companionBalance.POST("/withdraw", (&controller.BalanceController{}).ApplyWithdraw)      // 申请提现
+				companionBalance.GET("", (&controller.BalanceController{}).GetBalance)                                        // 查询账户余额
+				companionBalance.GET("/records", (&controller.BalanceController{}).GetBalanceRecordList)                      // 查询余额明细
+				companionBalance.POST("/withdraw", middleware.Idempotency(), (&controller.BalanceController{}).ApplyWithdraw) // 申请提现
+				companionBalance.GET("/statement", (&controller.StatementController{}).GetStatement)                          // 查询/导出月度收入对账单
 			}
 
 			// 评价相关
@@ -120,6 +183,62 @@ func InitRouter() *gin.Engine {
 				companionEval.POST("/patient", (&controller.EvalController{}).CompanionEvalPatient) // 评价患者
 			}
 		}
+
+		// -------------------------- 管理员专属接口 --------------------------
+		adminGroup := authGroup.Group("/admin")
+		adminGroup.Use(middleware.JwtAuthByRole(3)) // 仅平台管理员（user_type=3）访问
+		{
+			// 争议裁决相关
+			adminDispute := adminGroup.Group("/dispute")
+			{
+				adminDispute.GET("/list", (&controller.DisputeController{}).GetList)                               // 查询争议列表
+				adminDispute.POST("/resolve", middleware.Idempotency(), (&controller.DisputeController{}).Resolve) // 裁决争议
+			}
+
+			// 佣金规则相关
+			adminCommission := adminGroup.Group("/commission")
+			{
+				adminCommission.GET("/list", (&controller.CommissionController{}).GetList)         // 查询佣金规则列表
+				adminCommission.POST("/create", (&controller.CommissionController{}).Create)       // 新增佣金规则
+				adminCommission.POST("/update", (&controller.CommissionController{}).Update)       // 修改佣金规则
+				adminCommission.POST("/status", (&controller.CommissionController{}).UpdateStatus) // 启用/停用佣金规则
+			}
+
+			// 优惠券管理相关
+			adminCoupon := adminGroup.Group("/coupon")
+			{
+				adminCoupon.GET("/list", (&controller.CouponController{}).GetTemplateList)                   // 查询优惠券模板列表
+				adminCoupon.POST("/create", (&controller.CouponController{}).CreateTemplate)                 // 新增优惠券模板
+				adminCoupon.POST("/status", (&controller.CouponController{}).UpdateTemplateStatus)           // 启用/停用优惠券模板
+				adminCoupon.POST("/issue", middleware.Idempotency(), (&controller.CouponController{}).Issue) // 向患者发放优惠券
+			}
+
+			// 收入对账单相关
+			adminGroup.GET("/statement", (&controller.StatementController{}).AdminGetStatement) // 查询/导出陪诊师月度收入对账单
+
+			// 余额对账相关
+			adminGroup.GET("/reconcile/latest", (&controller.ReconciliationController{}).GetLatest) // 查询最近一次余额对账结果
+
+			// 退款管理相关
+			adminRefund := adminGroup.Group("/refund")
+			{
+				adminRefund.GET("/list", (&controller.RefundController{}).GetList)                           // 查询退款单列表
+				adminRefund.POST("/retry", middleware.Idempotency(), (&controller.RefundController{}).Retry) // 重新发起失败的退款
+			}
+
+			// 提现审核相关
+			adminWithdraw := adminGroup.Group("/withdraw")
+			{
+				adminWithdraw.GET("/list", (&controller.BalanceController{}).GetWithdrawList)                               // 查询提现单列表
+				adminWithdraw.POST("/approve", middleware.Idempotency(), (&controller.BalanceController{}).ApproveWithdraw) // 审核通过提现
+				adminWithdraw.POST("/reject", middleware.Idempotency(), (&controller.BalanceController{}).RejectWithdraw)   // 驳回提现
+				// 导出返回文件，幂等中间件只能按 JSON 重放响应，故不使用；重复导出不会重复打款（已进入批次的提现单不会再次导出）
+				adminWithdraw.POST("/payout/export", (&controller.PayoutController{}).Export)                           // 导出银行批量转账文件
+				adminWithdraw.GET("/payout/batch/list", (&controller.PayoutController{}).GetBatchList)                  // 查询打款批次列表
+				adminWithdraw.GET("/payout/batch/file", (&controller.PayoutController{}).GetBatchFile)                  // 重新下载打款批次文件
+				adminWithdraw.POST("/payout/import", middleware.Idempotency(), (&controller.PayoutController{}).Import) // 导入银行打款结果文件
+			}
+		}
 	}
 
 	// 返回Gin引擎
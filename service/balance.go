@@ -3,64 +3,118 @@ package service
 
 import (
 	"errors"
-	"strconv"
+	"time"
 
 	"github.com/X-Colder/companion-backend/model"
 	"github.com/X-Colder/companion-backend/utils"
 	"github.com/jinzhu/gorm"
 )
 
-// BalanceService 余额服务
+// BalanceService 余额服务（余额与明细均由账本派生，见 service/ledger.go）
 type BalanceService struct{}
 
-// GetCompanionBalance 查询陪诊师账户余额
-func (b *BalanceService) GetCompanionBalance(companionId uint64) (float64, error) {
+// BalanceRecordView 余额明细（由用户账户的账本分录行生成，字段与原 balance_records 保持一致；账本上线前的历史明细取自 balance_records）
+type BalanceRecordView struct {
+	ID          uint64      `json:"id"`
+	SerialNo    string      `json:"serial_no"`    // 明细编号（即记账凭证编号）
+	CompanionId uint64      `json:"companion_id"` // 账户所属用户ID
+	Type        int         `json:"type"`         // 明细类型（提现明细展示提现单的当前状态）
+	Amount      utils.Money `json:"amount"`       // 金额（收入为正，支出为负）
+	Remark      string      `json:"remark"`       // 明细备注
+	CreateTime  time.Time   `json:"create_time"`  // 发生时间
+	Legacy      bool        `json:"legacy"`       // 是否为账本上线前的历史明细（已汇总计入期初余额）
+}
+
+// recordTypeExpr 明细类型：提现申请的分录行展示提现单当前状态，其余取分录行记录的类型
+const recordTypeExpr = "CASE WHEN e.biz_type = '" + BizWithdrawApply + "' THEN IFNULL(w.status, p.record_type) ELSE p.record_type END"
+
+// CompanionBalance 陪诊师账户余额
+type CompanionBalance struct {
+	Available utils.Money `json:"balance"`        // 可用余额（可提现）
+	Frozen    utils.Money `json:"frozen_balance"` // 冻结余额（服务收入冻结期内，到期自动转入可用余额）
+}
+
+// GetCompanionBalance 查询陪诊师账户余额（取自账本可用余额账户与冻结余额账户）
+func (b *BalanceService) GetCompanionBalance(companionId uint64) (*CompanionBalance, error) {
 	// 1. 查询陪诊师用户信息
 	var companion model.User
 	if err := model.DB.Where("id = ? AND user_type = ?", companionId, 2).First(&companion).Error; err != nil {
 		if gorm.IsRecordNotFoundError(err) {
-			return 0, errors.New("非陪诊师账户，无余额信息")
+			return nil, errors.New("非陪诊师账户，无余额信息")
 		}
-		return 0, errors.New("查询用户信息失败")
+		return nil, errors.New("查询用户信息失败")
 	}
 
-	// 2. 返回保留两位小数的余额
-	return utils.KeepTwoDecimal(companion.Balance), nil
+	// 2. 返回账本中的可用余额与冻结余额
+	ledger := &LedgerService{}
+	available, err := ledger.GetAccountBalance(AccountCompanionAvailable, companionId)
+	if err != nil {
+		return nil, err
+	}
+	frozen, err := ledger.GetAccountBalance(AccountCompanionFrozen, companionId)
+	if err != nil {
+		return nil, err
+	}
+	return &CompanionBalance{Available: available, Frozen: frozen}, nil
 }
 
-// GetCompanionBalanceRecordList 查询陪诊师余额明细（带分页、类型筛选）
-func (b *BalanceService) GetCompanionBalanceRecordList(companionId uint64, recordType int, page int, size int) ([]model.BalanceRecord, int64, error) {
-	var recordList []model.BalanceRecord
+// GetCompanionBalanceRecordList 查询陪诊师余额明细（带分页、类型筛选），明细为可用余额账户分录行的视图，并合并账本上线前的历史明细
+func (b *BalanceService) GetCompanionBalanceRecordList(companionId uint64, recordType int, page int, size int) ([]BalanceRecordView, int64, error) {
+	recordList := []BalanceRecordView{}
 	var total int64
 
 	// 1. 计算分页偏移量
 	offset := (page - 1) * size
 
-	// 2. 构造查询条件
-	query := model.DB.Where("companion_id = ?", companionId)
-	if recordType > 0 { // 筛选指定类型（1-收入，2-提现成功，3-提现失败）
-		query = query.Where("type = ?", recordType)
+	// 2. 查询可用余额账户（账户尚未开立时仅有历史明细）
+	var account model.LedgerAccount
+	if err := model.DB.Where("account_type = ? AND owner_id = ?", AccountCompanionAvailable, companionId).First(&account).Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		return nil, 0, err
+	}
+
+	// 3. 构造查询：账本分录行 + balance_records 历史明细
+	ledgerSql := "SELECT p.id, e.entry_no AS serial_no, ? AS companion_id, " + recordTypeExpr + " AS type, p.amount, e.remark, p.created_at AS create_time, 0 AS legacy" +
+		" FROM ledger_postings p JOIN ledger_entries e ON e.id = p.entry_id LEFT JOIN withdrawals w ON w.serial_no = e.entry_no WHERE p.account_id = ?"
+	legacySql := "SELECT br.id, br.serial_no, br.companion_id, br.type, br.amount, br.remark, br.create_time, 1 AS legacy" +
+		" FROM balance_records br WHERE br.companion_id = ? AND br.deleted_at IS NULL"
+	args := []interface{}{companionId, account.ID}
+	legacyArgs := []interface{}{companionId}
+	if recordType > 0 { // 筛选指定类型（1-收入，2-提现成功，3-提现失败，4-提现中，5-取消违约金，6-取消补偿，7-退款，8-期初余额，9-打赏，10-余额调整）
+		ledgerSql += " AND " + recordTypeExpr + " = ?"
+		legacySql += " AND br.type = ?"
+		args = append(args, recordType)
+		legacyArgs = append(legacyArgs, recordType)
 	}
+	unionSql := "(" + ledgerSql + " UNION ALL " + legacySql + ") t"
+	args = append(args, legacyArgs...)
 
-	// 3. 查询明细总数
-	if err := query.Model(&model.BalanceRecord{}).Count(&total).Error; err != nil {
+	// 4. 查询明细总数
+	if err := model.DB.Raw("SELECT COUNT(*) FROM "+unionSql, args...).Row().Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
-	// 4. 查询分页明细（按创建时间倒序，最新明细优先）
-	if err := query.Order("create_time DESC").Offset(offset).Limit(size).Find(&recordList).Error; err != nil {
+	// 5. 查询分页明细（按发生时间倒序，最新明细优先；历史明细均早于账本期初余额）
+	if err := model.DB.Raw("SELECT * FROM "+unionSql+" ORDER BY t.create_time DESC, t.legacy ASC, t.id DESC LIMIT ? OFFSET ?", append(args, size, offset)...).
+		Scan(&recordList).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return recordList, total, nil
 }
 
-// ApplyWithdraw 陪诊师申请提现（事务处理：扣减余额+生成提现明细）
-func (b *BalanceService) ApplyWithdraw(companionId uint64, amount float64, account string, realName string) (string, error) {
-	// 1. 格式化提现金额（保留两位小数）
-	withdrawAmount := utils.KeepTwoDecimal(amount)
+// ApplyWithdraw 陪诊师申请提现（事务处理：校验提现规则 + 生成提现单 + 记账：可用余额 → 提现在途）
+func (b *BalanceService) ApplyWithdraw(companionId uint64, amount utils.Money, account string, realName string) (*model.Withdrawal, error) {
+	// 1. 校验收款信息（会原样写入银行打款文件，不允许以公式触发字符开头），计算提现金额与手续费（手续费从提现金额中扣除）
+	if hasFormulaPrefix(account) || hasFormulaPrefix(realName) {
+		return nil, errors.New("提现账户与真实姓名不能以 = + - @ 或空白字符开头")
+	}
+	withdrawAmount := amount
+	fee := calcWithdrawFee(withdrawAmount)
+	if fee >= withdrawAmount {
+		return nil, errors.New("提现金额需大于手续费" + fee.String() + "元")
+	}
 
-	// 开启事务（多表操作：查询余额+扣减余额+生成明细）
+	// 开启事务（多表操作：锁定余额账户+生成提现单+记账）
 	tx := model.DB.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -69,116 +123,249 @@ func (b *BalanceService) ApplyWithdraw(companionId uint64, amount float64, accou
 	}()
 
 	if err := tx.Error; err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// 2. 查询陪诊师信息与当前余额
+	// 2. 查询陪诊师信息
 	var companion model.User
 	if err := tx.Where("id = ? AND user_type = ?", companionId, 2).First(&companion).Error; err != nil {
 		tx.Rollback()
 		if gorm.IsRecordNotFoundError(err) {
-			return "", errors.New("非陪诊师账户，无法提现")
+			return nil, errors.New("非陪诊师账户，无法提现")
 		}
-		return "", errors.New("查询陪诊师信息失败")
+		return nil, errors.New("查询陪诊师信息失败")
 	}
 
-	// 3. 校验余额是否充足
-	currentBalance := utils.KeepTwoDecimal(companion.Balance)
+	// 3. 锁定可用余额账户并校验余额是否充足（FOR UPDATE 保证并发提现不会透支）
+	available, err := ensureAccount(tx, AccountCompanionAvailable, companionId)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", available.ID).First(available).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("查询账户余额失败")
+	}
+	currentBalance := available.Balance
 	if currentBalance < withdrawAmount {
 		tx.Rollback()
-		return "", errors.New("账户余额不足，当前余额：" + strconv.FormatFloat(currentBalance, 'f', 2, 64))
+		return nil, errors.New("账户可提现余额不足，当前可提现余额：" + currentBalance.String())
 	}
 
-	// 4. 生成提现明细编号
+	// 4. 校验单笔限额与日/月累计限额
+	if err := checkWithdrawLimits(tx, companionId, withdrawAmount, time.Now()); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 5. 生成提现单编号
 	serialNo := utils.GenerateSerialNo("WDR") // WDR-提现前缀
 
-	// 5. 扣减陪诊师余额
-	newBalance := utils.KeepTwoDecimal(currentBalance - withdrawAmount)
-	if err := tx.Model(&model.User{}).Where("id = ?", companionId).Update("balance", newBalance).Error; err != nil {
+	// 6. 生成提现单（状态：4-提现中，管理员审核通过后导出批量打款，按银行结果由 UpdateWithdrawStatus 更新为2-提现成功/3-提现失败；驳回直接更新为3-提现失败）
+	remark := "提现至" + account + "（姓名：" + realName + "）"
+	withdrawal := model.Withdrawal{
+		SerialNo:     serialNo,
+		CompanionId:  companionId,
+		Amount:       withdrawAmount,
+		Fee:          fee,
+		ActualAmount: withdrawAmount - fee,
+		Account:      account,
+		RealName:     realName,
+		Status:       RecordWithdrawing,
+	}
+	if err := tx.Create(&withdrawal).Error; err != nil {
 		tx.Rollback()
-		return "", errors.New("扣减账户余额失败")
+		return nil, errors.New("生成提现单失败")
 	}
 
-	// 6. 生成提现明细（状态：2-提现成功/3-提现失败，此处先记录为待审核，可后续扩展审核逻辑）
-	// 备注：实际项目中可增加「提现审核」状态，此处简化为直接生成提现成功明细（或提现中）
-	remark := "提现至" + account + "（姓名：" + realName + "）"
-	balanceRecord := model.BalanceRecord{
-		SerialNo:    serialNo,
-		CompanionId: companionId,
-		Type:        2,               // 2-提现成功（若需审核，可先设为4-提现中，审核后更新为2/3）
-		Amount:      -withdrawAmount, // 提现金额为负数（收入为正，提现为负）
-		Remark:      remark,
-	}
-	if err := tx.Create(&balanceRecord).Error; err != nil {
+	// 7. 记账：可用余额 → 提现在途（待确认打款结果后结清）
+	if err := postEntry(tx, serialNo, BizWithdrawApply, serialNo, remark,
+		Posting{AccountType: AccountCompanionAvailable, OwnerId: companionId, Amount: -withdrawAmount, RecordType: RecordWithdrawing},
+		Posting{AccountType: AccountPayoutInTransit, OwnerId: companionId, Amount: withdrawAmount},
+	); err != nil {
 		tx.Rollback()
-		return "", errors.New("生成提现明细失败")
+		return nil, err
 	}
 
-	// 7. 提交事务
+	// 8. 提交事务
 	if err := tx.Commit().Error; err != nil {
 		tx.Rollback()
-		return "", errors.New("提现事务提交失败")
+		return nil, errors.New("提现事务提交失败")
 	}
 
-	// 返回提现明细编号
-	return serialNo, nil
+	// 返回提现单
+	return &withdrawal, nil
 }
 
-// UpdateWithdrawStatus 更新提现状态（后续扩展：审核/支付回调时调用）
-func (b *BalanceService) UpdateWithdrawStatus(serialNo string, newType int) error {
-	// 1. 校验提现类型（仅允许更新为2-成功/3-失败）
-	if newType != 2 && newType != 3 {
-		return errors.New("无效的提现状态，仅支持2-提现成功/3-提现失败")
+// GetWithdrawList 管理员查询提现单列表（status<=0 表示不筛选状态，按申请时间正序，先申请先审核）
+func (b *BalanceService) GetWithdrawList(status int, page int, size int) ([]model.Withdrawal, int64, error) {
+	var withdrawList []model.Withdrawal
+	var total int64
+
+	query := model.DB.Model(&model.Withdrawal{})
+	if status > 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("查询提现单总数失败")
 	}
 
-	// 2. 查询提现明细是否存在
-	var record model.BalanceRecord
-	if err := model.DB.Where("serial_no = ? AND type IN (4,2,3)", serialNo).First(&record).Error; err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			return errors.New("提现明细不存在")
-		}
-		return errors.New("查询提现明细失败")
+	offset := (page - 1) * size
+	if err := query.Order("created_at ASC").Offset(offset).Limit(size).Find(&withdrawList).Error; err != nil {
+		return nil, 0, errors.New("查询提现单列表失败")
 	}
+	return withdrawList, total, nil
+}
 
-	// 3. 若提现失败，恢复余额
-	if newType == 3 {
-		tx := model.DB.Begin()
-		defer func() {
-			if r := recover(); r != nil {
-				tx.Rollback()
-			}
-		}()
+// ApproveWithdraw 管理员审核通过提现：记录审核信息，状态仍为提现中，等待导出批量打款后按银行结果确认
+// 已导出打款的提现单不可再审核
+func (b *BalanceService) ApproveWithdraw(serialNo string, adminId uint64, remark string) error {
+	// 1. 以提现中、未导出、未审核为条件记录审核信息
+	updates := map[string]interface{}{"approved_at": time.Now(), "admin_id": adminId}
+	if remark != "" {
+		updates["remark"] = remark
+	}
+	result := model.DB.Model(&model.Withdrawal{}).
+		Where("serial_no = ? AND status = ? AND settled_at IS NULL AND batch_no = ? AND approved_at IS NULL", serialNo, RecordWithdrawing, "").
+		Updates(updates)
+	if result.Error != nil {
+		return errors.New("更新提现审核状态失败")
+	}
 
-		// 恢复陪诊师余额
-		var companion model.User
-		if err := tx.Where("id = ?", record.CompanionId).First(&companion).Error; err != nil {
-			tx.Rollback()
-			return errors.New("查询陪诊师信息失败")
+	// 2. 未更新时返回具体原因
+	if result.RowsAffected == 0 {
+		withdrawal, err := findWithdrawal(serialNo)
+		if err != nil {
+			return err
 		}
-		restoreAmount := -record.Amount // 提现金额为负，恢复时取正
-		newBalance := utils.KeepTwoDecimal(companion.Balance + restoreAmount)
-		if err := tx.Model(&model.User{}).Where("id = ?", record.CompanionId).Update("balance", newBalance).Error; err != nil {
-			tx.Rollback()
-			return errors.New("恢复陪诊师余额失败")
+		if withdrawal.ApprovedAt != nil && withdrawal.Status == RecordWithdrawing && withdrawal.BatchNo == "" {
+			return errors.New("该提现已审核通过，请勿重复操作")
 		}
+		return settleConflictError(withdrawal)
+	}
+	return nil
+}
 
-		// 更新提现明细状态
-		if err := tx.Model(&model.BalanceRecord{}).Where("serial_no = ?", serialNo).Update("type", 3).Error; err != nil {
-			tx.Rollback()
-			return errors.New("更新提现状态失败")
+// RejectWithdraw 管理员驳回提现（走提现失败流程，金额退回陪诊师可用余额）
+// 已导出打款的提现单银行可能已在打款，不可驳回，只能按银行结果文件处理
+func (b *BalanceService) RejectWithdraw(serialNo string, adminId uint64, reason string) error {
+	if reason == "" {
+		return errors.New("驳回提现需填写原因")
+	}
+	return b.settleWithdraw(serialNo, RecordWithdrawFail, adminId, reason, true)
+}
+
+// UpdateWithdrawStatus 确认提现打款结果（导入银行结果文件/支付回调时调用，回调时 adminId 为0）：
+// 成功时提现在途 → 平台资金清算；失败时提现在途退回陪诊师可用余额。仅提现中的提现单可处理，每笔提现仅结清一次
+func (b *BalanceService) UpdateWithdrawStatus(serialNo string, newType int, adminId uint64, remark string) error {
+	return b.settleWithdraw(serialNo, newType, adminId, remark, false)
+}
+
+// findWithdrawal 按提现编号查询提现单
+func findWithdrawal(serialNo string) (*model.Withdrawal, error) {
+	var withdrawal model.Withdrawal
+	if err := model.DB.Where("serial_no = ?", serialNo).First(&withdrawal).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("提现明细不存在")
 		}
+		return nil, errors.New("查询提现明细失败")
+	}
+	return &withdrawal, nil
+}
+
+// settleConflictError 提现单未能按条件更新的原因（已导出打款或已处理）
+func settleConflictError(withdrawal *model.Withdrawal) error {
+	if withdrawal.Status == RecordWithdrawing && withdrawal.SettledAt == nil && withdrawal.BatchNo != "" {
+		return errors.New("该提现已导出打款（批次" + withdrawal.BatchNo + "），请通过导入银行结果文件处理")
+	}
+	return errors.New("该提现已处理，请勿重复操作")
+}
+
+// settleWithdraw 结清提现单（manual 为 true 表示管理员手工处理，仅限尚未导出打款的提现单）
+func (b *BalanceService) settleWithdraw(serialNo string, newType int, adminId uint64, remark string, manual bool) error {
+	// 1. 校验提现类型（仅允许更新为2-成功/3-失败）
+	if newType != RecordWithdrawSuccess && newType != RecordWithdrawFail {
+		return errors.New("无效的提现状态，仅支持2-提现成功/3-提现失败")
+	}
 
-		if err := tx.Commit().Error; err != nil {
+	// 2. 查询提现单是否存在
+	withdrawal, err := findWithdrawal(serialNo)
+	if err != nil {
+		return err
+	}
+
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
 			tx.Rollback()
-			return errors.New("提现失败事务提交失败")
 		}
-		return nil
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
 	}
 
-	// 4. 提现成功，直接更新状态（若无需恢复余额）
-	if err := model.DB.Model(&model.BalanceRecord{}).Where("serial_no = ?", serialNo).Update("type", 2).Error; err != nil {
+	// 3. 以提现中且未结清为条件更新提现单状态（避免重复审核/回调导致重复记账；手工处理时还须尚未导出打款，与导出并发时只有一方成功）
+	query := tx.Model(&model.Withdrawal{}).Where("id = ? AND status = ? AND settled_at IS NULL", withdrawal.ID, RecordWithdrawing)
+	if manual {
+		query = query.Where("batch_no = ?", "")
+	}
+	result := query.Updates(map[string]interface{}{
+		"status":     newType,
+		"admin_id":   adminId,
+		"remark":     remark,
+		"settled_at": time.Now(),
+	})
+	if result.Error != nil {
+		tx.Rollback()
 		return errors.New("更新提现状态失败")
 	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		if latest, err := findWithdrawal(serialNo); err == nil {
+			return settleConflictError(latest)
+		}
+		return errors.New("该提现已处理，请勿重复操作")
+	}
 
+	// 4. 记账结清提现在途
+	if newType == RecordWithdrawFail {
+		// 提现失败，退回陪诊师可用余额
+		failRemark := "提现" + serialNo + "失败，金额退回"
+		if remark != "" {
+			failRemark += "（原因：" + remark + "）"
+		}
+		err = postEntry(tx, utils.GenerateSerialNo("WDF"), BizWithdrawFail, serialNo, failRemark,
+			Posting{AccountType: AccountPayoutInTransit, OwnerId: withdrawal.CompanionId, Amount: -withdrawal.Amount},
+			Posting{AccountType: AccountCompanionAvailable, OwnerId: withdrawal.CompanionId, Amount: withdrawal.Amount, RecordType: RecordWithdrawFail},
+		)
+	} else {
+		// 提现成功，实际到账金额流出平台，手续费计入平台收入
+		err = postEntry(tx, utils.GenerateSerialNo("WDS"), BizWithdrawSuccess, serialNo, "提现"+serialNo+"打款成功",
+			Posting{AccountType: AccountPayoutInTransit, OwnerId: withdrawal.CompanionId, Amount: -withdrawal.Amount},
+			Posting{AccountType: AccountPlatformClearing, Amount: withdrawal.Amount - withdrawal.Fee},
+			Posting{AccountType: AccountPlatformRevenue, Amount: withdrawal.Fee},
+		)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return errors.New("更新提现状态事务提交失败")
+	}
+
+	// 5. 通知陪诊师提现结果
+	if newType == RecordWithdrawFail {
+		content := "您的提现" + serialNo + "（" + withdrawal.Amount.String() + "元）未通过，金额已退回账户余额"
+		if remark != "" {
+			content += "，原因：" + remark
+		}
+		notify(withdrawal.CompanionId, NoticeWithdrawRejected, "提现失败", content, withdrawal.ID)
+	} else {
+		notify(withdrawal.CompanionId, NoticeWithdrawApproved, "提现成功", "您的提现"+serialNo+"（到账"+(withdrawal.Amount-withdrawal.Fee).String()+"元）已打款", withdrawal.ID)
+	}
 	return nil
 }
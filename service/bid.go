@@ -0,0 +1,281 @@
+// service/bid.go
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/statemachine"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 报价状态
+const (
+	BidPending   = 0 // 待选择
+	BidAccepted  = 1 // 已中标
+	BidRejected  = 2 // 未中标
+	BidWithdrawn = 3 // 已撤回
+	BidExpired   = 4 // 已失效（需求退回订单大厅或过期关闭，陪诊师可重新报价）
+)
+
+// BidService 竞价服务
+type BidService struct{}
+
+// BidDetail 报价详情（附带陪诊师资料与评分，供患者选择）
+type BidDetail struct {
+	model.Bid
+	CompanionNickname string  `json:"companion_nickname"` // 陪诊师昵称
+	CompanionAvatar   string  `json:"companion_avatar"`   // 陪诊师头像
+	AvgScore          float64 `json:"avg_score"`          // 陪诊师平均评分（1-5星，无评价为0）
+	EvalCount         int64   `json:"eval_count"`         // 陪诊师收到的评价数
+}
+
+// -------------------------- 陪诊师相关业务 --------------------------
+
+// SubmitBid 陪诊师提交报价（同一需求重复提交时更新报价，返回报价ID）
+func (b *BidService) SubmitBid(demandId uint64, companionId uint64, price utils.Money, message string) (uint64, error) {
+	// 1. 查询需求：必须是竞价模式、待接单且服务时间未过
+	var demand model.Demand
+	if err := model.DB.Where("id = ? AND status = ?", demandId, statemachine.DemandPending).First(&demand).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return 0, errors.New("需求不存在或已被接单")
+		}
+		return 0, errors.New("查询需求失败")
+	}
+	if demand.BidMode != 1 {
+		return 0, errors.New("该需求不是竞价模式，请直接接单")
+	}
+	if !demand.ServiceTime.After(time.Now()) {
+		return 0, errors.New("需求服务时间已过，无法报价")
+	}
+	if demand.PatientId == companionId {
+		return 0, errors.New("不能对自己发布的需求报价")
+	}
+
+	// 2. 已有报价则更新（含已撤回/未中标/已失效后重新报价），否则新建
+	var bid model.Bid
+	err := model.DB.Where("demand_id = ? AND companion_id = ?", demandId, companionId).First(&bid).Error
+	if err == nil {
+		if err := model.DB.Model(&model.Bid{}).Where("id = ?", bid.ID).Updates(map[string]interface{}{
+			"price":   price,
+			"message": message,
+			"status":  BidPending,
+		}).Error; err != nil {
+			return 0, errors.New("更新报价失败")
+		}
+		return bid.ID, nil
+	}
+	if !gorm.IsRecordNotFoundError(err) {
+		return 0, errors.New("查询报价失败")
+	}
+
+	bid = model.Bid{
+		DemandId:    demandId,
+		CompanionId: companionId,
+		Price:       price,
+		Message:     message,
+		Status:      BidPending,
+	}
+	if err := model.DB.Create(&bid).Error; err != nil {
+		return 0, errors.New("提交报价失败")
+	}
+
+	return bid.ID, nil
+}
+
+// WithdrawBid 陪诊师撤回报价（仅待选择状态可撤回）
+func (b *BidService) WithdrawBid(bidId uint64, companionId uint64) error {
+	result := model.DB.Model(&model.Bid{}).
+		Where("id = ? AND companion_id = ? AND status = ?", bidId, companionId, BidPending).
+		Update("status", BidWithdrawn)
+	if result.Error != nil {
+		return errors.New("撤回报价失败")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("报价不存在或已被处理，无法撤回")
+	}
+	return nil
+}
+
+// GetCompanionBidList 查询陪诊师的报价列表（带分页）
+func (b *BidService) GetCompanionBidList(companionId uint64, page int, size int) ([]model.Bid, int64, error) {
+	var bidList []model.Bid
+	var total int64
+
+	// 计算分页偏移量
+	offset := (page - 1) * size
+
+	// 查询总数
+	if err := model.DB.Where("companion_id = ?", companionId).Model(&model.Bid{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 查询分页数据，按更新时间倒序
+	if err := model.DB.Where("companion_id = ?", companionId).Order("updated_at DESC").Offset(offset).Limit(size).Find(&bidList).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return bidList, total, nil
+}
+
+// -------------------------- 患者相关业务 --------------------------
+
+// GetDemandBidList 患者查询需求收到的报价（附带陪诊师评分，按报价从低到高）
+func (b *BidService) GetDemandBidList(demandId uint64, patientId uint64) ([]BidDetail, error) {
+	// 1. 校验需求归属
+	var demand model.Demand
+	if err := model.DB.Where("id = ? AND patient_id = ?", demandId, patientId).First(&demand).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("需求不存在或非本人需求")
+		}
+		return nil, errors.New("查询需求失败")
+	}
+
+	// 2. 查询报价（不含已撤回、已失效）
+	var bidList []model.Bid
+	if err := model.DB.Where("demand_id = ? AND status NOT IN (?)", demandId, []int{BidWithdrawn, BidExpired}).Order("price ASC, created_at ASC").Find(&bidList).Error; err != nil {
+		return nil, errors.New("查询报价失败")
+	}
+	if len(bidList) == 0 {
+		return []BidDetail{}, nil
+	}
+
+	companionIds := make([]uint64, 0, len(bidList))
+	for _, bid := range bidList {
+		companionIds = append(companionIds, bid.CompanionId)
+	}
+
+	// 3. 查询陪诊师资料
+	var companions []model.User
+	if err := model.DB.Where("id IN (?)", companionIds).Find(&companions).Error; err != nil {
+		return nil, errors.New("查询陪诊师信息失败")
+	}
+	companionMap := make(map[uint64]model.User, len(companions))
+	for _, companion := range companions {
+		companionMap[companion.ID] = companion
+	}
+
+	// 4. 统计陪诊师评分
+	var ratings []struct {
+		ToUserId  uint64
+		AvgScore  float64
+		EvalCount int64
+	}
+	if err := model.DB.Model(&model.Evaluation{}).
+		Select("to_user_id, AVG(score) AS avg_score, COUNT(*) AS eval_count").
+		Where("to_user_id IN (?)", companionIds).
+		Group("to_user_id").Scan(&ratings).Error; err != nil {
+		return nil, errors.New("查询陪诊师评分失败")
+	}
+	ratingMap := make(map[uint64]int, len(ratings))
+	for i, rating := range ratings {
+		ratingMap[rating.ToUserId] = i
+	}
+
+	// 5. 组装报价详情
+	details := make([]BidDetail, 0, len(bidList))
+	for _, bid := range bidList {
+		detail := BidDetail{Bid: bid}
+		if companion, ok := companionMap[bid.CompanionId]; ok {
+			detail.CompanionNickname = companion.Nickname
+			detail.CompanionAvatar = companion.Avatar
+		}
+		if i, ok := ratingMap[bid.CompanionId]; ok {
+			detail.AvgScore = utils.KeepTwoDecimal(ratings[i].AvgScore)
+			detail.EvalCount = ratings[i].EvalCount
+		}
+		details = append(details, detail)
+	}
+
+	return details, nil
+}
+
+// AcceptBid 患者选定报价（按报价金额生成订单，订单直接进入待支付，其余报价标记为未中标）
+func (b *BidService) AcceptBid(bidId uint64, patientId uint64, clientIP string) error {
+	// 开启事务（更新报价 + 生成订单 + 更新需求）
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	// 1. 查询报价：必须是待选择状态
+	var bid model.Bid
+	if err := tx.Where("id = ? AND status = ?", bidId, BidPending).First(&bid).Error; err != nil {
+		tx.Rollback()
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("报价不存在或已失效")
+		}
+		return errors.New("查询报价失败")
+	}
+
+	// 2. 查询需求：当前患者的竞价需求，且仍为待接单状态
+	var demand model.Demand
+	if err := tx.Where("id = ? AND patient_id = ? AND status = ? AND bid_mode = ?", bid.DemandId, patientId, statemachine.DemandPending, 1).First(&demand).Error; err != nil {
+		tx.Rollback()
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("需求不存在、非本人需求或已被接单")
+		}
+		return errors.New("查询需求失败")
+	}
+
+	// 3. 按报价金额生成订单（患者已选定陪诊师，无需再次确认）
+	actor := statemachine.Actor{Id: patientId, Role: statemachine.RolePatient, ClientIP: clientIP}
+	reason := "患者选定竞价报价，报价金额：" + bid.Price.String()
+	order, err := (&OrderService{}).createOrderForDemand(tx, &demand, bid.CompanionId, bid.Price, nil, actor, reason)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 4. 更新报价状态（选中 → 已中标，其余待选择 → 未中标）
+	result := tx.Model(&model.Bid{}).Where("id = ? AND status = ?", bid.ID, BidPending).Update("status", BidAccepted)
+	if result.Error != nil {
+		tx.Rollback()
+		return errors.New("更新报价状态失败")
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("报价已撤回或失效，请刷新后重试")
+	}
+	var loserIds []uint64
+	if err := tx.Model(&model.Bid{}).Where("demand_id = ? AND id <> ? AND status = ?", demand.ID, bid.ID, BidPending).Pluck("companion_id", &loserIds).Error; err != nil {
+		tx.Rollback()
+		return errors.New("查询其余报价失败")
+	}
+	if err := tx.Model(&model.Bid{}).Where("demand_id = ? AND id <> ? AND status = ?", demand.ID, bid.ID, BidPending).Update("status", BidRejected).Error; err != nil {
+		tx.Rollback()
+		return errors.New("更新报价状态失败")
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return errors.New("选定报价事务提交失败")
+	}
+
+	// 通知中标与未中标的陪诊师
+	notify(bid.CompanionId, NoticeBidAccepted, "您的报价已中标", "您对"+demand.Hospital+"陪诊需求的报价已被患者选中，订单"+order.OrderNo+"已生成，待患者支付后请按时到场服务。", order.ID)
+	for _, companionId := range loserIds {
+		notify(companionId, NoticeBidRejected, "您的报价未中标", "患者已为"+demand.Hospital+"陪诊需求选定其他陪诊师，感谢您的报价。", demand.ID)
+	}
+
+	return nil
+}
+
+// expireDemandBids 需求退回订单大厅或过期关闭时，此前的报价（待选择、已中标、未中标）全部置为已失效，避免展示过期的中标结果
+func expireDemandBids(tx *gorm.DB, demandId uint64) error {
+	if err := tx.Model(&model.Bid{}).Where("demand_id = ? AND status IN (?)", demandId, []int{BidPending, BidAccepted, BidRejected}).
+		Update("status", BidExpired).Error; err != nil {
+		return errors.New("更新报价状态失败")
+	}
+	return nil
+}
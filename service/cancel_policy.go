@@ -0,0 +1,201 @@
+// service/cancel_policy.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/statemachine"
+	"github.com/X-Colder/companion-backend/utils"
+	"github.com/jinzhu/gorm"
+)
+
+// CancelPenalty 取消违约结果
+type CancelPenalty struct {
+	Role         string      `json:"role"`          // 违约方（患者/陪诊师）
+	HoursLeft    float64     `json:"hours_left"`    // 取消时距服务时间的小时数
+	WithinHours  float64     `json:"within_hours"`  // 命中规则的时间窗口（0表示未命中任何规则）
+	Amount       utils.Money `json:"amount"`        // 违约金
+	CreditDeduct int         `json:"credit_deduct"` // 扣减信用分
+}
+
+// matchCancelRule 按距服务时间匹配取消规则（命中多条时取时间窗口最小、即最严格的一条）
+func matchCancelRule(rules []conf.CancelRule, hoursLeft float64) *conf.CancelRule {
+	var matched *conf.CancelRule
+	for i := range rules {
+		rule := &rules[i]
+		if hoursLeft >= rule.WithinHours {
+			continue
+		}
+		if matched == nil || rule.WithinHours < matched.WithinHours {
+			matched = rule
+		}
+	}
+	return matched
+}
+
+// calcCancelPenalty 计算取消违约金（违约金不超过订单金额）
+func calcCancelPenalty(role statemachine.Role, orderAmount utils.Money, serviceTime time.Time, now time.Time) CancelPenalty {
+	penalty := CancelPenalty{
+		Role:      role.String(),
+		HoursLeft: utils.KeepTwoDecimal(serviceTime.Sub(now).Hours()),
+	}
+
+	var rules []conf.CancelRule
+	switch role {
+	case statemachine.RolePatient:
+		rules = conf.AppConfig.CancelPolicy.Patient
+	case statemachine.RoleCompanion:
+		rules = conf.AppConfig.CancelPolicy.Companion
+	}
+	rule := matchCancelRule(rules, serviceTime.Sub(now).Hours())
+	if rule == nil {
+		return penalty
+	}
+
+	amount := orderAmount.MulRate(rule.PenaltyRate) + utils.MoneyFromYuan(rule.PenaltyAmount)
+	if amount > orderAmount {
+		amount = orderAmount
+	}
+	penalty.WithinHours = rule.WithinHours
+	penalty.Amount = amount
+	if role == statemachine.RoleCompanion {
+		penalty.CreditDeduct = rule.CreditDeduct
+	}
+	return penalty
+}
+
+// PreviewCancelPenalty 预估当前取消订单需承担的违约金（仅待服务订单，供取消前提示）
+func (o *OrderService) PreviewCancelPenalty(orderId uint64, userId uint64) (*CancelPenalty, error) {
+	var order model.Order
+	if err := model.DB.Where("id = ?", orderId).First(&order).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("订单不存在")
+		}
+		return nil, errors.New("查询订单失败")
+	}
+
+	var role statemachine.Role
+	switch userId {
+	case order.PatientId:
+		role = statemachine.RolePatient
+	case order.CompanionId:
+		role = statemachine.RoleCompanion
+	default:
+		return nil, statemachine.ErrNotParticipant
+	}
+	if statemachine.OrderStatus(order.Status) != statemachine.OrderPendingService {
+		return &CancelPenalty{Role: role.String()}, nil
+	}
+
+	var demand model.Demand
+	if err := model.DB.Where("id = ?", order.DemandId).First(&demand).Error; err != nil {
+		return nil, errors.New("查询关联需求失败")
+	}
+
+	penalty := calcCancelPenalty(role, order.OrderAmount, demand.ServiceTime, time.Now())
+	return &penalty, nil
+}
+
+// cancelPenaltyEffect 取消待服务订单时按违约规则扣收违约金，已预付的订单同时退回预付款：
+// 患者违约金从预付款扣除并补偿给陪诊师，其余退回患者；未经预付的历史订单从患者余额扣除，以现有余额为限，不足部分不再收取；
+// 陪诊师违约金从其余额扣除（归平台）并扣减信用分，预付款全额退回患者。违约金以可用余额与冻结余额之和为限，
+// 全部从可用余额扣除：可用余额不足时可暂为负数（不超过冻结余额），待冻结收入解冻后自动抵补，期间无法提现；超出部分不再收取
+func cancelPenaltyEffect(tx *gorm.DB, order *model.Order, actor statemachine.Actor) error {
+	var demand model.Demand
+	if err := tx.Where("id = ?", order.DemandId).First(&demand).Error; err != nil {
+		return errors.New("查询关联需求失败")
+	}
+
+	penalty := calcCancelPenalty(actor.Role, order.OrderAmount, demand.ServiceTime, time.Now())
+	remark := fmt.Sprintf("订单%s距服务时间%.1f小时取消", order.OrderNo, penalty.HoursLeft)
+
+	switch actor.Role {
+	case statemachine.RolePatient:
+		if order.PaidAt != nil {
+			// 记账：订单托管 → 陪诊师可用余额（违约金）+ 退款在途（其余退回患者，优惠部分退回平台）
+			refunds, err := refundPostings(tx, order, order.OrderAmount-penalty.Amount, remark+"，患者取消退款")
+			if err != nil {
+				return err
+			}
+			return postEntry(tx, utils.GenerateSerialNo("CNR"), BizCancelRefund, order.OrderNo, remark+"，患者取消结算",
+				append([]Posting{
+					{AccountType: AccountOrderEscrow, Amount: -order.OrderAmount},
+					{AccountType: AccountCompanionAvailable, OwnerId: order.CompanionId, Amount: penalty.Amount, RecordType: RecordCancelCompensation},
+				}, refunds...)...,
+			)
+		}
+		if penalty.Amount <= 0 {
+			return nil
+		}
+		// 未经预付：违约金以患者现有余额为限
+		balance, err := lockAccountBalance(tx, AccountPatientBalance, order.PatientId)
+		if err != nil {
+			return err
+		}
+		amount, remark := capPenalty(penalty.Amount, balance, remark)
+		if amount <= 0 {
+			return nil
+		}
+		// 记账：患者余额 → 陪诊师可用余额
+		return postEntry(tx, utils.GenerateSerialNo("PEN"), BizCancelPenalty, order.OrderNo, remark+"，患者违约金补偿陪诊师",
+			Posting{AccountType: AccountPatientBalance, OwnerId: order.PatientId, Amount: -amount, RecordType: RecordCancelPenalty},
+			Posting{AccountType: AccountCompanionAvailable, OwnerId: order.CompanionId, Amount: amount, RecordType: RecordCancelCompensation},
+		)
+	case statemachine.RoleCompanion:
+		if order.PaidAt != nil {
+			// 记账：订单托管 → 退款在途（预付款全额退回患者，优惠部分退回平台）
+			refunds, err := refundPostings(tx, order, order.OrderAmount, remark+"，陪诊师取消退款")
+			if err != nil {
+				return err
+			}
+			if err := postEntry(tx, utils.GenerateSerialNo("CNR"), BizCancelRefund, order.OrderNo, remark+"，陪诊师取消退款",
+				append([]Posting{{AccountType: AccountOrderEscrow, Amount: -order.OrderAmount}}, refunds...)...,
+			); err != nil {
+				return err
+			}
+		}
+		if penalty.Amount > 0 {
+			// 违约金以可用余额与冻结余额之和为限（先锁冻结余额再锁可用余额，与收入解冻的加锁顺序一致）
+			frozen, err := lockAccountBalance(tx, AccountCompanionFrozen, order.CompanionId)
+			if err != nil {
+				return err
+			}
+			available, err := lockAccountBalance(tx, AccountCompanionAvailable, order.CompanionId)
+			if err != nil {
+				return err
+			}
+			amount, remark := capPenalty(penalty.Amount, available+frozen, remark)
+			if amount > 0 {
+				// 记账：陪诊师可用余额 → 平台收入
+				if err := postEntry(tx, utils.GenerateSerialNo("PEN"), BizCancelPenalty, order.OrderNo, remark+"，陪诊师违约金",
+					Posting{AccountType: AccountCompanionAvailable, OwnerId: order.CompanionId, Amount: -amount, RecordType: RecordCancelPenalty},
+					Posting{AccountType: AccountPlatformRevenue, Amount: amount},
+				); err != nil {
+					return err
+				}
+			}
+		}
+		if penalty.CreditDeduct > 0 {
+			if err := tx.Model(&model.User{}).Where("id = ?", order.CompanionId).
+				Update("credit_score", gorm.Expr("GREATEST(credit_score - ?, 0)", penalty.CreditDeduct)).Error; err != nil {
+				return errors.New("扣减陪诊师信用分失败")
+			}
+		}
+	}
+	return nil
+}
+
+// capPenalty 违约金以可扣余额为限，不足时在摘要中注明应收与实收金额
+func capPenalty(amount utils.Money, balance utils.Money, remark string) (utils.Money, string) {
+	if balance < 0 {
+		balance = 0
+	}
+	if amount <= balance {
+		return amount, remark
+	}
+	return balance, remark + "，违约金" + amount.String() + "元，余额不足实收" + balance.String() + "元"
+}
@@ -0,0 +1,263 @@
+// service/commission.go
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// defaultCommissionRate 未命中任何佣金规则时的默认佣金比例（10%）
+const defaultCommissionRate = 0.1
+
+// 佣金规则状态
+const (
+	CommissionRuleDisabled = 0 // 停用
+	CommissionRuleEnabled  = 1 // 启用
+)
+
+// CommissionService 佣金规则服务（规则由管理员维护，接单生成订单时按规则计算平台佣金）
+type CommissionService struct{}
+
+// CommissionRuleInput 新增/修改佣金规则的参数（时间格式 2006-01-02 15:04:05，为空表示不限）
+type CommissionRuleInput struct {
+	Name         string
+	City         string
+	Hospital     string
+	MinScore     float64
+	MinEvalCount int
+	MinAmount    utils.Money
+	MaxAmount    utils.Money
+	StartAt      string
+	EndAt        string
+	Rate         float64
+	Priority     int
+}
+
+// companionRating 陪诊师评分统计（平均评分与评价数）
+type companionRating struct {
+	AvgScore  float64
+	EvalCount int
+}
+
+// resolveCommission 查找订单适用的佣金规则（在调用方事务内执行），返回命中的规则ID（0表示未命中）与佣金比例
+// 先在数据库中按生效时间与金额区间筛选启用的规则，再按优先级依次匹配城市、医院与陪诊师等级
+func resolveCommission(tx *gorm.DB, demand *model.Demand, companionId uint64, amount utils.Money, now time.Time) (uint64, float64, error) {
+	var ruleList []model.CommissionRule
+	if err := tx.Where("status = ? AND (start_at IS NULL OR start_at <= ?) AND (end_at IS NULL OR end_at > ?)", CommissionRuleEnabled, now, now).
+		Where("min_amount <= ? AND (max_amount = 0 OR max_amount > ?)", amount, amount).
+		Order("priority DESC, id DESC").Find(&ruleList).Error; err != nil {
+		return 0, 0, errors.New("查询佣金规则失败")
+	}
+
+	var rating *companionRating
+	for _, rule := range ruleList {
+		if !matchLocation(demand, rule.City, rule.Hospital) {
+			continue
+		}
+		if rule.MinScore > 0 || rule.MinEvalCount > 0 {
+			// 陪诊师评分仅在规则需要时查询一次
+			if rating == nil {
+				r, err := getCompanionRating(tx, companionId)
+				if err != nil {
+					return 0, 0, err
+				}
+				rating = r
+			}
+			if rating.AvgScore < rule.MinScore || rating.EvalCount < rule.MinEvalCount {
+				continue
+			}
+		}
+		return rule.ID, rule.Rate, nil
+	}
+	return 0, defaultCommissionRate, nil
+}
+
+// matchLocation 判断需求是否在限定的城市、医院范围内（为空表示不限；城市按医院地址包含城市名称匹配）
+func matchLocation(demand *model.Demand, city string, hospital string) bool {
+	if city != "" && !strings.Contains(demand.HospitalAddr, city) {
+		return false
+	}
+	if hospital != "" && hospital != demand.Hospital {
+		return false
+	}
+	return true
+}
+
+// getCompanionRating 统计陪诊师收到的评价（陪诊师收到的评价均来自患者）
+func getCompanionRating(tx *gorm.DB, companionId uint64) (*companionRating, error) {
+	var rating companionRating
+	if err := tx.Model(&model.Evaluation{}).Where("to_user_id = ?", companionId).
+		Select("COALESCE(AVG(score), 0) AS avg_score, COUNT(*) AS eval_count").Scan(&rating).Error; err != nil {
+		return nil, errors.New("查询陪诊师评分失败")
+	}
+	return &rating, nil
+}
+
+// GetRuleList 管理员查询佣金规则列表（status<0 表示不筛选状态）
+func (c *CommissionService) GetRuleList(status int, page int, size int) ([]model.CommissionRule, int64, error) {
+	var ruleList []model.CommissionRule
+	var total int64
+
+	query := model.DB.Model(&model.CommissionRule{})
+	if status >= 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("查询佣金规则总数失败")
+	}
+
+	offset := (page - 1) * size
+	if err := query.Order("priority DESC, id DESC").Offset(offset).Limit(size).Find(&ruleList).Error; err != nil {
+		return nil, 0, errors.New("查询佣金规则列表失败")
+	}
+	return ruleList, total, nil
+}
+
+// CreateRule 管理员新增佣金规则（新增后立即启用）
+func (c *CommissionService) CreateRule(input CommissionRuleInput, adminId uint64) (uint64, error) {
+	rule, err := input.toRule()
+	if err != nil {
+		return 0, err
+	}
+	rule.Status = CommissionRuleEnabled
+	rule.AdminId = adminId
+
+	if err := model.DB.Create(rule).Error; err != nil {
+		return 0, errors.New("新增佣金规则失败")
+	}
+	return rule.ID, nil
+}
+
+// UpdateRule 管理员修改佣金规则（仅影响修改后生成的订单，已生成订单保留接单时的佣金比例）
+func (c *CommissionService) UpdateRule(ruleId uint64, input CommissionRuleInput, adminId uint64) error {
+	rule, err := input.toRule()
+	if err != nil {
+		return err
+	}
+	if err := findCommissionRule(ruleId); err != nil {
+		return err
+	}
+
+	// 以 map 更新，允许将条件清空为不限
+	if err := model.DB.Model(&model.CommissionRule{}).Where("id = ?", ruleId).Updates(map[string]interface{}{
+		"name":           rule.Name,
+		"city":           rule.City,
+		"hospital":       rule.Hospital,
+		"min_score":      rule.MinScore,
+		"min_eval_count": rule.MinEvalCount,
+		"min_amount":     rule.MinAmount,
+		"max_amount":     rule.MaxAmount,
+		"start_at":       rule.StartAt,
+		"end_at":         rule.EndAt,
+		"rate":           rule.Rate,
+		"priority":       rule.Priority,
+		"admin_id":       adminId,
+	}).Error; err != nil {
+		return errors.New("修改佣金规则失败")
+	}
+	return nil
+}
+
+// UpdateRuleStatus 管理员启用/停用佣金规则
+func (c *CommissionService) UpdateRuleStatus(ruleId uint64, status int, adminId uint64) error {
+	if status != CommissionRuleEnabled && status != CommissionRuleDisabled {
+		return errors.New("规则状态无效")
+	}
+
+	if err := findCommissionRule(ruleId); err != nil {
+		return err
+	}
+
+	if err := model.DB.Model(&model.CommissionRule{}).Where("id = ?", ruleId).Updates(map[string]interface{}{
+		"status":   status,
+		"admin_id": adminId,
+	}).Error; err != nil {
+		return errors.New("更新佣金规则状态失败")
+	}
+	return nil
+}
+
+// findCommissionRule 校验佣金规则是否存在
+func findCommissionRule(ruleId uint64) error {
+	var rule model.CommissionRule
+	if err := model.DB.Where("id = ?", ruleId).First(&rule).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("佣金规则不存在")
+		}
+		return errors.New("查询佣金规则失败")
+	}
+	return nil
+}
+
+// toRule 校验规则参数并转换为规则实体
+func (in CommissionRuleInput) toRule() (*model.CommissionRule, error) {
+	// 1. 校验名称与佣金比例
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return nil, errors.New("规则名称不能为空")
+	}
+	if in.Rate < 0 || in.Rate > 1 {
+		return nil, errors.New("佣金比例需在0~1之间")
+	}
+
+	// 2. 校验陪诊师等级条件
+	if in.MinScore < 0 || in.MinScore > 5 {
+		return nil, errors.New("最低评分需在0~5之间")
+	}
+	if in.MinEvalCount < 0 {
+		return nil, errors.New("最少评价数不能为负数")
+	}
+
+	// 3. 校验金额区间
+	if in.MinAmount < 0 || in.MaxAmount < 0 {
+		return nil, errors.New("金额区间不能为负数")
+	}
+	if in.MaxAmount > 0 && in.MaxAmount <= in.MinAmount {
+		return nil, errors.New("金额上限需大于金额下限")
+	}
+
+	// 4. 解析并校验生效时间
+	startAt, err := parseRuleTime(in.StartAt)
+	if err != nil {
+		return nil, errors.New("生效开始时间格式错误，应为：2006-01-02 15:04:05")
+	}
+	endAt, err := parseRuleTime(in.EndAt)
+	if err != nil {
+		return nil, errors.New("生效结束时间格式错误，应为：2006-01-02 15:04:05")
+	}
+	if startAt != nil && endAt != nil && !endAt.After(*startAt) {
+		return nil, errors.New("生效结束时间需晚于开始时间")
+	}
+
+	return &model.CommissionRule{
+		Name:         name,
+		City:         strings.TrimSpace(in.City),
+		Hospital:     strings.TrimSpace(in.Hospital),
+		MinScore:     in.MinScore,
+		MinEvalCount: in.MinEvalCount,
+		MinAmount:    in.MinAmount,
+		MaxAmount:    in.MaxAmount,
+		StartAt:      startAt,
+		EndAt:        endAt,
+		Rate:         in.Rate,
+		Priority:     in.Priority,
+	}, nil
+}
+
+// parseRuleTime 解析规则生效时间（空字符串表示不限）
+func parseRuleTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
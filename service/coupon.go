@@ -0,0 +1,598 @@
+// service/coupon.go
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/statemachine"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 优惠券类型
+const (
+	CouponFixed     = 1 // 立减券（直接减免固定金额）
+	CouponPercent   = 2 // 折扣券（按订单金额比例减免，可设上限）
+	CouponThreshold = 3 // 满减券（订单金额满门槛后减免固定金额）
+)
+
+// 优惠券发放方式
+const (
+	CouponIssueAdmin    = 1 // 管理员发放
+	CouponIssueCampaign = 2 // 活动领取
+)
+
+// 优惠券模板状态
+const (
+	CouponTemplateDisabled = 0 // 停用
+	CouponTemplateEnabled  = 1 // 启用
+)
+
+// 患者优惠券状态（另有「已过期」：未使用且已超过过期时间，仅用于查询筛选）
+const (
+	UserCouponUnused  = 0 // 未使用
+	UserCouponLocked  = 1 // 已锁定（已用于待支付订单）
+	UserCouponUsed    = 2 // 已使用
+	UserCouponExpired = 3 // 已过期（查询筛选用，不落库）
+)
+
+// CouponService 优惠券服务（优惠金额由平台承担，陪诊师收入仍按订单金额计算）
+type CouponService struct{}
+
+// CouponTemplateInput 新增优惠券模板的参数（时间格式 2006-01-02 15:04:05，为空表示不限）
+type CouponTemplateInput struct {
+	Name         string
+	Type         int
+	Amount       utils.Money
+	Rate         float64
+	MaxDiscount  utils.Money
+	MinAmount    utils.Money
+	City         string
+	Hospital     string
+	IssueType    int
+	ClaimStartAt string
+	ClaimEndAt   string
+	ValidStartAt string
+	ValidEndAt   string
+	ValidDays    int
+	TotalCount   int
+	PerUserLimit int
+}
+
+// UserCouponView 患者优惠券（附带模板的优惠规则）
+type UserCouponView struct {
+	model.UserCoupon
+	Template *model.CouponTemplate `json:"template"`
+}
+
+// -------------------------- 模板管理 --------------------------
+
+// CreateTemplate 管理员新增优惠券模板（新增后立即启用）
+func (c *CouponService) CreateTemplate(input CouponTemplateInput, adminId uint64) (uint64, error) {
+	template, err := input.toTemplate()
+	if err != nil {
+		return 0, err
+	}
+	template.Status = CouponTemplateEnabled
+	template.AdminId = adminId
+
+	if err := model.DB.Create(template).Error; err != nil {
+		return 0, errors.New("新增优惠券失败")
+	}
+	return template.ID, nil
+}
+
+// GetTemplateList 管理员查询优惠券模板列表（status<0 表示不筛选状态）
+func (c *CouponService) GetTemplateList(status int, page int, size int) ([]model.CouponTemplate, int64, error) {
+	var templateList []model.CouponTemplate
+	var total int64
+
+	query := model.DB.Model(&model.CouponTemplate{})
+	if status >= 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("查询优惠券总数失败")
+	}
+
+	offset := (page - 1) * size
+	if err := query.Order("id DESC").Offset(offset).Limit(size).Find(&templateList).Error; err != nil {
+		return nil, 0, errors.New("查询优惠券列表失败")
+	}
+	return templateList, total, nil
+}
+
+// UpdateTemplateStatus 管理员启用/停用优惠券模板（停用后不可再发放或领取，已发放的券仍可使用）
+func (c *CouponService) UpdateTemplateStatus(templateId uint64, status int) error {
+	if status != CouponTemplateEnabled && status != CouponTemplateDisabled {
+		return errors.New("优惠券状态无效")
+	}
+
+	var template model.CouponTemplate
+	if err := model.DB.Where("id = ?", templateId).First(&template).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("优惠券不存在")
+		}
+		return errors.New("查询优惠券失败")
+	}
+
+	if err := model.DB.Model(&model.CouponTemplate{}).Where("id = ?", templateId).Update("status", status).Error; err != nil {
+		return errors.New("更新优惠券状态失败")
+	}
+	return nil
+}
+
+// -------------------------- 发放与领取 --------------------------
+
+// IssueCoupons 管理员向指定患者发放优惠券（已达持有上限的患者跳过，发放总量用尽时停止），返回实际发放张数
+func (c *CouponService) IssueCoupons(templateId uint64, patientIds []uint64) (int, error) {
+	// 1. 校验发放对象均为患者
+	var patientCount int
+	if err := model.DB.Model(&model.User{}).Where("id IN (?) AND user_type = ?", patientIds, 1).Count(&patientCount).Error; err != nil {
+		return 0, errors.New("查询患者信息失败")
+	}
+	if patientCount != len(patientIds) {
+		return 0, errors.New("发放对象中存在无效的患者ID")
+	}
+
+	// 2. 逐个发放（每张独立事务，锁定模板保证发放总量与持有上限不超发）
+	issued := 0
+	for _, patientId := range patientIds {
+		err := c.issueInTx(templateId, patientId, CouponIssueAdmin, nil)
+		if err == errCouponUserLimit {
+			continue
+		}
+		if err == errCouponSoldOut && issued > 0 {
+			break
+		}
+		if err != nil {
+			return issued, err
+		}
+		issued++
+	}
+	return issued, nil
+}
+
+// ClaimCoupon 患者领取活动优惠券
+func (c *CouponService) ClaimCoupon(templateId uint64, patientId uint64) error {
+	return c.issueInTx(templateId, patientId, CouponIssueCampaign, func(template *model.CouponTemplate, now time.Time) error {
+		if template.IssueType != CouponIssueCampaign {
+			return errors.New("该优惠券不支持领取")
+		}
+		if template.ClaimStartAt != nil && now.Before(*template.ClaimStartAt) {
+			return errors.New("活动尚未开始，领取时间：" + utils.FormatTime(*template.ClaimStartAt))
+		}
+		if template.ClaimEndAt != nil && !now.Before(*template.ClaimEndAt) {
+			return errors.New("活动已结束")
+		}
+		return nil
+	})
+}
+
+// GetCampaignList 查询当前可领取的活动优惠券（处于领取时间内且未领完）
+func (c *CouponService) GetCampaignList(page int, size int) ([]model.CouponTemplate, int64, error) {
+	templateList := []model.CouponTemplate{}
+	var total int64
+
+	now := time.Now()
+	query := model.DB.Model(&model.CouponTemplate{}).
+		Where("status = ? AND issue_type = ?", CouponTemplateEnabled, CouponIssueCampaign).
+		Where("(claim_start_at IS NULL OR claim_start_at <= ?) AND (claim_end_at IS NULL OR claim_end_at > ?)", now, now).
+		Where("total_count = 0 OR issued_count < total_count")
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("查询活动优惠券总数失败")
+	}
+
+	offset := (page - 1) * size
+	if err := query.Order("id DESC").Offset(offset).Limit(size).Find(&templateList).Error; err != nil {
+		return nil, 0, errors.New("查询活动优惠券列表失败")
+	}
+	return templateList, total, nil
+}
+
+// GetMyCouponList 患者查询本人优惠券（status：0-未使用，1-已锁定，2-已使用，3-已过期，<0 查询全部）
+func (c *CouponService) GetMyCouponList(patientId uint64, status int, page int, size int) ([]UserCouponView, int64, error) {
+	var couponList []model.UserCoupon
+	var total int64
+
+	now := time.Now()
+	query := model.DB.Model(&model.UserCoupon{}).Where("patient_id = ?", patientId)
+	switch status {
+	case UserCouponUnused:
+		query = query.Where("status = ? AND (valid_until IS NULL OR valid_until > ?)", UserCouponUnused, now)
+	case UserCouponExpired:
+		query = query.Where("status = ? AND valid_until <= ?", UserCouponUnused, now)
+	case UserCouponLocked, UserCouponUsed:
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("查询优惠券总数失败")
+	}
+
+	offset := (page - 1) * size
+	if err := query.Order("id DESC").Offset(offset).Limit(size).Find(&couponList).Error; err != nil {
+		return nil, 0, errors.New("查询优惠券列表失败")
+	}
+
+	// 附带模板的优惠规则
+	templateIds := make([]uint64, 0, len(couponList))
+	for _, coupon := range couponList {
+		templateIds = append(templateIds, coupon.TemplateId)
+	}
+	var templateList []model.CouponTemplate
+	if len(templateIds) > 0 {
+		if err := model.DB.Unscoped().Where("id IN (?)", templateIds).Find(&templateList).Error; err != nil {
+			return nil, 0, errors.New("查询优惠券信息失败")
+		}
+	}
+	templates := map[uint64]*model.CouponTemplate{}
+	for i := range templateList {
+		templates[templateList[i].ID] = &templateList[i]
+	}
+
+	viewList := make([]UserCouponView, 0, len(couponList))
+	for _, coupon := range couponList {
+		viewList = append(viewList, UserCouponView{UserCoupon: coupon, Template: templates[coupon.TemplateId]})
+	}
+	return viewList, total, nil
+}
+
+// errCouponUserLimit 患者持有该优惠券已达上限
+var errCouponUserLimit = errors.New("已达到该优惠券的领取上限")
+
+// errCouponSoldOut 优惠券已发放完毕
+var errCouponSoldOut = errors.New("优惠券已领完")
+
+// issueInTx 发放一张优惠券（独立事务：锁定模板 → 校验（check 可为空）→ 校验持有上限 → 累加发放数量 → 生成患者优惠券）
+func (c *CouponService) issueInTx(templateId uint64, patientId uint64, source int, check func(template *model.CouponTemplate, now time.Time) error) error {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	// 1. 锁定模板（同一模板的发放串行执行）
+	var template model.CouponTemplate
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", templateId).First(&template).Error; err != nil {
+		tx.Rollback()
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("优惠券不存在")
+		}
+		return errors.New("查询优惠券失败")
+	}
+	if template.Status != CouponTemplateEnabled {
+		tx.Rollback()
+		return errors.New("优惠券已停用")
+	}
+	now := time.Now()
+	if check != nil {
+		if err := check(&template, now); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if template.TotalCount > 0 && template.IssuedCount >= template.TotalCount {
+		tx.Rollback()
+		return errCouponSoldOut
+	}
+
+	// 2. 校验患者持有上限
+	if template.PerUserLimit > 0 {
+		var held int
+		if err := tx.Model(&model.UserCoupon{}).Where("template_id = ? AND patient_id = ?", templateId, patientId).Count(&held).Error; err != nil {
+			tx.Rollback()
+			return errors.New("查询已领取优惠券失败")
+		}
+		if held >= template.PerUserLimit {
+			tx.Rollback()
+			return errCouponUserLimit
+		}
+	}
+
+	// 3. 累加发放数量并生成患者优惠券（有效期：模板有效期与领取后有效天数取较早者）
+	if err := tx.Model(&model.CouponTemplate{}).Where("id = ?", templateId).
+		Update("issued_count", gorm.Expr("issued_count + 1")).Error; err != nil {
+		tx.Rollback()
+		return errors.New("更新优惠券发放数量失败")
+	}
+	validUntil := template.ValidEndAt
+	if template.ValidDays > 0 {
+		until := now.AddDate(0, 0, template.ValidDays)
+		if validUntil == nil || until.Before(*validUntil) {
+			validUntil = &until
+		}
+	}
+	coupon := model.UserCoupon{
+		TemplateId: templateId,
+		PatientId:  patientId,
+		Source:     source,
+		Status:     UserCouponUnused,
+		ValidFrom:  template.ValidStartAt,
+		ValidUntil: validUntil,
+	}
+	if err := tx.Create(&coupon).Error; err != nil {
+		tx.Rollback()
+		return errors.New("发放优惠券失败")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return errors.New("发放优惠券事务提交失败")
+	}
+	return nil
+}
+
+// -------------------------- 订单使用 --------------------------
+
+// ApplyOrderCoupon 患者为待确认/待支付订单选用优惠券（userCouponId 为0表示取消使用），发起支付后不可更换，返回更新后的订单
+func (c *CouponService) ApplyOrderCoupon(orderId uint64, patientId uint64, userCouponId uint64) (*model.Order, error) {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+
+	// 1. 锁定订单并校验状态
+	var order model.Order
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ? AND patient_id = ?", orderId, patientId).First(&order).Error; err != nil {
+		tx.Rollback()
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, statemachine.ErrNotParticipant
+		}
+		return nil, errors.New("查询订单失败")
+	}
+	status := statemachine.OrderStatus(order.Status)
+	if status != statemachine.OrderPendingApprove && status != statemachine.OrderPendingPayment {
+		tx.Rollback()
+		return nil, errors.New("订单当前为「" + status.String() + "」状态，无法使用优惠券")
+	}
+	if order.CouponId == userCouponId {
+		tx.Rollback()
+		return &order, nil
+	}
+
+	// 2. 已发起支付的订单不可更换优惠券（避免支付金额与订单应付金额不一致）
+	var pending int
+	if err := tx.Model(&model.Payment{}).Where("order_id = ? AND status = ?", order.ID, PaymentPending).Count(&pending).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("查询支付单失败")
+	}
+	if pending > 0 {
+		tx.Rollback()
+		return nil, errors.New("订单已发起支付，无法更换优惠券")
+	}
+
+	// 3. 退回原优惠券，锁定新优惠券
+	if err := releaseOrderCoupon(tx, &order); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	discount := utils.Money(0)
+	if userCouponId > 0 {
+		var err error
+		if discount, err = lockOrderCoupon(tx, &order, userCouponId); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	// 4. 更新订单优惠
+	updates := map[string]interface{}{"coupon_id": userCouponId, "discount_amount": discount}
+	if err := tx.Model(&model.Order{}).Where("id = ?", order.ID).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("更新订单优惠失败")
+	}
+	order.CouponId, order.DiscountAmount = userCouponId, discount
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("使用优惠券事务提交失败")
+	}
+	return &order, nil
+}
+
+// lockOrderCoupon 校验优惠券可用于订单并锁定（在调用方事务内执行），返回优惠金额
+func lockOrderCoupon(tx *gorm.DB, order *model.Order, userCouponId uint64) (utils.Money, error) {
+	// 1. 锁定优惠券并校验持有人、状态与有效期
+	var coupon model.UserCoupon
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ? AND patient_id = ?", userCouponId, order.PatientId).First(&coupon).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return 0, errors.New("优惠券不存在")
+		}
+		return 0, errors.New("查询优惠券失败")
+	}
+	if coupon.Status != UserCouponUnused {
+		return 0, errors.New("优惠券已使用")
+	}
+	now := time.Now()
+	if coupon.ValidFrom != nil && now.Before(*coupon.ValidFrom) {
+		return 0, errors.New("优惠券未到可用时间，可用时间：" + utils.FormatTime(*coupon.ValidFrom))
+	}
+	if coupon.ValidUntil != nil && !now.Before(*coupon.ValidUntil) {
+		return 0, errors.New("优惠券已过期")
+	}
+
+	// 2. 校验使用范围与门槛
+	var template model.CouponTemplate
+	if err := tx.Unscoped().Where("id = ?", coupon.TemplateId).First(&template).Error; err != nil {
+		return 0, errors.New("查询优惠券信息失败")
+	}
+	var demand model.Demand
+	if err := tx.Where("id = ?", order.DemandId).First(&demand).Error; err != nil {
+		return 0, errors.New("查询关联需求失败")
+	}
+	if !matchLocation(&demand, template.City, template.Hospital) {
+		return 0, errors.New("该优惠券不适用于本次就诊医院")
+	}
+	if order.OrderAmount < template.MinAmount {
+		return 0, errors.New("订单金额未满" + template.MinAmount.String() + "元，无法使用该优惠券")
+	}
+
+	// 3. 计算优惠金额并锁定优惠券
+	discount := calcCouponDiscount(&template, order.OrderAmount)
+	if discount <= 0 {
+		return 0, errors.New("该优惠券对本订单无优惠")
+	}
+	result := tx.Model(&model.UserCoupon{}).Where("id = ? AND status = ?", coupon.ID, UserCouponUnused).Updates(map[string]interface{}{
+		"status":   UserCouponLocked,
+		"order_id": order.ID,
+		"discount": discount,
+	})
+	if result.Error != nil {
+		return 0, errors.New("锁定优惠券失败")
+	}
+	if result.RowsAffected == 0 {
+		return 0, errors.New("优惠券已使用")
+	}
+	return discount, nil
+}
+
+// calcCouponDiscount 计算优惠金额（不超过订单金额且至少保留0.01元实付，折扣按比例四舍五入到分）
+func calcCouponDiscount(template *model.CouponTemplate, amount utils.Money) utils.Money {
+	var discount utils.Money
+	switch template.Type {
+	case CouponFixed, CouponThreshold:
+		discount = template.Amount
+	case CouponPercent:
+		discount = amount.MulRate(template.Rate)
+		if template.MaxDiscount > 0 && discount > template.MaxDiscount {
+			discount = template.MaxDiscount
+		}
+	}
+	if discount > amount-1 {
+		discount = amount - 1
+	}
+	return discount
+}
+
+// releaseOrderCoupon 退回订单锁定的优惠券（恢复为未使用，已过期的券查询时按已过期展示）
+func releaseOrderCoupon(tx *gorm.DB, order *model.Order) error {
+	if order.CouponId == 0 {
+		return nil
+	}
+	if err := tx.Model(&model.UserCoupon{}).Where("id = ? AND order_id = ? AND status = ?", order.CouponId, order.ID, UserCouponLocked).
+		Updates(map[string]interface{}{
+			"status":   UserCouponUnused,
+			"order_id": 0,
+			"discount": 0,
+		}).Error; err != nil {
+		return errors.New("退回优惠券失败")
+	}
+	return nil
+}
+
+// couponSubsidy 退回金额中由平台优惠承担的部分（按优惠金额占订单金额的比例分摊，四舍五入到分）
+func couponSubsidy(order *model.Order, amount utils.Money) utils.Money {
+	if order.DiscountAmount <= 0 || order.OrderAmount <= 0 {
+		return 0
+	}
+	return (amount*order.DiscountAmount*2 + order.OrderAmount) / (order.OrderAmount * 2)
+}
+
+// releaseCouponEffect 未支付订单取消：退回锁定的优惠券
+func releaseCouponEffect(tx *gorm.DB, order *model.Order, actor statemachine.Actor) error {
+	return releaseOrderCoupon(tx, order)
+}
+
+// useCouponEffect 订单支付成功：锁定的优惠券标记为已使用
+func useCouponEffect(tx *gorm.DB, order *model.Order, actor statemachine.Actor) error {
+	if order.CouponId == 0 {
+		return nil
+	}
+	if err := tx.Model(&model.UserCoupon{}).Where("id = ? AND order_id = ? AND status = ?", order.CouponId, order.ID, UserCouponLocked).
+		Updates(map[string]interface{}{
+			"status":  UserCouponUsed,
+			"used_at": time.Now(),
+		}).Error; err != nil {
+		return errors.New("核销优惠券失败")
+	}
+	return nil
+}
+
+// toTemplate 校验模板参数并转换为模板实体
+func (in CouponTemplateInput) toTemplate() (*model.CouponTemplate, error) {
+	// 1. 校验名称与发放方式
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return nil, errors.New("优惠券名称不能为空")
+	}
+	if in.IssueType != CouponIssueAdmin && in.IssueType != CouponIssueCampaign {
+		return nil, errors.New("发放方式无效")
+	}
+
+	// 2. 按类型校验优惠规则
+	if in.MinAmount < 0 || in.MaxDiscount < 0 {
+		return nil, errors.New("金额不能为负数")
+	}
+	switch in.Type {
+	case CouponFixed:
+		if in.Amount <= 0 {
+			return nil, errors.New("立减券需设置优惠金额")
+		}
+	case CouponThreshold:
+		if in.Amount <= 0 || in.MinAmount <= in.Amount {
+			return nil, errors.New("满减券需设置优惠金额，且使用门槛大于优惠金额")
+		}
+	case CouponPercent:
+		if in.Rate <= 0 || in.Rate >= 1 {
+			return nil, errors.New("折扣券优惠比例需在0~1之间")
+		}
+	default:
+		return nil, errors.New("优惠券类型无效")
+	}
+
+	// 3. 校验数量限制
+	if in.ValidDays < 0 || in.TotalCount < 0 || in.PerUserLimit < 0 {
+		return nil, errors.New("有效天数与数量限制不能为负数")
+	}
+
+	// 4. 解析并校验时间
+	var times [4]*time.Time
+	for i, s := range []string{in.ClaimStartAt, in.ClaimEndAt, in.ValidStartAt, in.ValidEndAt} {
+		t, err := parseRuleTime(s)
+		if err != nil {
+			return nil, errors.New("时间格式错误，应为：2006-01-02 15:04:05")
+		}
+		times[i] = t
+	}
+	claimStartAt, claimEndAt, validStartAt, validEndAt := times[0], times[1], times[2], times[3]
+	if claimStartAt != nil && claimEndAt != nil && !claimEndAt.After(*claimStartAt) {
+		return nil, errors.New("领取结束时间需晚于开始时间")
+	}
+	if validStartAt != nil && validEndAt != nil && !validEndAt.After(*validStartAt) {
+		return nil, errors.New("有效期结束时间需晚于开始时间")
+	}
+
+	return &model.CouponTemplate{
+		Name:         name,
+		Type:         in.Type,
+		Amount:       in.Amount,
+		Rate:         in.Rate,
+		MaxDiscount:  in.MaxDiscount,
+		MinAmount:    in.MinAmount,
+		City:         strings.TrimSpace(in.City),
+		Hospital:     strings.TrimSpace(in.Hospital),
+		IssueType:    in.IssueType,
+		ClaimStartAt: claimStartAt,
+		ClaimEndAt:   claimEndAt,
+		ValidStartAt: validStartAt,
+		ValidEndAt:   validEndAt,
+		ValidDays:    in.ValidDays,
+		TotalCount:   in.TotalCount,
+		PerUserLimit: in.PerUserLimit,
+	}, nil
+}
@@ -3,9 +3,12 @@ package service
 
 import (
 	"errors"
+	"log"
 	"time"
 
+	"github.com/X-Colder/companion-backend/conf"
 	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/statemachine"
 	"github.com/X-Colder/companion-backend/utils"
 
 	"github.com/jinzhu/gorm"
@@ -20,10 +23,12 @@ func (d *DemandService) PublishDemand(
 	hospital string,
 	hospitalAddr string,
 	serviceTimeStr string,
-	expectedPrice float64,
+	expectedPrice utils.Money,
 	serviceContent string,
 	contactName string,
 	contactPhone string,
+	bidMode int,
+	designatedId uint64,
 ) error {
 	// 1. 解析服务时间字符串为time.Time类型
 	serviceTime, err := time.Parse("2006-01-02 15:04:05", serviceTimeStr)
@@ -36,24 +41,56 @@ func (d *DemandService) PublishDemand(
 		return errors.New("服务时间不能早于当前时间")
 	}
 
-	// 3. 构造需求实体
+	// 3. 校验指定陪诊师（指定需求仅该陪诊师可见，不支持竞价）
+	var designateUntil *time.Time
+	if designatedId > 0 {
+		if bidMode == 1 {
+			return errors.New("指定陪诊师的需求不支持竞价模式")
+		}
+		if designatedId == patientId {
+			return errors.New("不能指定自己为陪诊师")
+		}
+		var companion model.User
+		if err := model.DB.Where("id = ? AND user_type = ?", designatedId, 2).First(&companion).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return errors.New("指定的陪诊师不存在")
+			}
+			return errors.New("查询陪诊师失败")
+		}
+		until := time.Now().Add(time.Duration(conf.AppConfig.Order.DesignateTimeoutMinutes) * time.Minute)
+		if until.After(serviceTime) {
+			until = serviceTime
+		}
+		designateUntil = &until
+	}
+
+	// 4. 构造需求实体
 	demand := model.Demand{
 		PatientId:      patientId,
 		Hospital:       hospital,
 		HospitalAddr:   hospitalAddr,
 		ServiceTime:    serviceTime,
-		ExpectedPrice:  utils.KeepTwoDecimal(expectedPrice),
+		ExpectedPrice:  expectedPrice,
 		ServiceContent: serviceContent,
 		ContactName:    contactName,
 		ContactPhone:   contactPhone,
-		Status:         0, // 0-待接单
+		Status:         0,       // 0-待接单
+		BidMode:        bidMode, // 0-抢单，1-竞价
+		DesignatedId:   designatedId,
+		DesignateUntil: designateUntil,
 	}
 
-	// 4. 存入数据库
+	// 5. 存入数据库
 	if err := model.DB.Create(&demand).Error; err != nil {
 		return errors.New("发布需求失败")
 	}
 
+	// 6. 通知被指定的陪诊师
+	if designatedId > 0 {
+		notify(designatedId, NoticeDemandInvited, "患者指定您陪诊",
+			"患者邀请您陪同就诊"+demand.Hospital+"（服务时间："+utils.FormatTime(serviceTime)+"），请于"+utils.FormatTime(*designateUntil)+"前接受或拒绝。", demand.ID)
+	}
+
 	return nil
 }
 
@@ -64,7 +101,7 @@ func (d *DemandService) UpdateDemand(
 	hospital string,
 	hospitalAddr string,
 	serviceTimeStr string,
-	expectedPrice float64,
+	expectedPrice utils.Money,
 	serviceContent string,
 	contactName string,
 	contactPhone string,
@@ -92,16 +129,20 @@ func (d *DemandService) UpdateDemand(
 		"hospital":        hospital,
 		"hospital_addr":   hospitalAddr,
 		"service_time":    serviceTime,
-		"expected_price":  utils.KeepTwoDecimal(expectedPrice),
+		"expected_price":  expectedPrice,
 		"service_content": serviceContent,
 		"contact_name":    contactName,
 		"contact_phone":   contactPhone,
 	}
 
-	// 4. 更新数据库
-	if err := model.DB.Model(&model.Demand{}).Where("id = ?", demandId).Updates(updateData).Error; err != nil {
+	// 4. 更新数据库（以仍为待接单为条件，避免与接单并发时修改已被接单的需求）
+	result := model.DB.Model(&model.Demand{}).Where("id = ? AND status = ?", demandId, statemachine.DemandPending).Updates(updateData)
+	if result.Error != nil {
 		return errors.New("修改需求失败")
 	}
+	if result.RowsAffected == 0 {
+		return errors.New("需求已被接单，无法修改")
+	}
 
 	return nil
 }
@@ -126,3 +167,142 @@ func (d *DemandService) GetPatientDemandList(patientId uint64, page int, size in
 
 	return demandList, total, nil
 }
+
+// -------------------------- 指定陪诊师 --------------------------
+
+// DeclineInvite 被指定的陪诊师拒绝邀请（需求公开到订单大厅，并通知患者）
+func (d *DemandService) DeclineInvite(demandId uint64, companionId uint64, reason string) error {
+	var demand model.Demand
+	if err := model.DB.Where("id = ? AND designated_id = ? AND status = ?", demandId, companionId, statemachine.DemandPending).First(&demand).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("邀请不存在或已处理")
+		}
+		return errors.New("查询需求失败")
+	}
+
+	released, err := d.releaseDesignation(demand)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return errors.New("邀请不存在或已处理")
+	}
+
+	notify(demand.PatientId, NoticeInviteDeclined, "指定陪诊师未接受邀请",
+		"您指定的陪诊师无法陪同就诊"+demand.Hospital+"，原因："+reason+"。需求已公开到订单大厅，等待其他陪诊师接单。", demand.ID)
+	return nil
+}
+
+// ReleaseOverdueDesignations 将指定陪诊师逾期未处理的需求公开到订单大厅，并通知患者，返回本次公开数量
+func (d *DemandService) ReleaseOverdueDesignations() (int, error) {
+	// 1. 查询指定截止时间已过的待接单需求
+	var demandList []model.Demand
+	if err := model.DB.Where("status = ? AND designated_id > 0 AND designate_until < ?", statemachine.DemandPending, time.Now()).
+		Order("id ASC").Limit(200).Find(&demandList).Error; err != nil {
+		return 0, errors.New("查询逾期指定需求失败")
+	}
+
+	// 2. 逐条公开（以指定陪诊师为条件更新，多实例并发执行时只有一个实例会成功并发送通知）
+	released := 0
+	for _, demand := range demandList {
+		ok, err := d.releaseDesignation(demand)
+		if err != nil {
+			log.Printf("需求%d公开失败：%s", demand.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		released++
+		notify(demand.PatientId, NoticeInviteDeclined, "指定陪诊师未及时响应",
+			"您指定的陪诊师未在时限内接受邀请，"+demand.Hospital+"陪诊需求已公开到订单大厅，等待其他陪诊师接单。", demand.ID)
+	}
+
+	return released, nil
+}
+
+// releaseDesignation 取消需求的指定陪诊师（条件更新），返回是否由本次调用公开
+func (d *DemandService) releaseDesignation(demand model.Demand) (bool, error) {
+	result := model.DB.Model(&model.Demand{}).
+		Where("id = ? AND designated_id = ? AND status = ?", demand.ID, demand.DesignatedId, statemachine.DemandPending).
+		Updates(map[string]interface{}{
+			"designated_id":   0,
+			"designate_until": nil,
+		})
+	if result.Error != nil {
+		return false, errors.New("公开需求失败")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ExpireStaleDemands 关闭服务时间已过仍未被接单的需求（待接单 → 已过期），并通知患者，返回本次关闭数量
+func (d *DemandService) ExpireStaleDemands() (int, error) {
+	// 1. 查询服务时间已过的待接单需求
+	var demandList []model.Demand
+	if err := model.DB.Where("status = ? AND service_time <= ?", statemachine.DemandPending, time.Now()).
+		Order("id ASC").Limit(200).Find(&demandList).Error; err != nil {
+		return 0, errors.New("查询过期需求失败")
+	}
+
+	// 2. 逐条关闭（以待接单状态为条件更新，多实例并发执行时只有一个实例会成功并发送通知）
+	expired := 0
+	for _, demand := range demandList {
+		closed, err := d.expireDemand(demand)
+		if err != nil {
+			log.Printf("需求%d关闭失败：%s", demand.ID, err)
+			continue
+		}
+		if closed {
+			expired++
+		}
+	}
+
+	return expired, nil
+}
+
+// expireDemand 关闭单条过期需求并通知患者（同一事务），返回是否由本次调用关闭
+func (d *DemandService) expireDemand(demand model.Demand) (bool, error) {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return false, err
+	}
+
+	// 1. 条件更新需求状态（已被接单或已被其他实例关闭时不影响任何行）
+	result := tx.Model(&model.Demand{}).Where("id = ? AND status = ?", demand.ID, statemachine.DemandPending).
+		Update("status", statemachine.DemandExpired)
+	if result.Error != nil {
+		tx.Rollback()
+		return false, errors.New("更新需求状态失败")
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	// 2. 竞价需求的报价随之失效
+	if err := expireDemandBids(tx, demand.ID); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	// 3. 通知患者
+	content := "您发布的" + demand.Hospital + "陪诊需求（服务时间：" + utils.FormatTime(demand.ServiceTime) + "）已过服务时间且无人接单，系统已自动关闭，如仍需陪诊请重新发布。"
+	if err := (&NotificationService{}).Notify(tx, demand.PatientId, NoticeDemandExpired, "陪诊需求已过期", content, demand.ID); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return false, errors.New("关闭需求事务提交失败")
+	}
+
+	return true, nil
+}
@@ -0,0 +1,208 @@
+// service/dispute.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/statemachine"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 争议状态
+const (
+	DisputePending  = 0 // 待裁决
+	DisputeResolved = 1 // 已裁决
+)
+
+// 争议裁决结果
+const (
+	DisputeResultPayout  = 1 // 全额结算给陪诊师
+	DisputeResultPartial = 2 // 部分结算，其余退还患者
+	DisputeResultRefund  = 3 // 全额退款给患者
+)
+
+// disputeResultEvents 裁决结果对应的订单流转事件
+var disputeResultEvents = map[int]statemachine.Event{
+	DisputeResultPayout:  statemachine.EventDisputePayout,
+	DisputeResultPartial: statemachine.EventDisputePartial,
+	DisputeResultRefund:  statemachine.EventDisputeRefund,
+}
+
+// DisputeService 订单争议服务
+type DisputeService struct{}
+
+// OpenDispute 订单参与方发起争议（仅服务中/待结算订单，发起后订单冻结结算），返回争议ID
+func (d *DisputeService) OpenDispute(orderId uint64, userId uint64, description string, evidenceUrls string, clientIP string) (uint64, error) {
+	// 1. 查询订单，确定发起人角色
+	var order model.Order
+	if err := model.DB.Where("id = ? AND (patient_id = ? OR companion_id = ?)", orderId, userId, userId).First(&order).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return 0, statemachine.ErrNotParticipant
+		}
+		return 0, errors.New("查询订单失败")
+	}
+	role, counterpartId := statemachine.RolePatient, order.CompanionId
+	if order.CompanionId == userId {
+		role, counterpartId = statemachine.RoleCompanion, order.PatientId
+	}
+
+	// 2. 创建争议记录并流转订单（服务中/待结算 → 争议中）
+	var disputeId uint64
+	actor := statemachine.Actor{Id: userId, Role: role, ClientIP: clientIP}
+	updated, err := (&OrderService{}).transitOrderAndGet(orderId, statemachine.EventOpenDispute, actor, description, func(tx *gorm.DB, order *model.Order) (map[string]interface{}, error) {
+		dispute := model.Dispute{
+			OrderId:       order.ID,
+			InitiatorId:   userId,
+			InitiatorRole: int(role),
+			OrderStatus:   order.Status,
+			Description:   description,
+			EvidenceUrls:  evidenceUrls,
+			Status:        DisputePending,
+		}
+		if err := tx.Create(&dispute).Error; err != nil {
+			return nil, errors.New("创建争议记录失败")
+		}
+		disputeId = dispute.ID
+		return nil, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	notify(counterpartId, NoticeDisputeOpened, "订单进入争议处理", "对方对订单"+updated.OrderNo+"发起了争议，平台将介入裁决，裁决前订单暂停结算。", updated.ID)
+	return disputeId, nil
+}
+
+// GetOrderDispute 订单参与方查询订单的争议记录
+func (d *DisputeService) GetOrderDispute(orderId uint64, userId uint64) (*model.Dispute, error) {
+	var order model.Order
+	if err := model.DB.Where("id = ? AND (patient_id = ? OR companion_id = ?)", orderId, userId, userId).First(&order).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, statemachine.ErrNotParticipant
+		}
+		return nil, errors.New("查询订单失败")
+	}
+
+	var dispute model.Dispute
+	if err := model.DB.Where("order_id = ?", orderId).Order("id DESC").First(&dispute).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("该订单暂无争议记录")
+		}
+		return nil, errors.New("查询争议记录失败")
+	}
+	return &dispute, nil
+}
+
+// GetDisputeList 管理员查询争议列表（status<0 表示不筛选状态）
+func (d *DisputeService) GetDisputeList(status int, page int, size int) ([]model.Dispute, int64, error) {
+	var disputeList []model.Dispute
+	var total int64
+
+	query := model.DB.Model(&model.Dispute{})
+	if status >= 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("查询争议总数失败")
+	}
+
+	offset := (page - 1) * size
+	if err := query.Order("created_at ASC").Offset(offset).Limit(size).Find(&disputeList).Error; err != nil {
+		return nil, 0, errors.New("查询争议列表失败")
+	}
+	return disputeList, total, nil
+}
+
+// ResolveDispute 管理员裁决争议（全额结算 / 按比例部分结算 / 全额退款），结算在订单流转事务内完成
+func (d *DisputeService) ResolveDispute(disputeId uint64, adminId uint64, result int, payoutRate float64, remark string, clientIP string) error {
+	// 1. 校验裁决结果
+	event, ok := disputeResultEvents[result]
+	if !ok {
+		return errors.New("裁决结果无效")
+	}
+	if result == DisputeResultPartial && (payoutRate <= 0 || payoutRate >= 1) {
+		return errors.New("部分结算比例需介于0与1之间")
+	}
+
+	// 2. 查询待裁决的争议
+	var dispute model.Dispute
+	if err := model.DB.Where("id = ? AND status = ?", disputeId, DisputePending).First(&dispute).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("争议不存在或已裁决")
+		}
+		return errors.New("查询争议失败")
+	}
+
+	// 3. 记录裁决结果并流转订单（争议中 → 已完成/已取消），结算由 settle_dispute 副作用完成
+	actor := statemachine.Actor{Id: adminId, Role: statemachine.RoleAdmin, ClientIP: clientIP}
+	order, err := (&OrderService{}).transitOrderAndGet(dispute.OrderId, event, actor, remark, func(tx *gorm.DB, order *model.Order) (map[string]interface{}, error) {
+		companionAmount, refundAmount := order.CompanionIncome, utils.Money(0)
+		switch result {
+		case DisputeResultPartial:
+			companionAmount = order.CompanionIncome.MulRate(payoutRate)
+			refundAmount = order.OrderAmount - order.OrderAmount.MulRate(payoutRate)
+		case DisputeResultRefund:
+			companionAmount, refundAmount = 0, order.OrderAmount
+		}
+
+		now := time.Now()
+		res := tx.Model(&model.Dispute{}).Where("id = ? AND status = ?", dispute.ID, DisputePending).Updates(map[string]interface{}{
+			"status":           DisputeResolved,
+			"result":           result,
+			"payout_rate":      payoutRate,
+			"companion_amount": companionAmount,
+			"refund_amount":    refundAmount,
+			"admin_id":         adminId,
+			"resolve_remark":   remark,
+			"resolved_at":      now,
+		})
+		if res.Error != nil {
+			return nil, errors.New("更新争议裁决结果失败")
+		}
+		if res.RowsAffected == 0 {
+			return nil, errors.New("争议已被裁决，请刷新后重试")
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	content := "订单" + order.OrderNo + "的争议已裁决，裁决说明：" + remark
+	notify(order.PatientId, NoticeDisputeResolved, "订单争议已裁决", content, order.ID)
+	notify(order.CompanionId, NoticeDisputeResolved, "订单争议已裁决", content, order.ID)
+
+	// 发起裁决退款（失败由定时任务重试）
+	(&RefundService{}).ProcessOrderRefunds(order.ID)
+	return nil
+}
+
+// settleDisputeEffect 按裁决结果结算：陪诊师入账（1-服务收入），患者退款（7-退款，未经预付的历史订单不退款），平台保留其余部分
+func settleDisputeEffect(tx *gorm.DB, order *model.Order, actor statemachine.Actor) error {
+	var dispute model.Dispute
+	if err := tx.Where("order_id = ? AND status = ?", order.ID, DisputeResolved).Order("id DESC").First(&dispute).Error; err != nil {
+		return errors.New("查询争议裁决结果失败")
+	}
+
+	// 记账：订单金额由托管账户转出，分别计入陪诊师收入（按冻结期规则）、患者退款，差额为平台佣金
+	income, err := incomePosting(tx, order.CompanionId, order.OrderNo, dispute.CompanionAmount)
+	if err != nil {
+		return err
+	}
+	refunds, err := refundPostings(tx, order, dispute.RefundAmount, fmt.Sprintf("订单%s争议裁决退款", order.OrderNo))
+	if err != nil {
+		return err
+	}
+	return postEntry(tx, utils.GenerateSerialNo("DSP"), BizDisputeSettle, order.OrderNo, fmt.Sprintf("订单%s争议裁决结算", order.OrderNo),
+		append([]Posting{
+			{AccountType: escrowSource(order), Amount: -order.OrderAmount},
+			income,
+			{AccountType: AccountPlatformRevenue, Amount: order.OrderAmount - dispute.CompanionAmount - dispute.RefundAmount},
+		}, refunds...)...,
+	)
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/statemachine"
 	"github.com/jinzhu/gorm"
 )
 
@@ -30,7 +31,7 @@ func (e *EvalService) PatientEvalCompanion(orderId uint64, patientId uint64, sco
 
 	// 1. 查询订单信息，校验评价合法性
 	var order model.Order
-	if err := tx.Where("id = ? AND patient_id = ? AND status = ?", orderId, patientId, 4).First(&order).Error; err != nil {
+	if err := tx.Where("id = ? AND patient_id = ? AND status = ?", orderId, patientId, statemachine.OrderCompleted).First(&order).Error; err != nil {
 		tx.Rollback()
 		if gorm.IsRecordNotFoundError(err) {
 			return errors.New("订单不存在、非本人订单或非已完成状态，无法评价")
@@ -93,7 +94,7 @@ func (e *EvalService) CompanionEvalPatient(orderId uint64, companionId uint64, s
 
 	// 1. 查询订单信息，校验评价合法性
 	var order model.Order
-	if err := tx.Where("id = ? AND companion_id = ? AND status = ?", orderId, companionId, 4).First(&order).Error; err != nil {
+	if err := tx.Where("id = ? AND companion_id = ? AND status = ?", orderId, companionId, statemachine.OrderCompleted).First(&order).Error; err != nil {
 		tx.Rollback()
 		if gorm.IsRecordNotFoundError(err) {
 			return errors.New("订单不存在、非本人订单或非已完成状态，无法评价")
@@ -0,0 +1,108 @@
+// service/idempotency.go
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 幂等记录状态
+const (
+	IdempotencyProcessing = 0 // 处理中
+	IdempotencyCompleted  = 1 // 已完成
+)
+
+// 幂等校验错误
+var (
+	ErrIdempotencyKeyReused   = errors.New("Idempotency-Key 已用于其他请求，请更换后重试")
+	ErrIdempotencyInProgress  = errors.New("相同请求正在处理中，请勿重复提交")
+	ErrIdempotencyUnavailable = errors.New("幂等校验失败，请稍后重试")
+)
+
+// IdempotencyService 幂等请求服务
+type IdempotencyService struct{}
+
+// Begin 登记一次幂等请求：
+// 首次请求登记为处理中并返回 nil 记录；重复请求且已完成时返回原始记录用于重放；
+// 请求摘要不一致返回 ErrIdempotencyKeyReused，原请求尚未完成返回 ErrIdempotencyInProgress
+func (i *IdempotencyService) Begin(userId uint64, key string, method string, path string, requestHash string) (*model.IdempotencyRecord, error) {
+	now := time.Now()
+
+	// 1. 查询已有记录（过期记录直接清除，Key 可重新使用）
+	var record model.IdempotencyRecord
+	err := model.DB.Where("user_id = ? AND idem_key = ?", userId, key).First(&record).Error
+	if err == nil && record.ExpireAt.Before(now) {
+		if err := model.DB.Where("id = ? AND expire_at < ?", record.ID, now).Delete(&model.IdempotencyRecord{}).Error; err != nil {
+			return nil, ErrIdempotencyUnavailable
+		}
+		err = gorm.ErrRecordNotFound
+	}
+	if err == nil {
+		return i.checkDuplicate(&record, requestHash)
+	}
+	if !gorm.IsRecordNotFoundError(err) {
+		return nil, ErrIdempotencyUnavailable
+	}
+
+	// 2. 登记处理中（唯一索引保证并发的相同 Key 只有一个请求能登记成功）
+	record = model.IdempotencyRecord{
+		UserId:      userId,
+		IdemKey:     key,
+		Method:      method,
+		Path:        path,
+		RequestHash: requestHash,
+		Status:      IdempotencyProcessing,
+		ExpireAt:    now.Add(time.Duration(conf.AppConfig.Idempotency.TtlMinutes) * time.Minute),
+	}
+	if err := model.DB.Create(&record).Error; err != nil {
+		// 登记失败多为并发请求抢先登记，按已有记录处理
+		var exist model.IdempotencyRecord
+		if model.DB.Where("user_id = ? AND idem_key = ?", userId, key).First(&exist).Error != nil {
+			return nil, ErrIdempotencyUnavailable
+		}
+		return i.checkDuplicate(&exist, requestHash)
+	}
+	return nil, nil
+}
+
+// checkDuplicate 校验重复请求：摘要一致且已完成时返回原始记录
+func (i *IdempotencyService) checkDuplicate(record *model.IdempotencyRecord, requestHash string) (*model.IdempotencyRecord, error) {
+	if record.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if record.Status != IdempotencyCompleted {
+		return nil, ErrIdempotencyInProgress
+	}
+	return record, nil
+}
+
+// Complete 保存请求的原始响应（后续重复请求原样重放）
+func (i *IdempotencyService) Complete(userId uint64, key string, statusCode int, responseBody string) error {
+	return model.DB.Model(&model.IdempotencyRecord{}).
+		Where("user_id = ? AND idem_key = ? AND status = ?", userId, key, IdempotencyProcessing).
+		Updates(map[string]interface{}{
+			"status":        IdempotencyCompleted,
+			"status_code":   statusCode,
+			"response_body": responseBody,
+		}).Error
+}
+
+// Release 删除处理中的记录（请求异常未产生有效响应时调用，允许客户端用同一 Key 重试）
+func (i *IdempotencyService) Release(userId uint64, key string) error {
+	return model.DB.Where("user_id = ? AND idem_key = ? AND status = ?", userId, key, IdempotencyProcessing).
+		Delete(&model.IdempotencyRecord{}).Error
+}
+
+// CleanExpired 清理已过期的幂等记录，返回清理条数
+func (i *IdempotencyService) CleanExpired() (int64, error) {
+	result := model.DB.Where("expire_at < ?", time.Now()).Delete(&model.IdempotencyRecord{})
+	if result.Error != nil {
+		return 0, errors.New("清理过期幂等记录失败")
+	}
+	return result.RowsAffected, nil
+}
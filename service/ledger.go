@@ -0,0 +1,213 @@
+// service/ledger.go
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 账本账户类型
+const (
+	AccountPlatformRevenue    = "platform_revenue"    // 平台收入（佣金、陪诊师违约金）
+	AccountPlatformClearing   = "platform_clearing"   // 平台资金清算（用户付款流入、提现打款流出的外部资金对手方）
+	AccountCompanionAvailable = "companion_available" // 陪诊师可用余额（可提现）
+	AccountCompanionFrozen    = "companion_frozen"    // 陪诊师冻结余额（暂不可提现）
+	AccountPayoutInTransit    = "payout_in_transit"   // 提现在途（已从陪诊师余额扣出、尚未确认打款结果）
+	AccountPatientBalance     = "patient_balance"     // 患者余额（退款与违约金往来）
+	AccountOrderEscrow        = "order_escrow"        // 订单托管（患者预付款，服务结算时划给陪诊师与平台）
+	AccountRefundInTransit    = "refund_in_transit"   // 退款在途（已确定退回患者、尚未确认渠道退款成功）
+	AccountPlatformMarketing  = "platform_marketing"  // 平台营销费用（优惠券补贴，余额为负表示累计补贴金额）
+)
+
+// 余额明细类型（与 balance_records.type 取值一致，记在用户账户的分录行上）
+const (
+	RecordIncome             = 1  // 服务收入
+	RecordWithdrawSuccess    = 2  // 提现成功
+	RecordWithdrawFail       = 3  // 提现失败
+	RecordWithdrawing        = 4  // 提现中
+	RecordCancelPenalty      = 5  // 取消违约金
+	RecordCancelCompensation = 6  // 取消补偿
+	RecordRefund             = 7  // 退款
+	RecordOpening            = 8  // 期初余额（账本上线前的历史余额）
+	RecordTip                = 9  // 打赏（与服务收入分开展示）
+	RecordAdjustment         = 10 // 余额调整（对账修正）
+)
+
+// 记账业务类型
+const (
+	BizOrderSettle     = "order_settle"     // 订单结算
+	BizCancelPenalty   = "cancel_penalty"   // 取消违约金
+	BizDisputeSettle   = "dispute_settle"   // 争议裁决结算
+	BizWithdrawApply   = "withdraw_apply"   // 提现申请
+	BizWithdrawSuccess = "withdraw_success" // 提现打款成功
+	BizWithdrawFail    = "withdraw_fail"    // 提现失败退回
+	BizOpeningBalance  = "opening_balance"  // 期初余额
+	BizIncomeRelease   = "income_release"   // 冻结收入解冻
+	BizOrderPayment    = "order_payment"    // 患者预付订单款
+	BizCancelRefund    = "cancel_refund"    // 取消订单退回预付款
+	BizPaymentReturn   = "payment_return"   // 订单已关闭后到账的支付款退回
+	BizRefundSuccess   = "refund_success"   // 渠道退款成功
+	BizTip             = "tip"              // 患者打赏
+	BizBalanceAdjust   = "balance_adjust"   // 对账余额调整
+)
+
+// ErrLedgerUnbalanced 记账凭证借贷不平衡
+var ErrLedgerUnbalanced = errors.New("记账凭证借贷不平衡")
+
+// Posting 分录行（记账时传入）
+type Posting struct {
+	AccountType string      // 账户类型
+	OwnerId     uint64      // 账户所属用户ID（平台账户为0）
+	Amount      utils.Money // 变动金额（增加为正，减少为负）
+	RecordType  int         // 对用户展示的明细类型（平台账户为0）
+}
+
+// userBalanceAccounts 与 users.balance 同步的账户类型（users.balance 为该账户余额的冗余，便于用户信息展示）
+var userBalanceAccounts = map[string]bool{
+	AccountCompanionAvailable: true,
+	AccountPatientBalance:     true,
+}
+
+// LedgerService 账本服务
+type LedgerService struct{}
+
+// postEntry 在调用方事务内记一张凭证：校验借贷平衡 → 写凭证与分录行 → 累加账户余额（并同步 users.balance）
+func postEntry(tx *gorm.DB, entryNo string, bizType string, bizNo string, remark string, postings ...Posting) error {
+	// 1. 校验借贷平衡（分录行金额之和必须为0）
+	var sum utils.Money
+	nonZero := 0
+	for _, p := range postings {
+		sum += p.Amount
+		if p.Amount != 0 {
+			nonZero++
+		}
+	}
+	if sum != 0 || nonZero < 2 {
+		return ErrLedgerUnbalanced
+	}
+
+	// 2. 写凭证
+	entry := model.LedgerEntry{
+		EntryNo: entryNo,
+		BizType: bizType,
+		BizNo:   bizNo,
+		Remark:  remark,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return errors.New("生成记账凭证失败")
+	}
+
+	// 3. 逐行记账
+	for _, p := range postings {
+		if p.Amount == 0 {
+			continue
+		}
+		account, err := ensureAccount(tx, p.AccountType, p.OwnerId)
+		if err != nil {
+			return err
+		}
+		posting := model.LedgerPosting{
+			EntryId:    entry.ID,
+			AccountId:  account.ID,
+			Amount:     p.Amount,
+			RecordType: p.RecordType,
+		}
+		if err := tx.Create(&posting).Error; err != nil {
+			return errors.New("生成分录失败")
+		}
+		if err := tx.Model(&model.LedgerAccount{}).Where("id = ?", account.ID).
+			Update("balance", gorm.Expr("balance + CAST(? AS DECIMAL(12,2))", p.Amount)).Error; err != nil {
+			return errors.New("更新账户余额失败")
+		}
+		if userBalanceAccounts[p.AccountType] {
+			if err := tx.Model(&model.User{}).Where("id = ?", p.OwnerId).
+				Update("balance", gorm.Expr("balance + CAST(? AS DECIMAL(10,2))", p.Amount)).Error; err != nil {
+				return errors.New("更新账户余额失败")
+			}
+		}
+	}
+	return nil
+}
+
+// ensureAccount 查询账户，不存在则创建（唯一索引保证并发创建时只有一条）
+func ensureAccount(tx *gorm.DB, accountType string, ownerId uint64) (*model.LedgerAccount, error) {
+	now := time.Now()
+	if err := tx.Exec("INSERT IGNORE INTO ledger_accounts (account_type, owner_id, balance, created_at, updated_at) VALUES (?, ?, 0, ?, ?)",
+		accountType, ownerId, now, now).Error; err != nil {
+		return nil, errors.New("创建账本账户失败")
+	}
+	var account model.LedgerAccount
+	if err := tx.Where("account_type = ? AND owner_id = ?", accountType, ownerId).First(&account).Error; err != nil {
+		return nil, errors.New("查询账本账户失败")
+	}
+	return &account, nil
+}
+
+// lockAccountBalance 锁定账户（不存在时开立）并返回当前余额，用于扣款前在同一事务内校验余额
+func lockAccountBalance(tx *gorm.DB, accountType string, ownerId uint64) (utils.Money, error) {
+	account, err := ensureAccount(tx, accountType, ownerId)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", account.ID).First(account).Error; err != nil {
+		return 0, errors.New("查询账本账户失败")
+	}
+	return account.Balance, nil
+}
+
+// GetAccountBalance 查询账户余额（账户不存在视为0）
+func (l *LedgerService) GetAccountBalance(accountType string, ownerId uint64) (utils.Money, error) {
+	var account model.LedgerAccount
+	if err := model.DB.Where("account_type = ? AND owner_id = ?", accountType, ownerId).First(&account).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return 0, nil
+		}
+		return 0, errors.New("查询账本账户失败")
+	}
+	return account.Balance, nil
+}
+
+// OpenLegacyBalances 将账本上线前的 users.balance 记为期初余额（仅处理尚未开立账本账户的用户，可重复执行）
+func (l *LedgerService) OpenLegacyBalances() error {
+	var users []model.User
+	if err := model.DB.Where("balance <> 0 AND user_type IN (?) AND NOT EXISTS (SELECT 1 FROM ledger_accounts a WHERE a.owner_id = users.id AND a.account_type IN (?))",
+		[]int{1, 2}, []string{AccountCompanionAvailable, AccountPatientBalance}).Find(&users).Error; err != nil {
+		return errors.New("查询历史余额失败")
+	}
+
+	for _, user := range users {
+		accountType := AccountPatientBalance
+		if user.UserType == 2 {
+			accountType = AccountCompanionAvailable
+		}
+		tx := model.DB.Begin()
+		if err := tx.Error; err != nil {
+			return err
+		}
+		// 记账会同步累加 users.balance，而历史余额已在其中，记账后扣回同等金额
+		if err := postEntry(tx, utils.GenerateSerialNo("OPN"), BizOpeningBalance, "", "账本上线期初余额",
+			Posting{AccountType: accountType, OwnerId: user.ID, Amount: user.Balance, RecordType: RecordOpening},
+			Posting{AccountType: AccountPlatformClearing, Amount: -user.Balance},
+		); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Model(&model.User{}).Where("id = ?", user.ID).
+			Update("balance", gorm.Expr("balance - CAST(? AS DECIMAL(10,2))", user.Balance)).Error; err != nil {
+			tx.Rollback()
+			return errors.New("更新账户余额失败")
+		}
+		if err := tx.Commit().Error; err != nil {
+			tx.Rollback()
+			return errors.New("期初余额事务提交失败")
+		}
+		log.Printf("账本期初余额：用户%d 期初余额%s", user.ID, user.Balance.String())
+	}
+	return nil
+}
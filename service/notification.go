@@ -0,0 +1,99 @@
+// service/notification.go
+package service
+
+import (
+	"errors"
+	"log"
+
+	"github.com/X-Colder/companion-backend/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 通知类型
+const (
+	NoticeDemandExpired      = "demand_expired"      // 需求过期关闭
+	NoticeOrderTaken         = "order_taken"         // 陪诊师接单，待患者确认
+	NoticeOrderApproved      = "order_approved"      // 患者已确认陪诊师
+	NoticeOrderRejected      = "order_rejected"      // 患者拒绝或超时未确认，订单取消
+	NoticeBidAccepted        = "bid_accepted"        // 报价中标
+	NoticeBidRejected        = "bid_rejected"        // 报价未中标
+	NoticeDemandInvited      = "demand_invited"      // 患者指定陪诊师
+	NoticeInviteDeclined     = "invite_declined"     // 指定陪诊师拒绝或超时，需求已公开
+	NoticeInviteAccepted     = "invite_accepted"     // 指定陪诊师已接受
+	NoticeRescheduleProposed = "reschedule_proposed" // 对方发起改约
+	NoticeRescheduleAnswered = "reschedule_answered" // 改约申请已处理
+	NoticeDisputeOpened      = "dispute_opened"      // 对方发起争议，订单结算冻结
+	NoticeDisputeResolved    = "dispute_resolved"    // 争议已裁决
+	NoticeWithdrawApproved   = "withdraw_approved"   // 提现审核通过
+	NoticeWithdrawRejected   = "withdraw_rejected"   // 提现被驳回或打款失败，金额已退回
+	NoticeOrderPaid          = "order_paid"          // 患者已支付订单
+	NoticePayTimeout         = "pay_timeout"         // 订单超时未支付已取消
+	NoticeRefundSucceeded    = "refund_succeeded"    // 退款已原路退回
+	NoticeRefundFailed       = "refund_failed"       // 退款多次失败，转人工处理
+	NoticeTipReceived        = "tip_received"        // 收到患者打赏
+)
+
+// NotificationService 站内通知服务
+type NotificationService struct{}
+
+// Notify 发送站内通知（db 可传入事务，保证通知与业务变更同时生效）
+func (n *NotificationService) Notify(db *gorm.DB, userId uint64, noticeType string, title string, content string, relateId uint64) error {
+	notice := model.Notification{
+		UserId:   userId,
+		Type:     noticeType,
+		Title:    title,
+		Content:  content,
+		RelateId: relateId,
+	}
+	if err := db.Create(&notice).Error; err != nil {
+		return errors.New("发送通知失败")
+	}
+	return nil
+}
+
+// notify 发送站内通知（业务已提交后调用，失败仅记录日志，不影响主流程）
+func notify(userId uint64, noticeType string, title string, content string, relateId uint64) {
+	if err := (&NotificationService{}).Notify(model.DB, userId, noticeType, title, content, relateId); err != nil {
+		log.Printf("发送通知失败（用户%d，类型%s）：%s", userId, noticeType, err)
+	}
+}
+
+// GetUserNotificationList 查询用户通知列表（带分页，可仅查未读）
+func (n *NotificationService) GetUserNotificationList(userId uint64, unreadOnly bool, page int, size int) ([]model.Notification, int64, error) {
+	var noticeList []model.Notification
+	var total int64
+
+	// 1. 计算分页偏移量
+	offset := (page - 1) * size
+
+	// 2. 构造查询条件
+	query := model.DB.Where("user_id = ?", userId)
+	if unreadOnly {
+		query = query.Where("is_read = ?", 0)
+	}
+
+	// 3. 查询总数
+	if err := query.Model(&model.Notification{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 4. 查询分页数据（最新通知优先）
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(size).Find(&noticeList).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return noticeList, total, nil
+}
+
+// MarkNotificationRead 标记通知为已读（noticeId 为0时标记全部）
+func (n *NotificationService) MarkNotificationRead(userId uint64, noticeId uint64) error {
+	query := model.DB.Model(&model.Notification{}).Where("user_id = ? AND is_read = ?", userId, 0)
+	if noticeId > 0 {
+		query = query.Where("id = ?", noticeId)
+	}
+	if err := query.Update("is_read", 1).Error; err != nil {
+		return errors.New("标记已读失败")
+	}
+	return nil
+}
@@ -2,9 +2,15 @@
 package service
 
 import (
+	"crypto/subtle"
 	"errors"
+	"log"
+	"strconv"
+	"time"
 
+	"github.com/X-Colder/companion-backend/conf"
 	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/statemachine"
 	"github.com/X-Colder/companion-backend/utils"
 
 	"github.com/jinzhu/gorm"
@@ -13,6 +19,22 @@ import (
 // OrderService 订单服务
 type OrderService struct{}
 
+// ErrDemandTaken 需求已被其他陪诊师抢先接单（并发接单的失败方收到此错误）
+var ErrDemandTaken = errors.New("手慢了，该需求已被其他陪诊师接单")
+
+// orderMachine 订单状态机（副作用实现见文件末尾「状态流转副作用」）
+var orderMachine = statemachine.NewOrderMachine(map[statemachine.Effect]statemachine.EffectFunc{
+	statemachine.EffectResetDemand:     resetDemandEffect,
+	statemachine.EffectConfirmDemand:   confirmDemandEffect,
+	statemachine.EffectCreditCompanion: creditCompanionEffect,
+	statemachine.EffectCancelPenalty:   cancelPenaltyEffect,
+	statemachine.EffectSettleDispute:   settleDisputeEffect,
+	statemachine.EffectAwaitPayment:    awaitPaymentEffect,
+	statemachine.EffectHoldEscrow:      holdEscrowEffect,
+	statemachine.EffectReleaseCoupon:   releaseCouponEffect,
+	statemachine.EffectUseCoupon:       useCouponEffect,
+})
+
 // -------------------------- 陪诊师相关业务 --------------------------
 
 // GetUndertakeDemandList 获取订单大厅（待接单需求列表，带分页）
@@ -23,21 +45,24 @@ func (o *OrderService) GetUndertakeDemandList(page int, size int) ([]model.Deman
 	// 计算分页偏移量
 	offset := (page - 1) * size
 
-	// 查询待接单需求总数（status=0）
-	if err := model.DB.Where("status = ?", 0).Model(&model.Demand{}).Count(&total).Error; err != nil {
+	// 待接单、服务时间未过且未指定陪诊师的需求（过期需求由定时任务关闭，此处同时过滤尚未关闭的）
+	query := model.DB.Where("status = ? AND service_time > ? AND designated_id = 0", statemachine.DemandPending, time.Now())
+
+	// 查询待接单需求总数
+	if err := query.Model(&model.Demand{}).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	// 查询分页数据，按创建时间倒序
-	if err := model.DB.Where("status = ?", 0).Order("created_at DESC").Offset(offset).Limit(size).Find(&demandList).Error; err != nil {
+	if err := query.Order("created_at DESC").Offset(offset).Limit(size).Find(&demandList).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return demandList, total, nil
 }
 
-// TakeOrder 接单操作（生成订单，更新需求状态）
-func (o *OrderService) TakeOrder(demandId uint64, companionId uint64) error {
+// TakeOrder 接单操作（生成待确认订单，需求进入待确认状态，等待患者确认）
+func (o *OrderService) TakeOrder(demandId uint64, companionId uint64, clientIP string) error {
 	// 开启事务（多表操作，保证数据一致性）
 	tx := model.DB.Begin()
 	defer func() {
@@ -50,60 +75,109 @@ func (o *OrderService) TakeOrder(demandId uint64, companionId uint64) error {
 		return err
 	}
 
-	// 1. 查询需求：必须是待接单状态（status=0），且未被其他陪诊师接单
+	// 1. 查询需求：必须是待接单状态，且未被其他陪诊师接单
 	var demand model.Demand
-	if err := tx.Where("id = ? AND status = ?", demandId, 0).First(&demand).Error; err != nil {
+	if err := tx.Where("id = ? AND status = ?", demandId, statemachine.DemandPending).First(&demand).Error; err != nil {
 		tx.Rollback()
 		if gorm.IsRecordNotFoundError(err) {
+			// 公开需求已被其他陪诊师抢先接单时，与并发抢占失败方返回相同错误
+			var takenCount int
+			if model.DB.Model(&model.Demand{}).Where("id = ? AND designated_id = 0 AND order_id > 0", demandId).Count(&takenCount).Error == nil && takenCount > 0 {
+				return ErrDemandTaken
+			}
 			return errors.New("需求不存在或已被接单")
 		}
 		return errors.New("查询需求失败")
 	}
 
-	// 2. 校验陪诊师身份（避免自己接自己的需求，若需求发布者是陪诊师）
-	if demand.PatientId == companionId {
+	// 2. 指定陪诊师的需求仅被指定人可见，须通过接受邀请接单
+	if demand.DesignatedId > 0 {
 		tx.Rollback()
-		return errors.New("不能接自己发布的需求")
+		if demand.DesignatedId != companionId {
+			return errors.New("需求不存在或已被接单")
+		}
+		return errors.New("该需求为患者指定给您的邀请，请在邀请列表中接受")
 	}
 
-	// 3. 生成唯一订单编号
-	orderNo := utils.GenerateOrderNo()
+	// 3. 竞价模式的需求需提交报价，由患者选择
+	if demand.BidMode == 1 {
+		tx.Rollback()
+		return errors.New("该需求为竞价模式，请提交报价等待患者选择")
+	}
 
-	// 4. 计算订单金额与陪诊师收入（默认扣除10%平台佣金，可配置）
-	orderAmount := utils.KeepTwoDecimal(demand.ExpectedPrice)
-	commissionRate := 0.1 // 10%佣金
-	companionIncome := utils.KeepTwoDecimal(orderAmount * (1 - commissionRate))
+	// 4. 生成订单（按期望价格，待患者确认，超时按配置自动确认或退回订单大厅）
+	approveDeadline := time.Now().Add(time.Duration(conf.AppConfig.Order.ApproveTimeoutMinutes) * time.Minute)
+	actor := statemachine.Actor{Id: companionId, Role: statemachine.RoleCompanion, ClientIP: clientIP}
+	order, err := o.createOrderForDemand(tx, &demand, companionId, demand.ExpectedPrice, &approveDeadline, actor, "")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 
-	// 5. 创建订单
-	order := model.Order{
-		OrderNo:         orderNo,
-		DemandId:        demandId,
-		PatientId:       demand.PatientId,
-		CompanionId:     companionId,
-		OrderAmount:     orderAmount,
-		CompanionIncome: companionIncome,
-		Status:          1, // 1-待服务
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return errors.New("接单事务提交失败")
 	}
-	if err := tx.Create(&order).Error; err != nil {
+
+	// 通知患者确认
+	notify(demand.PatientId, NoticeOrderTaken, "有陪诊师接单，请确认",
+		"您发布的"+demand.Hospital+"陪诊需求已被陪诊师接单，请于"+utils.FormatTime(approveDeadline)+"前确认或拒绝，逾期将由系统自动处理。", order.ID)
+
+	return nil
+}
+
+// GetInviteDemandList 获取患者指定给当前陪诊师、尚待处理的需求列表
+func (o *OrderService) GetInviteDemandList(companionId uint64) ([]model.Demand, error) {
+	var demandList []model.Demand
+	if err := model.DB.Where("status = ? AND designated_id = ? AND service_time > ?", statemachine.DemandPending, companionId, time.Now()).
+		Order("designate_until ASC").Find(&demandList).Error; err != nil {
+		return nil, errors.New("查询邀请列表失败")
+	}
+	return demandList, nil
+}
+
+// AcceptInvite 被指定的陪诊师接受邀请（患者已选定陪诊师，订单直接进入待支付）
+func (o *OrderService) AcceptInvite(demandId uint64, companionId uint64, clientIP string) error {
+	// 开启事务
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	// 1. 查询需求：指定给当前陪诊师且仍待接单
+	var demand model.Demand
+	if err := tx.Where("id = ? AND designated_id = ? AND status = ?", demandId, companionId, statemachine.DemandPending).First(&demand).Error; err != nil {
 		tx.Rollback()
-		return errors.New("生成订单失败")
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("邀请不存在或已失效")
+		}
+		return errors.New("查询需求失败")
 	}
 
-	// 6. 更新需求状态（0-待接单 → 1-已接单），并关联订单ID
-	if err := tx.Model(&model.Demand{}).Where("id = ?", demandId).Updates(map[string]interface{}{
-		"status":   1,
-		"order_id": order.ID,
-	}).Error; err != nil {
+	// 2. 按期望价格生成订单
+	actor := statemachine.Actor{Id: companionId, Role: statemachine.RoleCompanion, ClientIP: clientIP}
+	order, err := o.createOrderForDemand(tx, &demand, companionId, demand.ExpectedPrice, nil, actor, "接受患者指定邀请")
+	if err != nil {
 		tx.Rollback()
-		return errors.New("更新需求状态失败")
+		return err
 	}
 
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		tx.Rollback()
-		return errors.New("接单事务提交失败")
+		return errors.New("接受邀请事务提交失败")
 	}
 
+	// 通知患者
+	notify(demand.PatientId, NoticeInviteAccepted, "指定陪诊师已接受邀请", "您指定的陪诊师已接受"+demand.Hospital+"陪诊邀请，订单"+order.OrderNo+"已生成，请于"+utils.FormatTime(*order.PayDeadline)+"前完成支付。", order.ID)
+
 	return nil
 }
 
@@ -134,70 +208,24 @@ func (o *OrderService) GetCompanionOrderList(companionId uint64, status int, pag
 	return orderList, total, nil
 }
 
-// CompanionConfirmOrderComplete 陪诊师确认服务完成（仅服务中状态可操作）
-func (o *OrderService) CompanionConfirmOrderComplete(orderId uint64, companionId uint64) error {
-	// 1. 查询订单：必须是当前陪诊师的订单，且状态为2-服务中
-	var order model.Order
-	if err := model.DB.Where("id = ? AND companion_id = ? AND status = ?", orderId, companionId, 2).First(&order).Error; err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			return errors.New("订单不存在或非服务中状态，无法确认完成")
-		}
-		return errors.New("查询订单失败")
-	}
-
-	// 2. 更新订单状态（2-服务中 → 3-待结算）
-	if err := model.DB.Model(&model.Order{}).Where("id = ?", orderId).Update("status", 3).Error; err != nil {
-		return errors.New("更新订单状态失败")
-	}
-
-	return nil
+// CompanionConfirmOrderComplete 陪诊师确认服务完成（服务中 → 待结算）
+func (o *OrderService) CompanionConfirmOrderComplete(orderId uint64, companionId uint64, clientIP string) error {
+	actor := statemachine.Actor{Id: companionId, Role: statemachine.RoleCompanion, ClientIP: clientIP}
+	return o.transitOrder(orderId, statemachine.EventCompanionConfirm, actor, "", func(tx *gorm.DB, order *model.Order) (map[string]interface{}, error) {
+		// 记录完成时间（自动结算以此计时）
+		return map[string]interface{}{"finish_at": time.Now()}, nil
+	})
 }
 
-// CompanionCancelOrder 陪诊师取消订单（仅待服务状态可操作）
-func (o *OrderService) CompanionCancelOrder(orderId uint64, companionId uint64, reason string) error {
-	// 开启事务
-	tx := model.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	if err := tx.Error; err != nil {
+// CompanionCancelOrder 陪诊师取消订单（待支付/待服务 → 已取消，需求退回订单大厅，已预付的款项全额退回患者）
+func (o *OrderService) CompanionCancelOrder(orderId uint64, companionId uint64, reason string, clientIP string) error {
+	actor := statemachine.Actor{Id: companionId, Role: statemachine.RoleCompanion, ClientIP: clientIP}
+	if err := o.transitOrder(orderId, statemachine.EventCompanionCancel, actor, reason, nil); err != nil {
 		return err
 	}
 
-	// 1. 查询订单：当前陪诊师的订单，状态为1-待服务
-	var order model.Order
-	if err := tx.Where("id = ? AND companion_id = ? AND status = ?", orderId, companionId, 1).First(&order).Error; err != nil {
-		tx.Rollback()
-		if gorm.IsRecordNotFoundError(err) {
-			return errors.New("订单不存在或非待服务状态，无法取消")
-		}
-		return errors.New("查询订单失败")
-	}
-
-	// 2. 更新订单状态（1-待服务 → 5-已取消）
-	if err := tx.Model(&model.Order{}).Where("id = ?", orderId).Update("status", 5).Error; err != nil {
-		tx.Rollback()
-		return errors.New("更新订单状态失败")
-	}
-
-	// 3. 更新需求状态（1-已接单 → 0-待接单），清空订单ID
-	if err := tx.Model(&model.Demand{}).Where("id = ?", order.DemandId).Updates(map[string]interface{}{
-		"status":   0,
-		"order_id": 0,
-	}).Error; err != nil {
-		tx.Rollback()
-		return errors.New("更新需求状态失败")
-	}
-
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		return errors.New("取消订单事务提交失败")
-	}
-
+	// 发起预付款退款（失败由定时任务重试）
+	(&RefundService{}).ProcessOrderRefunds(orderId)
 	return nil
 }
 
@@ -230,78 +258,375 @@ func (o *OrderService) GetPatientOrderList(patientId uint64, status int, page in
 	return orderList, total, nil
 }
 
-// PatientConfirmOrderComplete 患者确认服务完成（触发订单结算，陪诊师收款）
-func (o *OrderService) PatientConfirmOrderComplete(orderId uint64, patientId uint64) error {
-	// 开启事务（更新订单、更新陪诊师余额、生成收入明细）
+// PatientConfirmOrderComplete 患者确认服务完成（待结算 → 已完成，触发陪诊师入账）
+func (o *OrderService) PatientConfirmOrderComplete(orderId uint64, patientId uint64, clientIP string) error {
+	actor := statemachine.Actor{Id: patientId, Role: statemachine.RolePatient, ClientIP: clientIP}
+	return o.transitOrder(orderId, statemachine.EventPatientConfirm, actor, "", nil)
+}
+
+// PatientCancelOrder 患者取消订单（待支付/待服务 → 已取消，需求退回订单大厅，已预付的款项扣除违约金后退回）
+func (o *OrderService) PatientCancelOrder(orderId uint64, patientId uint64, reason string, clientIP string) error {
+	actor := statemachine.Actor{Id: patientId, Role: statemachine.RolePatient, ClientIP: clientIP}
+	if err := o.transitOrder(orderId, statemachine.EventPatientCancel, actor, reason, nil); err != nil {
+		return err
+	}
+
+	// 发起预付款退款（失败由定时任务重试）
+	(&RefundService{}).ProcessOrderRefunds(orderId)
+	return nil
+}
+
+// PatientApproveOrder 患者确认陪诊师（待确认 → 待支付）
+func (o *OrderService) PatientApproveOrder(orderId uint64, patientId uint64, clientIP string) error {
+	actor := statemachine.Actor{Id: patientId, Role: statemachine.RolePatient, ClientIP: clientIP}
+	order, err := o.transitOrderAndGet(orderId, statemachine.EventPatientApprove, actor, "", nil)
+	if err != nil {
+		return err
+	}
+
+	notify(order.CompanionId, NoticeOrderApproved, "患者已确认您的接单", "订单"+order.OrderNo+"已由患者确认，待患者支付后请按时到场服务。", order.ID)
+	return nil
+}
+
+// PatientRejectOrder 患者拒绝陪诊师（待确认 → 已取消，需求退回订单大厅）
+func (o *OrderService) PatientRejectOrder(orderId uint64, patientId uint64, reason string, clientIP string) error {
+	actor := statemachine.Actor{Id: patientId, Role: statemachine.RolePatient, ClientIP: clientIP}
+	order, err := o.transitOrderAndGet(orderId, statemachine.EventPatientReject, actor, reason, nil)
+	if err != nil {
+		return err
+	}
+
+	notify(order.CompanionId, NoticeOrderRejected, "患者未选择您的接单", "订单"+order.OrderNo+"已被患者拒绝，原因："+reason, order.ID)
+	return nil
+}
+
+// -------------------------- 系统自动处理 --------------------------
+
+// AutoSettleOrders 待结算订单超时自动确认完成（与患者确认走同一结算逻辑，返回本次确认的订单数）
+func (o *OrderService) AutoSettleOrders(timeout time.Duration) (int, error) {
+	// 1. 查询超时未确认的待结算订单（历史订单无完成时间时按更新时间计算）
+	deadline := time.Now().Add(-timeout)
+	var orderIds []uint64
+	if err := model.DB.Model(&model.Order{}).
+		Where("status = ?", statemachine.OrderPendingSettle).
+		Where("(finish_at IS NOT NULL AND finish_at < ?) OR (finish_at IS NULL AND updated_at < ?)", deadline, deadline).
+		Order("id ASC").Limit(100).Pluck("id", &orderIds).Error; err != nil {
+		return 0, errors.New("查询待自动结算订单失败")
+	}
+
+	// 2. 逐笔确认（状态机以当前状态为条件更新，多实例并发执行时同一订单只会结算一次）
+	reason := "患者超过" + strconv.Itoa(int(timeout.Hours())) + "小时未确认，系统自动确认完成"
+	actor := statemachine.Actor{Role: statemachine.RoleSystem}
+	settled := 0
+	for _, orderId := range orderIds {
+		if err := o.transitOrder(orderId, statemachine.EventAutoConfirm, actor, reason, nil); err != nil {
+			if !statemachine.IsTransitionError(err) {
+				log.Printf("订单%d自动结算失败：%s", orderId, err)
+			}
+			continue
+		}
+		settled++
+	}
+
+	return settled, nil
+}
+
+// ResolveApproveTimeouts 处理患者超时未确认的订单（按配置自动确认或退回订单大厅，返回本次处理数量）
+func (o *OrderService) ResolveApproveTimeouts() (int, error) {
+	// 1. 查询确认截止时间已过的待确认订单
+	var orderIds []uint64
+	if err := model.DB.Model(&model.Order{}).
+		Where("status = ? AND approve_deadline < ?", statemachine.OrderPendingApprove, time.Now()).
+		Order("id ASC").Limit(100).Pluck("id", &orderIds).Error; err != nil {
+		return 0, errors.New("查询待确认超时订单失败")
+	}
+
+	// 2. 按配置选择处理方式
+	event := statemachine.EventReleaseTimeout
+	reason := "患者超时未确认，需求已退回订单大厅"
+	if conf.AppConfig.Order.ApproveTimeoutAction == "approve" {
+		event = statemachine.EventApproveTimeout
+		reason = "患者超时未确认，系统自动确认"
+	}
+
+	// 3. 逐笔处理（状态机以当前状态为条件更新，多实例并发执行时同一订单只会处理一次）
+	actor := statemachine.Actor{Role: statemachine.RoleSystem}
+	resolved := 0
+	for _, orderId := range orderIds {
+		order, err := o.transitOrderAndGet(orderId, event, actor, reason, nil)
+		if err != nil {
+			if !statemachine.IsTransitionError(err) {
+				log.Printf("订单%d确认超时处理失败：%s", orderId, err)
+			}
+			continue
+		}
+		resolved++
+
+		if event == statemachine.EventApproveTimeout {
+			notify(order.CompanionId, NoticeOrderApproved, "接单已自动确认", "订单"+order.OrderNo+"患者未在时限内处理，系统已自动确认，待患者支付后请按时到场服务。", order.ID)
+			notify(order.PatientId, NoticeOrderApproved, "接单已自动确认，请完成支付", "订单"+order.OrderNo+"已由系统自动确认陪诊师，请于"+utils.FormatTime(*order.PayDeadline)+"前完成支付，逾期订单将自动取消。", order.ID)
+		} else {
+			notify(order.CompanionId, NoticeOrderRejected, "接单已失效", "订单"+order.OrderNo+"患者未在时限内确认，订单已取消。", order.ID)
+		}
+	}
+
+	return resolved, nil
+}
+
+// -------------------------- 流转记录查询 --------------------------
+
+// GetOrderEventList 查询订单流转时间线（仅订单参与方可查看，按发生时间正序）
+func (o *OrderService) GetOrderEventList(orderId uint64, userId uint64) ([]model.OrderEvent, error) {
+	// 1. 校验订单归属
+	var order model.Order
+	if err := model.DB.Where("id = ? AND (patient_id = ? OR companion_id = ?)", orderId, userId, userId).First(&order).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, statemachine.ErrNotParticipant
+		}
+		return nil, errors.New("查询订单失败")
+	}
+
+	// 2. 查询流转事件
+	var eventList []model.OrderEvent
+	if err := model.DB.Where("order_id = ?", orderId).Order("created_at ASC, id ASC").Find(&eventList).Error; err != nil {
+		return nil, errors.New("查询订单流转记录失败")
+	}
+
+	return eventList, nil
+}
+
+// -------------------------- 签到相关业务 --------------------------
+
+// GetOrderCheckinCode 患者获取订单签到码（仅待服务状态，陪诊师到场后向其出示）
+func (o *OrderService) GetOrderCheckinCode(orderId uint64, patientId uint64) (string, error) {
+	// 1. 查询订单：当前患者的订单，状态为1-待服务
+	var order model.Order
+	if err := model.DB.Where("id = ? AND patient_id = ? AND status = ?", orderId, patientId, statemachine.OrderPendingService).First(&order).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return "", errors.New("订单不存在或非待服务状态，无法获取签到码")
+		}
+		return "", errors.New("查询订单失败")
+	}
+
+	// 2. 历史订单无签到码时补发
+	if order.CheckinCode == "" {
+		code := utils.GenerateCheckinCode()
+		if err := model.DB.Model(&model.Order{}).Where("id = ? AND checkin_code = ''", orderId).Update("checkin_code", code).Error; err != nil {
+			return "", errors.New("生成签到码失败")
+		}
+		order.CheckinCode = code
+	}
+
+	return order.CheckinCode, nil
+}
+
+// CompanionCheckIn 陪诊师到场签到（核验签到码与服务时间窗口，待服务 → 服务中）
+func (o *OrderService) CompanionCheckIn(orderId uint64, companionId uint64, checkinCode string, lat float64, lng float64, checkinTime time.Time, clientIP string) error {
+	now := time.Now()
+
+	// 1. 校验客户端时间戳（与服务器时间偏差过大视为无效）
+	maxSkew := time.Duration(conf.AppConfig.Order.CheckinMaxSkewSeconds) * time.Second
+	if skew := now.Sub(checkinTime); skew > maxSkew || skew < -maxSkew {
+		return errors.New("签到时间与服务器时间偏差过大，请校准设备时间后重试")
+	}
+
+	actor := statemachine.Actor{Id: companionId, Role: statemachine.RoleCompanion, ClientIP: clientIP}
+	err := o.transitOrder(orderId, statemachine.EventCheckIn, actor, "", func(tx *gorm.DB, order *model.Order) (map[string]interface{}, error) {
+		// 2. 核验签到码（一次性，签到成功后作废；连续错误次数过多时锁定，防止穷举）
+		if order.CheckinLockedUntil != nil && order.CheckinLockedUntil.After(now) {
+			return nil, errors.New("签到码错误次数过多，请于" + utils.FormatTime(*order.CheckinLockedUntil) + "后重试")
+		}
+		if order.CheckinCode == "" || subtle.ConstantTimeCompare([]byte(order.CheckinCode), []byte(checkinCode)) != 1 {
+			return nil, errCheckinCodeMismatch
+		}
+
+		// 3. 校验签到时间是否在服务时间窗口内
+		var demand model.Demand
+		if err := tx.Where("id = ?", order.DemandId).First(&demand).Error; err != nil {
+			return nil, errors.New("查询需求失败")
+		}
+		windowStart := demand.ServiceTime.Add(-time.Duration(conf.AppConfig.Order.CheckinEarlyMinutes) * time.Minute)
+		windowEnd := demand.ServiceTime.Add(time.Duration(conf.AppConfig.Order.CheckinLateMinutes) * time.Minute)
+		if now.Before(windowStart) {
+			return nil, errors.New("未到签到时间，最早可于" + utils.FormatTime(windowStart) + "签到")
+		}
+		if now.After(windowEnd) {
+			return nil, errors.New("已超过签到时间窗口，无法签到")
+		}
+
+		// 4. 记录签到信息并作废签到码
+		return map[string]interface{}{
+			"checkin_code":         "",
+			"checkin_fail_count":   0,
+			"checkin_locked_until": nil,
+			"checkin_at":           now,
+			"checkin_lat":          lat,
+			"checkin_lng":          lng,
+		}, nil
+	})
+	if err == errCheckinCodeMismatch {
+		// 签到事务已回滚，错误次数单独记录
+		return recordCheckinFailure(orderId, now)
+	}
+	return err
+}
+
+// errCheckinCodeMismatch 签到码错误（调用方需累计错误次数）
+var errCheckinCodeMismatch = errors.New("签到码错误")
+
+// recordCheckinFailure 累计签到码错误次数，达到上限时锁定签到并清零计数，返回提示错误
+func recordCheckinFailure(orderId uint64, now time.Time) error {
+	maxAttempts := conf.AppConfig.Order.CheckinMaxAttempts
+	if maxAttempts <= 0 {
+		return errCheckinCodeMismatch
+	}
+
 	tx := model.DB.Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
-
 	if err := tx.Error; err != nil {
-		return err
+		return errCheckinCodeMismatch
 	}
 
-	// 1. 查询订单：当前患者的订单，状态为3-待结算
+	// 1. 锁定订单并累加错误次数（FOR UPDATE 保证并发错误请求逐一计数）
 	var order model.Order
-	if err := tx.Where("id = ? AND patient_id = ? AND status = ?", orderId, patientId, 3).First(&order).Error; err != nil {
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", orderId).First(&order).Error; err != nil {
 		tx.Rollback()
-		if gorm.IsRecordNotFoundError(err) {
-			return errors.New("订单不存在或非待结算状态，无法确认完成")
-		}
-		return errors.New("查询订单失败")
+		return errCheckinCodeMismatch
+	}
+	failCount := order.CheckinFailCount + 1
+	updates := map[string]interface{}{"checkin_fail_count": failCount}
+	var lockedAt time.Time
+	if failCount >= maxAttempts {
+		lockedAt = now.Add(time.Duration(conf.AppConfig.Order.CheckinLockMinutes) * time.Minute)
+		updates = map[string]interface{}{"checkin_fail_count": 0, "checkin_locked_until": lockedAt}
 	}
 
-	// 2. 查询陪诊师信息
-	var companion model.User
-	if err := tx.Where("id = ?", order.CompanionId).First(&companion).Error; err != nil {
+	// 2. 保存并提示剩余次数
+	if err := tx.Model(&model.Order{}).Where("id = ?", orderId).Updates(updates).Error; err != nil {
 		tx.Rollback()
-		if gorm.IsRecordNotFoundError(err) {
-			return errors.New("陪诊师不存在")
-		}
-		return errors.New("查询陪诊师失败")
+		return errCheckinCodeMismatch
+	}
+	if err := tx.Commit().Error; err != nil {
+		return errCheckinCodeMismatch
 	}
+	if failCount >= maxAttempts {
+		return errors.New("签到码错误次数过多，签到已锁定至" + utils.FormatTime(lockedAt))
+	}
+	return errors.New("签到码错误，还可尝试" + strconv.Itoa(maxAttempts-failCount) + "次")
+}
 
-	// 3. 更新订单状态（3-待结算 → 4-已完成）
-	if err := tx.Model(&model.Order{}).Where("id = ?", orderId).Update("status", 4).Error; err != nil {
-		tx.Rollback()
-		return errors.New("更新订单状态失败")
+// -------------------------- 订单生成 --------------------------
+
+// createOrderForDemand 为待接单需求生成订单（在调用方事务内执行）
+// approveDeadline 不为空时订单进入待确认状态，等待患者确认；为空表示患者已选定陪诊师，订单直接进入待支付
+func (o *OrderService) createOrderForDemand(
+	tx *gorm.DB,
+	demand *model.Demand,
+	companionId uint64,
+	price utils.Money,
+	approveDeadline *time.Time,
+	actor statemachine.Actor,
+	reason string,
+) (*model.Order, error) {
+	// 1. 校验服务时间（已过期的需求不可接单）
+	if !demand.ServiceTime.After(time.Now()) {
+		return nil, errors.New("需求服务时间已过，无法接单")
 	}
 
-	// 4. 更新陪诊师余额（累加收入）
-	newBalance := utils.KeepTwoDecimal(companion.Balance + order.CompanionIncome)
-	if err := tx.Model(&model.User{}).Where("id = ?", order.CompanionId).Update("balance", newBalance).Error; err != nil {
-		tx.Rollback()
-		return errors.New("更新陪诊师余额失败")
+	// 2. 校验陪诊师身份（避免自己接自己的需求，若需求发布者是陪诊师）
+	if demand.PatientId == companionId {
+		return nil, errors.New("不能接自己发布的需求")
 	}
 
-	// 5. 生成余额收入明细
-	serialNo := utils.GenerateSerialNo("INC") // INC-收入前缀
-	balanceRecord := model.BalanceRecord{
-		SerialNo:    serialNo,
-		CompanionId: order.CompanionId,
-		Type:        1, // 1-服务收入
-		Amount:      order.CompanionIncome,
-		Remark:      "订单" + order.OrderNo + "服务收入",
+	// 3. 计算订单金额与陪诊师收入（按佣金规则扣除平台佣金，未命中规则时默认10%）
+	// 佣金按比例四舍五入到分，陪诊师收入取差额，保证「收入 + 佣金 = 订单金额」
+	orderAmount := price
+	commissionRuleId, commissionRate, err := resolveCommission(tx, demand, companionId, orderAmount, time.Now())
+	if err != nil {
+		return nil, err
 	}
-	if err := tx.Create(&balanceRecord).Error; err != nil {
-		tx.Rollback()
-		return errors.New("生成收入明细失败")
+	companionIncome := orderAmount - orderAmount.MulRate(commissionRate)
+
+	// 4. 确定订单与需求的初始状态
+	orderStatus, demandStatus := statemachine.OrderPendingPayment, statemachine.DemandTaken
+	var orderPayDeadline *time.Time
+	if approveDeadline != nil {
+		orderStatus, demandStatus = statemachine.OrderPendingApprove, statemachine.DemandApproving
+	} else {
+		deadline := payDeadline(time.Now())
+		orderPayDeadline = &deadline
 	}
 
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		return errors.New("确认完成事务提交失败")
+	// 5. 抢占需求：以「待接单、未关联订单、指定对象未变」为条件更新，并发接单时仅一个事务能更新成功
+	result := tx.Model(&model.Demand{}).
+		Where("id = ? AND status = ? AND order_id = 0 AND designated_id = ?", demand.ID, statemachine.DemandPending, demand.DesignatedId).
+		Update("status", demandStatus)
+	if result.Error != nil {
+		return nil, errors.New("更新需求状态失败")
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrDemandTaken
 	}
 
-	return nil
+	// 6. 创建订单
+	order := model.Order{
+		OrderNo:          utils.GenerateOrderNo(),
+		DemandId:         demand.ID,
+		PatientId:        demand.PatientId,
+		CompanionId:      companionId,
+		OrderAmount:      orderAmount,
+		CompanionIncome:  companionIncome,
+		CommissionRuleId: commissionRuleId,
+		CommissionRate:   commissionRate,
+		Status:           int(orderStatus),
+		ApproveDeadline:  approveDeadline,
+		PayDeadline:      orderPayDeadline,
+		CheckinCode:      utils.GenerateCheckinCode(), // 签到码（患者出示，陪诊师签到时核验）
+	}
+	if err := tx.Create(&order).Error; err != nil {
+		return nil, errors.New("生成订单失败")
+	}
+
+	// 7. 需求关联订单ID
+	if err := tx.Model(&model.Demand{}).Where("id = ?", demand.ID).Update("order_id", order.ID).Error; err != nil {
+		return nil, errors.New("更新需求状态失败")
+	}
+
+	// 8. 记录接单事件
+	if err := statemachine.RecordEvent(tx, order.ID, statemachine.EventTake, statemachine.OrderNone, orderStatus, actor, reason); err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// -------------------------- 状态流转通用流程 --------------------------
+
+// transitOrder 订单状态流转通用流程（见 transitOrderAndGet）
+func (o *OrderService) transitOrder(
+	orderId uint64,
+	event statemachine.Event,
+	actor statemachine.Actor,
+	reason string,
+	check func(tx *gorm.DB, order *model.Order) (map[string]interface{}, error),
+) error {
+	_, err := o.transitOrderAndGet(orderId, event, actor, reason, check)
+	return err
 }
 
-// PatientCancelOrder 患者取消订单（仅待服务状态可操作）
-func (o *OrderService) PatientCancelOrder(orderId uint64, patientId uint64, reason string) error {
+// transitOrderAndGet 订单状态流转通用流程（开启事务 → 加载订单 → 状态机校验 → 业务校验 → 流转并执行副作用 → 提交），返回流转后的订单
+// reason：操作原因（记入流转事件）；check：可选的业务校验，返回需随状态一并更新的字段
+func (o *OrderService) transitOrderAndGet(
+	orderId uint64,
+	event statemachine.Event,
+	actor statemachine.Actor,
+	reason string,
+	check func(tx *gorm.DB, order *model.Order) (map[string]interface{}, error),
+) (*model.Order, error) {
 	// 开启事务
 	tx := model.DB.Begin()
 	defer func() {
@@ -311,60 +636,94 @@ func (o *OrderService) PatientCancelOrder(orderId uint64, patientId uint64, reas
 	}()
 
 	if err := tx.Error; err != nil {
-		return err
+		return nil, err
 	}
 
-	// 1. 查询订单：当前患者的订单，状态为1-待服务
+	// 1. 查询订单
 	var order model.Order
-	if err := tx.Where("id = ? AND patient_id = ? AND status = ?", orderId, patientId, 1).First(&order).Error; err != nil {
+	if err := tx.Where("id = ?", orderId).First(&order).Error; err != nil {
 		tx.Rollback()
 		if gorm.IsRecordNotFoundError(err) {
-			return errors.New("订单不存在或非待服务状态，无法取消")
+			return nil, statemachine.ErrNotParticipant
 		}
-		return errors.New("查询订单失败")
+		return nil, errors.New("查询订单失败")
 	}
 
-	// 2. 更新订单状态（1-待服务 → 5-已取消）
-	if err := tx.Model(&model.Order{}).Where("id = ?", orderId).Update("status", 5).Error; err != nil {
+	// 2. 状态机校验（参与方 + 流转合法性），先于业务校验执行，避免向非参与方暴露业务信息
+	if _, err := orderMachine.Check(&order, event, actor); err != nil {
 		tx.Rollback()
-		return errors.New("更新订单状态失败")
+		return nil, err
 	}
 
-	// 3. 更新需求状态（1-已接单 → 0-待接单），清空订单ID
-	if err := tx.Model(&model.Demand{}).Where("id = ?", order.DemandId).Updates(map[string]interface{}{
-		"status":   0,
-		"order_id": 0,
-	}).Error; err != nil {
+	// 3. 业务校验
+	var fields map[string]interface{}
+	if check != nil {
+		var err error
+		if fields, err = check(tx, &order); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	// 4. 执行流转与副作用
+	if _, err := orderMachine.Fire(tx, &order, event, actor, reason, fields); err != nil {
 		tx.Rollback()
-		return errors.New("更新需求状态失败")
+		return nil, err
 	}
 
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		tx.Rollback()
-		return errors.New("取消订单事务提交失败")
+		return nil, errors.New(event.String() + "事务提交失败")
 	}
 
-	return nil
+	return &order, nil
 }
 
-// -------------------------- 通用辅助方法 --------------------------
-
-// UpdateOrderStatusToServing 订单状态更新为服务中（可在服务开始时调用）
-func (o *OrderService) UpdateOrderStatusToServing(orderId uint64) error {
-	// 校验订单状态（仅待服务状态可更新为服务中）
-	var order model.Order
-	if err := model.DB.Where("id = ? AND status = ?", orderId, 1).First(&order).Error; err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			return errors.New("订单不存在或非待服务状态，无法开始服务")
-		}
-		return errors.New("查询订单失败")
+// -------------------------- 状态流转副作用 --------------------------
+
+// resetDemandEffect 需求退回订单大厅（已接单 → 待接单），清空订单ID与指定陪诊师，并使竞价需求此前的报价失效
+// 以需求仍关联本订单为条件，避免覆盖需求后续的状态
+func resetDemandEffect(tx *gorm.DB, order *model.Order, actor statemachine.Actor) error {
+	result := tx.Model(&model.Demand{}).Where("id = ? AND order_id = ?", order.DemandId, order.ID).Updates(map[string]interface{}{
+		"status":        statemachine.DemandPending,
+		"order_id":      0,
+		"designated_id": 0,
+	})
+	if result.Error != nil {
+		return errors.New("更新需求状态失败")
 	}
-
-	// 更新状态
-	if err := model.DB.Model(&model.Order{}).Where("id = ?", orderId).Update("status", 2).Error; err != nil {
-		return errors.New("更新订单状态为服务中失败")
+	if result.RowsAffected == 0 {
+		return nil
 	}
+	// 竞价需求此前的报价随之失效，陪诊师需重新报价
+	return expireDemandBids(tx, order.DemandId)
+}
 
+// confirmDemandEffect 需求确认接单（待确认 → 已接单）
+func confirmDemandEffect(tx *gorm.DB, order *model.Order, actor statemachine.Actor) error {
+	result := tx.Model(&model.Demand{}).
+		Where("id = ? AND order_id = ? AND status = ?", order.DemandId, order.ID, statemachine.DemandApproving).
+		Update("status", statemachine.DemandTaken)
+	if result.Error != nil {
+		return errors.New("更新需求状态失败")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("需求状态已变更，请刷新后重试")
+	}
 	return nil
 }
+
+// creditCompanionEffect 订单结算记账：订单金额由托管账户转出，陪诊师收入计入冻结余额（到期解冻）或可用余额，佣金计入平台收入
+func creditCompanionEffect(tx *gorm.DB, order *model.Order, actor statemachine.Actor) error {
+	income, err := incomePosting(tx, order.CompanionId, order.OrderNo, order.CompanionIncome)
+	if err != nil {
+		return err
+	}
+	// INC-收入前缀
+	return postEntry(tx, utils.GenerateSerialNo("INC"), BizOrderSettle, order.OrderNo, "订单"+order.OrderNo+"服务收入",
+		Posting{AccountType: escrowSource(order), Amount: -order.OrderAmount},
+		income,
+		Posting{AccountType: AccountPlatformRevenue, Amount: order.OrderAmount - order.CompanionIncome},
+	)
+}
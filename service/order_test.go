@@ -0,0 +1,111 @@
+package service
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/statemachine"
+	"github.com/X-Colder/companion-backend/utils"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+)
+
+// testMysqlDsnEnv 测试数据库连接串的环境变量（未设置时跳过依赖数据库的测试）
+// 应指向专用的测试库，格式同 conf/app.yaml 的 mysql.dsn；模型的 DeletedAt 为非指针 time.Time，sql_mode 需允许零值日期
+const testMysqlDsnEnv = "COMPANION_TEST_MYSQL_DSN"
+
+// openTestDB 连接测试数据库并替换全局 model.DB，测试结束后恢复
+func openTestDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv(testMysqlDsnEnv)
+	if dsn == "" {
+		t.Skip("未设置 " + testMysqlDsnEnv + "，跳过数据库测试")
+	}
+	db, err := gorm.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("连接测试数据库失败：%s", err)
+	}
+	db.AutoMigrate(
+		&model.Demand{},
+		&model.Order{},
+		&model.OrderEvent{},
+		&model.Notification{},
+		&model.CommissionRule{},
+		&model.Evaluation{},
+	)
+
+	origin := model.DB
+	model.DB = db
+	t.Cleanup(func() {
+		model.DB = origin
+		db.Close()
+	})
+	return db
+}
+
+// TestTakeOrderConcurrent 多名陪诊师同时抢同一需求：仅一人成功，其余均收到 ErrDemandTaken，且只生成一个订单
+func TestTakeOrderConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	conf.AppConfig.Order.ApproveTimeoutMinutes = 30
+
+	// 1. 发布一条公开的抢单需求（DeletedAt 不写入，保持 NULL 以便软删除条件能查到）
+	base := uint64(time.Now().UnixNano() % 1e9 * 100)
+	demand := model.Demand{
+		PatientId:      base,
+		Hospital:       "并发接单测试医院",
+		HospitalAddr:   "测试地址",
+		ServiceTime:    time.Now().Add(24 * time.Hour),
+		ExpectedPrice:  utils.MoneyFromYuan(200),
+		ServiceContent: "并发接单测试",
+		ContactName:    "测试",
+		ContactPhone:   "13800000000",
+		Status:         int(statemachine.DemandPending),
+	}
+	if err := db.Omit("deleted_at").Create(&demand).Error; err != nil {
+		t.Fatalf("创建需求失败：%s", err)
+	}
+
+	// 2. N 名陪诊师同时接单
+	const n = 20
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = (&OrderService{}).TakeOrder(demand.ID, base+uint64(i)+1, "127.0.0.1")
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	// 3. 校验结果
+	successCount, takenCount := 0, 0
+	for i, err := range errs {
+		switch err {
+		case nil:
+			successCount++
+		case ErrDemandTaken:
+			takenCount++
+		default:
+			t.Errorf("陪诊师%d接单返回非预期错误：%s", i+1, err)
+		}
+	}
+	if successCount != 1 || takenCount != n-1 {
+		t.Errorf("接单成功%d人、抢占失败%d人，期望成功1人、失败%d人", successCount, takenCount, n-1)
+	}
+
+	var orderCount int
+	if err := db.Unscoped().Model(&model.Order{}).Where("demand_id = ?", demand.ID).Count(&orderCount).Error; err != nil {
+		t.Fatalf("查询订单失败：%s", err)
+	}
+	if orderCount != 1 {
+		t.Errorf("需求生成了%d个订单，期望1个", orderCount)
+	}
+}
@@ -0,0 +1,317 @@
+// service/payment.go
+package service
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/payment"
+	"github.com/X-Colder/companion-backend/statemachine"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 支付单状态
+const (
+	PaymentPending       = 0 // 待支付
+	PaymentPaid          = 1 // 支付成功
+	PaymentClosed        = 2 // 已关闭（渠道下单失败等）
+	PaymentPaidButClosed = 3 // 支付成功但订单已关闭（已取消或已由其他支付单完成支付），款项全额退回
+)
+
+// errPaymentHandled 支付单已被处理（重复回调）
+var errPaymentHandled = errors.New("支付单已处理")
+
+// errPaymentAmountChanged 支付单金额与订单当前应付金额不一致（发起支付与更换优惠券并发时可能出现）
+var errPaymentAmountChanged = errors.New("支付金额与订单应付金额不一致")
+
+// PayParams 发起支付结果（客户端据此拉起支付）
+type PayParams struct {
+	PaymentNo   string            `json:"payment_no"`   // 平台支付单号
+	Amount      utils.Money       `json:"amount"`       // 支付金额
+	Provider    string            `json:"provider"`     // 支付渠道
+	PayParams   map[string]string `json:"pay_params"`   // 渠道支付参数
+	PayDeadline *time.Time        `json:"pay_deadline"` // 支付截止时间
+}
+
+// PaymentService 支付服务（患者预付订单金额，由平台托管至服务结算）
+type PaymentService struct{}
+
+// CreateOrderPayment 患者为待支付订单发起支付（每次调用生成新的支付单，先支付成功的一张生效）
+// userCouponId 不为0时先为订单选用该优惠券（订单首次发起支付前可更换），支付金额为扣除优惠后的实付金额
+func (p *PaymentService) CreateOrderPayment(orderId uint64, patientId uint64, userCouponId uint64) (*PayParams, error) {
+	// 1. 查询订单（仅本人的待支付订单）
+	var order model.Order
+	if err := model.DB.Where("id = ? AND patient_id = ?", orderId, patientId).First(&order).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, statemachine.ErrNotParticipant
+		}
+		return nil, errors.New("查询订单失败")
+	}
+	if statemachine.OrderStatus(order.Status) != statemachine.OrderPendingPayment {
+		return nil, errors.New("订单当前为「" + statemachine.OrderStatus(order.Status).String() + "」状态，无需支付")
+	}
+	if order.PayDeadline != nil && order.PayDeadline.Before(time.Now()) {
+		return nil, errors.New("订单已超过支付时限")
+	}
+	if userCouponId > 0 && userCouponId != order.CouponId {
+		updated, err := (&CouponService{}).ApplyOrderCoupon(order.ID, patientId, userCouponId)
+		if err != nil {
+			return nil, err
+		}
+		order = *updated
+	}
+
+	// 2. 获取当前支付渠道
+	provider, err := payment.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 生成支付单
+	record := model.Payment{
+		PaymentNo: utils.GenerateSerialNo("PAY"), // PAY-支付前缀
+		OrderId:   order.ID,
+		PatientId: patientId,
+		Amount:    order.PayAmount(),
+		Provider:  provider.Name(),
+		Status:    PaymentPending,
+	}
+	if err := model.DB.Create(&record).Error; err != nil {
+		return nil, errors.New("生成支付单失败")
+	}
+
+	// 4. 渠道下单（失败时关闭支付单）
+	req := payment.CreateRequest{
+		PaymentNo: record.PaymentNo,
+		Amount:    record.Amount,
+		Subject:   "陪诊服务订单" + order.OrderNo,
+		NotifyUrl: conf.AppConfig.Payment.NotifyUrl + provider.Name(),
+	}
+	if order.PayDeadline != nil {
+		req.ExpireAt = *order.PayDeadline
+	}
+	result, err := provider.CreatePayment(req)
+	if err != nil {
+		model.DB.Model(&model.Payment{}).Where("id = ?", record.ID).Update("status", PaymentClosed)
+		return nil, errors.New("发起支付失败：" + err.Error())
+	}
+	if result.TradeNo != "" {
+		if err := model.DB.Model(&model.Payment{}).Where("id = ?", record.ID).Update("trade_no", result.TradeNo).Error; err != nil {
+			return nil, errors.New("更新支付单失败")
+		}
+	}
+
+	return &PayParams{
+		PaymentNo:   record.PaymentNo,
+		Amount:      record.Amount,
+		Provider:    record.Provider,
+		PayParams:   result.PayParams,
+		PayDeadline: order.PayDeadline,
+	}, nil
+}
+
+// SyncOrderPayment 患者主动查询订单支付结果（回调未到达时向渠道查询待支付的支付单），返回订单最新状态
+func (p *PaymentService) SyncOrderPayment(orderId uint64, patientId uint64) (*model.Order, error) {
+	// 1. 查询订单
+	var order model.Order
+	if err := model.DB.Where("id = ? AND patient_id = ?", orderId, patientId).First(&order).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, statemachine.ErrNotParticipant
+		}
+		return nil, errors.New("查询订单失败")
+	}
+	if statemachine.OrderStatus(order.Status) != statemachine.OrderPendingPayment {
+		return &order, nil
+	}
+
+	// 2. 逐张查询待支付的支付单
+	var paymentList []model.Payment
+	if err := model.DB.Where("order_id = ? AND status = ?", orderId, PaymentPending).Order("id DESC").Find(&paymentList).Error; err != nil {
+		return nil, errors.New("查询支付单失败")
+	}
+	for _, record := range paymentList {
+		provider, err := payment.Get(record.Provider)
+		if err != nil {
+			continue
+		}
+		result, err := provider.QueryPayment(record.PaymentNo)
+		if err != nil {
+			log.Printf("查询支付单%s失败：%s", record.PaymentNo, err)
+			continue
+		}
+		if result.Status == payment.StatusPaid {
+			if err := p.confirmPaid(record.PaymentNo, result.TradeNo, result.Amount); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	// 3. 返回最新订单
+	if err := model.DB.Where("id = ?", orderId).First(&order).Error; err != nil {
+		return nil, errors.New("查询订单失败")
+	}
+	return &order, nil
+}
+
+// HandleCallback 处理渠道支付结果回调（验签 → 确认支付），重复回调返回成功
+func (p *PaymentService) HandleCallback(providerName string, body []byte, header http.Header) error {
+	provider, err := payment.Get(providerName)
+	if err != nil {
+		return err
+	}
+	notification, err := provider.VerifyCallback(body, header)
+	if err != nil {
+		return err
+	}
+	if notification.Status != payment.StatusPaid {
+		return nil
+	}
+	return p.confirmPaid(notification.PaymentNo, notification.TradeNo, notification.Amount)
+}
+
+// confirmPaid 确认支付成功：支付单置为成功，订单待支付 → 待服务并将款项转入托管（同一事务）；
+// 订单已不在待支付状态时，支付单标记为「支付成功但订单已关闭」并全额退款；打赏支付单转由打赏服务入账
+func (p *PaymentService) confirmPaid(paymentNo string, tradeNo string, amount utils.Money) error {
+	// 1. 查询支付单
+	var record model.Payment
+	if err := model.DB.Where("payment_no = ?", paymentNo).First(&record).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("支付单不存在")
+		}
+		return errors.New("查询支付单失败")
+	}
+	if record.Status != PaymentPending {
+		return nil
+	}
+	if amount != record.Amount {
+		log.Printf("支付单%s实付金额%s与应付金额%s不一致", paymentNo, amount.String(), record.Amount.String())
+		return errors.New("支付金额不一致")
+	}
+	now := time.Now()
+	if record.TipId != 0 {
+		return (&TipService{}).confirmTipPaid(&record, tradeNo, now)
+	}
+
+	// 2. 流转订单（支付单以待支付为条件更新，重复回调只会处理一次）
+	actor := statemachine.Actor{Role: statemachine.RoleSystem}
+	order, err := (&OrderService{}).transitOrderAndGet(record.OrderId, statemachine.EventPaid, actor, "支付单"+paymentNo+"支付成功", func(tx *gorm.DB, order *model.Order) (map[string]interface{}, error) {
+		if record.Amount != order.PayAmount() {
+			return nil, errPaymentAmountChanged
+		}
+		if err := markPayment(tx, record.ID, PaymentPaid, tradeNo, now); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"paid_at": now}, nil
+	})
+	if err == errPaymentHandled {
+		return nil
+	}
+	if statemachine.IsTransitionError(err) || err == errPaymentAmountChanged {
+		// 订单已取消、已支付或应付金额已变更：记录款项并全额退回患者
+		refunds := &RefundService{}
+		if err := refunds.refundClosedPayment(&record, tradeNo, now); err != nil {
+			if err == errPaymentHandled {
+				return nil
+			}
+			return err
+		}
+		log.Printf("支付单%s支付成功，但订单%d已不在待支付状态，款项全额退回", paymentNo, record.OrderId)
+		refunds.ProcessOrderRefunds(record.OrderId)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	notify(order.CompanionId, NoticeOrderPaid, "患者已支付订单", "订单"+order.OrderNo+"患者已完成支付，请按时到场服务。", order.ID)
+	return nil
+}
+
+// markPayment 以待支付为条件更新支付单结果
+func markPayment(db *gorm.DB, paymentId uint64, status int, tradeNo string, paidAt time.Time) error {
+	updates := map[string]interface{}{"status": status, "paid_at": paidAt}
+	if tradeNo != "" {
+		updates["trade_no"] = tradeNo
+	}
+	result := db.Model(&model.Payment{}).Where("id = ? AND status = ?", paymentId, PaymentPending).Updates(updates)
+	if result.Error != nil {
+		return errors.New("更新支付单失败")
+	}
+	if result.RowsAffected == 0 {
+		return errPaymentHandled
+	}
+	return nil
+}
+
+// CancelUnpaidOrders 取消超过支付时限仍未支付的订单（需求退回订单大厅，返回本次取消数量）
+func (o *OrderService) CancelUnpaidOrders() (int, error) {
+	// 1. 查询支付截止时间已过的待支付订单
+	var orderIds []uint64
+	if err := model.DB.Model(&model.Order{}).
+		Where("status = ? AND pay_deadline < ?", statemachine.OrderPendingPayment, time.Now()).
+		Order("id ASC").Limit(100).Pluck("id", &orderIds).Error; err != nil {
+		return 0, errors.New("查询待支付超时订单失败")
+	}
+
+	// 2. 逐笔取消（状态机以当前状态为条件更新，与支付回调并发时只有一方成功）
+	actor := statemachine.Actor{Role: statemachine.RoleSystem}
+	reason := "患者超过" + strconv.Itoa(conf.AppConfig.Payment.TimeoutMinutes) + "分钟未支付，订单自动取消"
+	cancelled := 0
+	for _, orderId := range orderIds {
+		order, err := o.transitOrderAndGet(orderId, statemachine.EventPayTimeout, actor, reason, nil)
+		if err != nil {
+			if !statemachine.IsTransitionError(err) {
+				log.Printf("订单%d支付超时取消失败：%s", orderId, err)
+			}
+			continue
+		}
+		cancelled++
+
+		notify(order.PatientId, NoticePayTimeout, "订单超时未支付已取消", "订单"+order.OrderNo+"未在时限内完成支付，已自动取消，需求已重新发布到订单大厅。", order.ID)
+		notify(order.CompanionId, NoticePayTimeout, "订单超时未支付已取消", "订单"+order.OrderNo+"患者未在时限内完成支付，订单已取消。", order.ID)
+	}
+
+	return cancelled, nil
+}
+
+// payDeadline 按配置计算支付截止时间
+func payDeadline(now time.Time) time.Time {
+	return now.Add(time.Duration(conf.AppConfig.Payment.TimeoutMinutes) * time.Minute)
+}
+
+// escrowSource 订单结算的资金来源：已预付的订单从托管账户划出，未经预付的历史订单从平台资金清算划出
+func escrowSource(order *model.Order) string {
+	if order.PaidAt != nil {
+		return AccountOrderEscrow
+	}
+	return AccountPlatformClearing
+}
+
+// awaitPaymentEffect 订单进入待支付：设置支付截止时间
+func awaitPaymentEffect(tx *gorm.DB, order *model.Order, actor statemachine.Actor) error {
+	deadline := payDeadline(time.Now())
+	if err := tx.Model(&model.Order{}).Where("id = ?", order.ID).Update("pay_deadline", deadline).Error; err != nil {
+		return errors.New("设置支付截止时间失败")
+	}
+	order.PayDeadline = &deadline
+	return nil
+}
+
+// holdEscrowEffect 预付款记账：平台资金清算（患者实付）+ 平台营销费用（优惠补贴）→ 订单托管（订单金额）
+// 托管账户始终按订单金额入账，后续结算与陪诊师收入不受优惠影响
+func holdEscrowEffect(tx *gorm.DB, order *model.Order, actor statemachine.Actor) error {
+	// ESC-托管前缀
+	return postEntry(tx, utils.GenerateSerialNo("ESC"), BizOrderPayment, order.OrderNo, "订单"+order.OrderNo+"患者预付款托管",
+		Posting{AccountType: AccountPlatformClearing, Amount: -order.PayAmount()},
+		Posting{AccountType: AccountPlatformMarketing, Amount: -order.DiscountAmount},
+		Posting{AccountType: AccountOrderEscrow, Amount: order.OrderAmount},
+	)
+}
@@ -0,0 +1,366 @@
+// service/payout.go
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 结果文件逐行处理结果
+const (
+	PayoutLineSuccess = "success" // 确认打款成功
+	PayoutLineFail    = "fail"    // 确认打款失败，金额已退回陪诊师可用余额
+	PayoutLineSkipped = "skipped" // 跳过（已按相同结果处理过，或银行仍在处理中）
+	PayoutLineError   = "error"   // 无法处理（提现单不存在、金额不符、与已处理结果冲突等）
+)
+
+// PayoutService 提现批量打款服务：导出提现中的提现单为银行批量转账文件，导入银行结果文件确认打款结果
+type PayoutService struct{}
+
+// PayoutImportLine 结果文件单行的处理结果
+type PayoutImportLine struct {
+	Line     int    `json:"line"`      // 文件行号（含表头，从1开始）
+	SerialNo string `json:"serial_no"` // 提现编号
+	Result   string `json:"result"`    // 处理结果（success/fail/skipped/error）
+	Message  string `json:"message"`   // 说明
+}
+
+// PayoutImportResult 结果文件导入汇总
+type PayoutImportResult struct {
+	Total     int                `json:"total"`     // 数据行数
+	Succeeded int                `json:"succeeded"` // 确认打款成功笔数
+	Failed    int                `json:"failed"`    // 确认打款失败笔数
+	Skipped   int                `json:"skipped"`   // 跳过笔数
+	Errors    int                `json:"errors"`    // 无法处理笔数
+	Lines     []PayoutImportLine `json:"lines"`
+}
+
+// ExportBatch 将已审核通过、尚未导出的提现中提现单生成打款批次，返回批次及银行批量转账文件内容
+// 重复调用不会重复导出：已进入批次的提现单不会再次导出，没有新的待打款提现单时返回错误
+func (p *PayoutService) ExportBatch(adminId uint64) (*model.PayoutBatch, []byte, error) {
+	// 开启事务（锁定提现单+生成批次+标记批次编号）
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return nil, nil, errors.New("开启事务失败")
+	}
+
+	// 1. 锁定已审核通过、尚未导出的提现中提现单（FOR UPDATE 保证并发导出时同一笔提现只进入一个批次）
+	var withdrawalList []model.Withdrawal
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").
+		Where("status = ? AND settled_at IS NULL AND approved_at IS NOT NULL AND batch_no = ?", RecordWithdrawing, "").
+		Order("id ASC").Find(&withdrawalList).Error; err != nil {
+		tx.Rollback()
+		return nil, nil, errors.New("查询待打款提现单失败")
+	}
+	if len(withdrawalList) == 0 {
+		tx.Rollback()
+		return nil, nil, errors.New("暂无待打款的提现单")
+	}
+
+	// 2. 生成打款文件（先于落库校验列配置，配置错误时不生成批次）
+	data, err := renderPayoutFile(withdrawalList)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	// 3. 生成批次并标记提现单
+	batch := model.PayoutBatch{
+		BatchNo: utils.GenerateSerialNo("PYB"), // PYB-打款批次前缀
+		AdminId: adminId,
+		Count:   len(withdrawalList),
+	}
+	ids := make([]uint64, len(withdrawalList))
+	for i, withdrawal := range withdrawalList {
+		ids[i] = withdrawal.ID
+		batch.TotalAmount += withdrawal.ActualAmount
+	}
+	if err := tx.Create(&batch).Error; err != nil {
+		tx.Rollback()
+		return nil, nil, errors.New("生成打款批次失败")
+	}
+	if err := tx.Model(&model.Withdrawal{}).Where("id IN (?)", ids).Update("batch_no", batch.BatchNo).Error; err != nil {
+		tx.Rollback()
+		return nil, nil, errors.New("更新提现单打款批次失败")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, nil, errors.New("导出打款批次事务提交失败")
+	}
+	return &batch, data, nil
+}
+
+// GetBatchFile 重新下载打款批次的银行批量转账文件（按当前列配置生成）
+func (p *PayoutService) GetBatchFile(batchNo string) (*model.PayoutBatch, []byte, error) {
+	var batch model.PayoutBatch
+	if err := model.DB.Where("batch_no = ?", batchNo).First(&batch).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil, errors.New("打款批次不存在")
+		}
+		return nil, nil, errors.New("查询打款批次失败")
+	}
+
+	var withdrawalList []model.Withdrawal
+	if err := model.DB.Where("batch_no = ?", batchNo).Order("id ASC").Find(&withdrawalList).Error; err != nil {
+		return nil, nil, errors.New("查询批次提现单失败")
+	}
+	data, err := renderPayoutFile(withdrawalList)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &batch, data, nil
+}
+
+// GetBatchList 管理员查询打款批次列表（按导出时间倒序）
+func (p *PayoutService) GetBatchList(page int, size int) ([]model.PayoutBatch, int64, error) {
+	var batchList []model.PayoutBatch
+	var total int64
+
+	query := model.DB.Model(&model.PayoutBatch{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("查询打款批次总数失败")
+	}
+
+	offset := (page - 1) * size
+	if err := query.Order("id DESC").Offset(offset).Limit(size).Find(&batchList).Error; err != nil {
+		return nil, 0, errors.New("查询打款批次列表失败")
+	}
+	return batchList, total, nil
+}
+
+// ImportResult 导入银行结果文件，逐行确认提现打款结果（失败的金额退回陪诊师可用余额）
+// 已按相同结果处理过的提现单直接跳过，同一文件重复导入不会重复记账
+func (p *PayoutService) ImportResult(file io.Reader, adminId uint64) (*PayoutImportResult, error) {
+	rule := conf.AppConfig.Payout.Result
+
+	// 1. 读取结果文件（去除 UTF-8 BOM，允许各行列数不一致）
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, errors.New("读取结果文件失败")
+	}
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\xEF\xBB\xBF"))))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.New("结果文件格式错误：" + err.Error())
+	}
+	if len(records) < 2 {
+		return nil, errors.New("结果文件无数据")
+	}
+
+	// 2. 按表头定位列
+	columns := map[string]int{}
+	for i, header := range records[0] {
+		columns[strings.TrimSpace(header)] = i
+	}
+	column := func(header string, required bool) (int, error) {
+		if header == "" && !required {
+			return -1, nil
+		}
+		index, ok := columns[header]
+		if !ok {
+			return -1, errors.New("结果文件缺少「" + header + "」列")
+		}
+		return index, nil
+	}
+	serialCol, err := column(rule.SerialNoColumn, true)
+	if err != nil {
+		return nil, err
+	}
+	statusCol, err := column(rule.StatusColumn, true)
+	if err != nil {
+		return nil, err
+	}
+	reasonCol, err := column(rule.ReasonColumn, false)
+	if err != nil {
+		return nil, err
+	}
+	amountCol, err := column(rule.AmountColumn, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 逐行处理
+	result := &PayoutImportResult{Lines: []PayoutImportLine{}}
+	for i, record := range records[1:] {
+		cell := func(index int) string {
+			if index < 0 || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+		line := PayoutImportLine{Line: i + 2, SerialNo: cell(serialCol)}
+		if line.SerialNo == "" && cell(statusCol) == "" {
+			continue // 空行
+		}
+		result.Total++
+
+		line.Result, line.Message = p.importLine(line.SerialNo, cell(statusCol), cell(reasonCol), cell(amountCol), amountCol >= 0, adminId)
+		switch line.Result {
+		case PayoutLineSuccess:
+			result.Succeeded++
+		case PayoutLineFail:
+			result.Failed++
+		case PayoutLineSkipped:
+			result.Skipped++
+		default:
+			result.Errors++
+		}
+		result.Lines = append(result.Lines, line)
+	}
+
+	log.Printf("导入提现打款结果：管理员%d 共%d行，成功%d笔，失败%d笔，跳过%d笔，无法处理%d笔",
+		adminId, result.Total, result.Succeeded, result.Failed, result.Skipped, result.Errors)
+	return result, nil
+}
+
+// importLine 处理结果文件的一行，返回处理结果与说明
+func (p *PayoutService) importLine(serialNo string, status string, reason string, amount string, checkAmount bool, adminId uint64) (string, string) {
+	rule := conf.AppConfig.Payout.Result
+
+	// 1. 解析银行处理结果（其他取值视为银行处理中）
+	var newType int
+	switch {
+	case containsString(rule.SuccessValues, status):
+		newType = RecordWithdrawSuccess
+	case containsString(rule.FailValues, status):
+		newType = RecordWithdrawFail
+	default:
+		return PayoutLineSkipped, "银行处理中（处理结果：" + status + "）"
+	}
+
+	// 2. 查询提现单
+	var withdrawal model.Withdrawal
+	if err := model.DB.Where("serial_no = ?", serialNo).First(&withdrawal).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return PayoutLineError, "提现单不存在"
+		}
+		return PayoutLineError, "查询提现单失败"
+	}
+	if withdrawal.BatchNo == "" {
+		return PayoutLineError, "提现单未导出打款，不能按结果文件处理"
+	}
+
+	// 3. 已处理的提现单：结果一致则跳过，不一致需人工核查
+	if withdrawal.Status != RecordWithdrawing {
+		if withdrawal.Status == newType {
+			return PayoutLineSkipped, "已处理"
+		}
+		return PayoutLineError, "与已处理结果不一致，请人工核查"
+	}
+
+	// 4. 核对打款金额
+	if checkAmount {
+		paid, err := utils.ParseMoney(amount)
+		if err != nil {
+			return PayoutLineError, "打款金额格式错误"
+		}
+		if paid != withdrawal.ActualAmount {
+			return PayoutLineError, "打款金额" + paid.String() + "元与实际到账金额" + withdrawal.ActualAmount.String() + "元不一致"
+		}
+	}
+
+	// 5. 确认打款结果
+	remark := "批量打款成功（批次" + withdrawal.BatchNo + "）"
+	if newType == RecordWithdrawFail {
+		remark = "银行打款失败"
+		if reason != "" {
+			remark += "：" + reason
+		}
+	}
+	if err := (&BalanceService{}).UpdateWithdrawStatus(serialNo, newType, adminId, remark); err != nil {
+		return PayoutLineError, err.Error()
+	}
+	if newType == RecordWithdrawFail {
+		return PayoutLineFail, remark
+	}
+	return PayoutLineSuccess, remark
+}
+
+// renderPayoutFile 按配置的列布局生成银行批量转账文件（CSV）
+func renderPayoutFile(withdrawalList []model.Withdrawal) ([]byte, error) {
+	layout := conf.AppConfig.Payout
+	if len(layout.ExportColumns) == 0 {
+		return nil, errors.New("未配置打款文件列布局")
+	}
+
+	rows := [][]string{}
+	if layout.ExportHeader {
+		header := make([]string, len(layout.ExportColumns))
+		for i, column := range layout.ExportColumns {
+			header[i] = column.Header
+		}
+		rows = append(rows, header)
+	}
+	for _, withdrawal := range withdrawalList {
+		row := make([]string, len(layout.ExportColumns))
+		for i, column := range layout.ExportColumns {
+			switch column.Field {
+			case "serial_no":
+				row[i] = withdrawal.SerialNo
+			case "account":
+				row[i] = payoutSafeCell(withdrawal.Account)
+			case "real_name":
+				row[i] = payoutSafeCell(withdrawal.RealName)
+			case "amount":
+				row[i] = withdrawal.ActualAmount.String()
+			case "companion_id":
+				row[i] = strconv.FormatUint(withdrawal.CompanionId, 10)
+			case "fixed":
+				row[i] = column.Value
+			default:
+				return nil, errors.New("打款文件列配置错误：不支持的字段" + column.Field)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	var buf bytes.Buffer
+	if err := csv.NewWriter(&buf).WriteAll(rows); err != nil {
+		return nil, errors.New("生成打款文件失败")
+	}
+	return buf.Bytes(), nil
+}
+
+// payoutFormulaPrefixes 表格软件视为公式开头的字符
+const payoutFormulaPrefixes = "=+-@\t\r"
+
+// hasFormulaPrefix 判断内容是否以公式触发字符开头
+func hasFormulaPrefix(s string) bool {
+	return s != "" && strings.ContainsRune(payoutFormulaPrefixes, rune(s[0]))
+}
+
+// payoutSafeCell 陪诊师填写的内容以公式触发字符开头时前置单引号（CSV 注入的通用防护），防止打款文件被表格软件当作公式执行
+// 申请提现时已拒绝此类收款信息，此处仅防护历史数据；原值不做删改，导出后由财务人工核对
+func payoutSafeCell(s string) string {
+	if hasFormulaPrefix(s) {
+		return "'" + s
+	}
+	return s
+}
+
+// containsString 判断取值列表中是否包含指定值
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
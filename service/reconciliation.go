@@ -0,0 +1,324 @@
+// service/reconciliation.go
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 对账触发方式
+const (
+	ReconcileTriggerJob     = "job"     // 定时任务
+	ReconcileTriggerCommand = "command" // 命令行
+)
+
+// 对账批次状态
+const (
+	ReconcileRunning  = 0 // 对账中
+	ReconcileFinished = 1 // 已完成
+	ReconcileFailed   = 2 // 失败
+)
+
+// reconcileBatchSize 每批核对的陪诊师数
+const reconcileBatchSize = 500
+
+// reconcileAccountTypes 逐户核对的陪诊师账户
+var reconcileAccountTypes = []string{AccountCompanionAvailable, AccountCompanionFrozen}
+
+// ReconciliationService 余额对账服务
+// 陪诊师可用余额、冻结余额以账户的分录行（即余额明细）之和为准，核对账本账户余额与 users.balance 两处冗余是否一致；
+// 同时核对账本试算平衡（全部分录行之和为0、每张凭证借贷平衡）。平台与患者账户的余额冗余不逐户核对，仅由试算平衡覆盖
+type ReconciliationService struct{}
+
+// ReconciliationReport 对账报告（批次及差异明细）
+type ReconciliationReport struct {
+	Run   *model.ReconciliationRun   `json:"run"`
+	Items []model.ReconciliationItem `json:"items"`
+}
+
+// companionBalanceRow 陪诊师 users.balance
+type companionBalanceRow struct {
+	ID      uint64
+	Balance utils.Money
+}
+
+// postingSumRow 账户分录行汇总
+type postingSumRow struct {
+	AccountId uint64
+	Total     utils.Money
+	Count     int
+}
+
+// Reconcile 核对账本试算平衡与全部陪诊师余额并生成对账批次（fix 为 true 时修正不一致的账户，见 recheckAccount）
+func (r *ReconciliationService) Reconcile(trigger string, fix bool) (*model.ReconciliationRun, error) {
+	// 1. 生成对账批次
+	run := model.ReconciliationRun{
+		RunNo:     utils.GenerateSerialNo("REC"), // REC-对账前缀
+		Trigger:   trigger,
+		Fix:       fix,
+		Status:    ReconcileRunning,
+		StartedAt: time.Now(),
+	}
+	if err := model.DB.Create(&run).Error; err != nil {
+		return nil, errors.New("生成对账批次失败")
+	}
+
+	// 2. 试算平衡与分批核对
+	err := r.checkTrialBalance(&run)
+	if err == nil {
+		err = r.reconcileAll(&run)
+	}
+
+	// 3. 记录对账结果
+	now := time.Now()
+	run.FinishedAt = &now
+	run.Status = ReconcileFinished
+	if err != nil {
+		run.Status = ReconcileFailed
+		run.ErrorMsg = err.Error()
+	}
+	if saveErr := model.DB.Model(&model.ReconciliationRun{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+		"status":                 run.Status,
+		"checked_count":          run.CheckedCount,
+		"mismatch_count":         run.MismatchCount,
+		"fixed_count":            run.FixedCount,
+		"trial_balance":          run.TrialBalance,
+		"unbalanced_entry_count": run.UnbalancedEntryCount,
+		"error_msg":              run.ErrorMsg,
+		"finished_at":            now,
+	}).Error; saveErr != nil && err == nil {
+		err = errors.New("更新对账批次失败")
+	}
+	return &run, err
+}
+
+// GetLatestReport 查询最近一次对账的批次及差异明细
+func (r *ReconciliationService) GetLatestReport() (*ReconciliationReport, error) {
+	var run model.ReconciliationRun
+	if err := model.DB.Order("id DESC").First(&run).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("暂无对账记录")
+		}
+		return nil, errors.New("查询对账批次失败")
+	}
+
+	items := []model.ReconciliationItem{}
+	if err := model.DB.Where("run_id = ?", run.ID).Order("id ASC").Find(&items).Error; err != nil {
+		return nil, errors.New("查询对账差异明细失败")
+	}
+	return &ReconciliationReport{Run: &run, Items: items}, nil
+}
+
+// checkTrialBalance 核对账本试算平衡：全部分录行金额之和应为0，且不存在借贷不平衡的凭证（仅报告，不修正）
+func (r *ReconciliationService) checkTrialBalance(run *model.ReconciliationRun) error {
+	var total struct {
+		Total utils.Money
+	}
+	if err := model.DB.Table("ledger_postings").Select("COALESCE(SUM(amount), 0) AS total").Scan(&total).Error; err != nil {
+		return errors.New("查询账本试算平衡失败")
+	}
+	var unbalanced struct {
+		Count int
+	}
+	if err := model.DB.Raw("SELECT COUNT(*) AS count FROM (SELECT entry_id FROM ledger_postings GROUP BY entry_id HAVING SUM(amount) <> 0) t").
+		Scan(&unbalanced).Error; err != nil {
+		return errors.New("查询不平衡凭证失败")
+	}
+
+	run.TrialBalance, run.UnbalancedEntryCount = total.Total, unbalanced.Count
+	if run.TrialBalance != 0 || run.UnbalancedEntryCount > 0 {
+		log.Printf("余额对账：账本试算不平衡，分录行合计%s，借贷不平衡凭证%d张", run.TrialBalance.String(), run.UnbalancedEntryCount)
+	}
+	return nil
+}
+
+// reconcileAll 按陪诊师ID分批比对：先以汇总查询找出疑似不一致的账户，再逐个加锁复核（排除与记账并发造成的误报）
+func (r *ReconciliationService) reconcileAll(run *model.ReconciliationRun) error {
+	var lastId uint64
+	for {
+		// 1. 查询本批陪诊师
+		var companions []companionBalanceRow
+		if err := model.DB.Model(&model.User{}).Where("user_type = ? AND id > ?", 2, lastId).
+			Order("id ASC").Limit(reconcileBatchSize).Select("id, balance").Scan(&companions).Error; err != nil {
+			return errors.New("查询陪诊师失败")
+		}
+		if len(companions) == 0 {
+			return nil
+		}
+		lastId = companions[len(companions)-1].ID
+
+		// 2. 查询可用余额、冻结余额账户及分录行汇总
+		companionIds := make([]uint64, len(companions))
+		for i, companion := range companions {
+			companionIds[i] = companion.ID
+		}
+		var accounts []model.LedgerAccount
+		if err := model.DB.Where("account_type IN (?) AND owner_id IN (?)", reconcileAccountTypes, companionIds).Find(&accounts).Error; err != nil {
+			return errors.New("查询账本账户失败")
+		}
+		accountMap := map[string]map[uint64]model.LedgerAccount{}
+		accountIds := []uint64{}
+		for _, account := range accounts {
+			if accountMap[account.AccountType] == nil {
+				accountMap[account.AccountType] = map[uint64]model.LedgerAccount{}
+			}
+			accountMap[account.AccountType][account.OwnerId] = account
+			accountIds = append(accountIds, account.ID)
+		}
+		sums, err := sumAccountPostings(model.DB, accountIds)
+		if err != nil {
+			return err
+		}
+
+		// 3. 比对，不一致的加锁复核（并按需修正）
+		for _, companion := range companions {
+			run.CheckedCount++
+			for _, accountType := range reconcileAccountTypes {
+				account := accountMap[accountType][companion.ID]
+				expected := sums[account.ID].Total
+				if account.Balance == expected && (!userBalanceAccounts[accountType] || companion.Balance == expected) {
+					continue
+				}
+				item, err := r.recheckAccount(run, companion.ID, accountType)
+				if err != nil {
+					return err
+				}
+				if item == nil {
+					continue
+				}
+				run.MismatchCount++
+				if item.Fixed {
+					run.FixedCount++
+				}
+				log.Printf("余额对账：陪诊师%d 账户%s 应有余额%s 账户余额%s（差异%s） users.balance %s（差异%s） 已修正：%t %s",
+					item.CompanionId, item.AccountType, item.ExpectedBalance.String(), item.AccountBalance.String(), item.AccountDrift.String(),
+					item.UserBalance.String(), item.UserDrift.String(), item.Fixed, item.AdjustEntryNo)
+			}
+		}
+	}
+}
+
+// recheckAccount 锁定陪诊师账户（可用余额账户同时锁定用户记录）后复核，仍不一致时记录差异明细，一致时返回 nil
+// 加锁顺序与记账一致（先账户后用户），复核期间不会有新的分录写入。run.Fix 时修正：
+//   - 账户余额按定义等于分录行之和，直接改回应有余额；
+//   - users.balance 是陪诊师所见余额（人工改动也落在此处），以其为准补记一张余额调整凭证（平台资金清算 ↔ 陪诊师可用余额），
+//     使账本能解释这笔差异，调整凭证编号记入差异明细
+func (r *ReconciliationService) recheckAccount(run *model.ReconciliationRun, companionId uint64, accountType string) (*model.ReconciliationItem, error) {
+	syncUser := userBalanceAccounts[accountType]
+
+	// 1. 开启事务
+	tx := model.DB.Begin()
+	defer func() {
+		if rec := recover(); rec != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return nil, errors.New("开启事务失败")
+	}
+
+	// 2. 锁定账户与用户（尚未开立账户时应有余额为0）
+	var account model.LedgerAccount
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").
+		Where("account_type = ? AND owner_id = ?", accountType, companionId).First(&account).Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		tx.Rollback()
+		return nil, errors.New("查询账本账户失败")
+	}
+	var user companionBalanceRow
+	if syncUser {
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Model(&model.User{}).
+			Where("id = ?", companionId).Select("id, balance").Scan(&user).Error; err != nil {
+			tx.Rollback()
+			return nil, errors.New("查询陪诊师失败")
+		}
+	}
+
+	// 3. 重新汇总分录行
+	var sum postingSumRow
+	if account.ID != 0 {
+		sums, err := sumAccountPostings(tx, []uint64{account.ID})
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		sum = sums[account.ID]
+	}
+	item := model.ReconciliationItem{
+		RunId:           run.ID,
+		CompanionId:     companionId,
+		AccountType:     accountType,
+		ExpectedBalance: sum.Total,
+		AccountBalance:  account.Balance,
+		AccountDrift:    account.Balance - sum.Total,
+		PostingCount:    sum.Count,
+		Fixed:           run.Fix,
+	}
+	if syncUser {
+		item.UserBalance, item.UserDrift = user.Balance, user.Balance-sum.Total
+	}
+	if item.AccountDrift == 0 && item.UserDrift == 0 {
+		tx.Rollback()
+		return nil, nil
+	}
+
+	// 4. 修正
+	if run.Fix {
+		// 账户余额改回分录行之和
+		if item.AccountDrift != 0 {
+			if err := tx.Model(&model.LedgerAccount{}).Where("id = ?", account.ID).Update("balance", sum.Total).Error; err != nil {
+				tx.Rollback()
+				return nil, errors.New("修正账户余额失败")
+			}
+		}
+		// users.balance 差异补记余额调整凭证：记账会同步累加 users.balance，而差异已在其中，记账后扣回同等金额
+		if item.UserDrift != 0 {
+			item.AdjustEntryNo = utils.GenerateSerialNo("ADJ") // ADJ-余额调整前缀
+			if err := postEntry(tx, item.AdjustEntryNo, BizBalanceAdjust, run.RunNo, "余额对账调整（对账批次"+run.RunNo+"）",
+				Posting{AccountType: accountType, OwnerId: companionId, Amount: item.UserDrift, RecordType: RecordAdjustment},
+				Posting{AccountType: AccountPlatformClearing, Amount: -item.UserDrift},
+			); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if err := tx.Model(&model.User{}).Where("id = ?", companionId).
+				Update("balance", gorm.Expr("balance - CAST(? AS DECIMAL(10,2))", item.UserDrift)).Error; err != nil {
+				tx.Rollback()
+				return nil, errors.New("修正用户余额失败")
+			}
+		}
+	}
+
+	// 5. 记录差异明细并提交
+	if err := tx.Create(&item).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("记录对账差异失败")
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New("对账事务提交失败")
+	}
+	return &item, nil
+}
+
+// sumAccountPostings 按账户汇总分录行金额与笔数
+func sumAccountPostings(db *gorm.DB, accountIds []uint64) (map[uint64]postingSumRow, error) {
+	sums := map[uint64]postingSumRow{}
+	if len(accountIds) == 0 {
+		return sums, nil
+	}
+
+	var rows []postingSumRow
+	if err := db.Table("ledger_postings").Where("account_id IN (?)", accountIds).
+		Select("account_id, COALESCE(SUM(amount), 0) AS total, COUNT(*) AS count").Group("account_id").Scan(&rows).Error; err != nil {
+		return nil, errors.New("查询账本明细失败")
+	}
+	for _, row := range rows {
+		sums[row.AccountId] = row
+	}
+	return sums, nil
+}
@@ -0,0 +1,359 @@
+// service/refund.go
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/payment"
+	"github.com/X-Colder/companion-backend/statemachine"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 退款单状态
+const (
+	RefundPending    = 0 // 待退款（含调用渠道失败、等待重试）
+	RefundProcessing = 1 // 退款处理中（渠道已受理，等待结果）
+	RefundSucceeded  = 2 // 退款成功
+	RefundFailed     = 3 // 退款失败（超过最大重试次数，需人工处理）
+)
+
+// RefundService 退款服务（预付款按原支付单原路退回）
+type RefundService struct{}
+
+// refundPostings 从订单托管金额中退回 amount 的分录行：
+// 使用优惠券的订单按优惠比例拆分，优惠承担部分退回平台营销费用，其余退回患者；
+// 已预付订单登记退款单，金额计入退款在途（渠道退款成功后结清）；
+// 未经预付的历史订单患者并未实际付款，不退款也不计入患者余额，该部分计入平台收入（与从平台资金清算划出的订单金额相抵）
+func refundPostings(tx *gorm.DB, order *model.Order, amount utils.Money, reason string) ([]Posting, error) {
+	if amount <= 0 {
+		return nil, nil
+	}
+	subsidy := couponSubsidy(order, amount)
+	postings := []Posting{{AccountType: AccountPlatformMarketing, Amount: subsidy}}
+	refundAmount := amount - subsidy
+	if refundAmount <= 0 {
+		return postings, nil
+	}
+	if order.PaidAt == nil {
+		return append(postings, Posting{AccountType: AccountPlatformRevenue, Amount: refundAmount}), nil
+	}
+
+	var paid model.Payment
+	if err := tx.Where("order_id = ? AND status = ?", order.ID, PaymentPaid).First(&paid).Error; err != nil {
+		return nil, errors.New("查询订单原支付单失败")
+	}
+	if err := createRefund(tx, &paid, order.OrderNo, refundAmount, reason); err != nil {
+		return nil, err
+	}
+	return append(postings, Posting{AccountType: AccountRefundInTransit, OwnerId: order.PatientId, Amount: refundAmount, RecordType: RecordRefund}), nil
+}
+
+// createRefund 登记退款单（在调用方事务内执行，渠道退款在事务提交后由 ProcessOrderRefunds 或定时任务发起）
+func createRefund(tx *gorm.DB, paid *model.Payment, orderNo string, amount utils.Money, reason string) error {
+	refund := model.Refund{
+		RefundNo:  utils.GenerateSerialNo("REF"), // REF-退款前缀
+		OrderId:   paid.OrderId,
+		OrderNo:   orderNo,
+		PatientId: paid.PatientId,
+		PaymentNo: paid.PaymentNo,
+		Provider:  paid.Provider,
+		Amount:    amount,
+		Reason:    reason,
+		Status:    RefundPending,
+		// 截断到秒：数据库时间精度为秒，避免四舍五入后晚于当前时间导致无法立即发起
+		NextRetryAt: time.Now().Truncate(time.Second),
+	}
+	if err := tx.Create(&refund).Error; err != nil {
+		return errors.New("生成退款单失败")
+	}
+	return nil
+}
+
+// refundClosedPayment 订单已关闭后才支付成功的款项全额退回（同一事务：支付单标记为「支付成功但订单已关闭」+ 登记退款单 + 记账：平台资金清算 → 退款在途）
+func (r *RefundService) refundClosedPayment(paid *model.Payment, tradeNo string, paidAt time.Time) error {
+	var order model.Order
+	if err := model.DB.Where("id = ?", paid.OrderId).First(&order).Error; err != nil {
+		return errors.New("查询订单失败")
+	}
+
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	if err := markPayment(tx, paid.ID, PaymentPaidButClosed, tradeNo, paidAt); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	reason := "订单" + order.OrderNo + "已关闭，支付款退回"
+	if err := createRefund(tx, paid, order.OrderNo, paid.Amount, reason); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := postEntry(tx, utils.GenerateSerialNo("RTN"), BizPaymentReturn, order.OrderNo, reason,
+		Posting{AccountType: AccountPlatformClearing, Amount: -paid.Amount},
+		Posting{AccountType: AccountRefundInTransit, OwnerId: paid.PatientId, Amount: paid.Amount, RecordType: RecordRefund},
+	); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return errors.New("登记退款事务提交失败")
+	}
+	return nil
+}
+
+// ProcessOrderRefunds 立即为订单待退款的退款单发起渠道退款（业务提交后调用，失败仅记录日志，由定时任务重试）
+func (r *RefundService) ProcessOrderRefunds(orderId uint64) {
+	var refundList []model.Refund
+	if err := model.DB.Where("order_id = ? AND status = ?", orderId, RefundPending).Find(&refundList).Error; err != nil {
+		log.Printf("查询订单%d待退款记录失败：%s", orderId, err)
+		return
+	}
+	for _, refund := range refundList {
+		if _, err := r.processRefund(refund); err != nil {
+			log.Printf("退款单%s处理失败：%s", refund.RefundNo, err)
+		}
+	}
+}
+
+// RetryRefunds 重试到期的待退款/处理中退款单（返回本次退款成功数量）
+func (r *RefundService) RetryRefunds() (int, error) {
+	var refundList []model.Refund
+	if err := model.DB.Where("status IN (?) AND next_retry_at <= ?", []int{RefundPending, RefundProcessing}, time.Now()).
+		Order("id ASC").Limit(100).Find(&refundList).Error; err != nil {
+		return 0, errors.New("查询待重试退款单失败")
+	}
+
+	succeeded := 0
+	for _, refund := range refundList {
+		ok, err := r.processRefund(refund)
+		if err != nil {
+			log.Printf("退款单%s处理失败：%s", refund.RefundNo, err)
+			continue
+		}
+		if ok {
+			succeeded++
+		}
+	}
+	return succeeded, nil
+}
+
+// processRefund 调用渠道退款：先以「到期未处理」为条件占用本次重试（多实例并发时只有一方调用渠道），再按渠道结果更新退款单
+func (r *RefundService) processRefund(refund model.Refund) (bool, error) {
+	// 1. 占用本次重试：累加调用次数并预设下次重试时间（本次调用异常中断时到期后自动重试）
+	now := time.Now()
+	attempts := refund.Attempts + 1
+	result := model.DB.Model(&model.Refund{}).
+		Where("id = ? AND status IN (?) AND attempts = ? AND next_retry_at <= ?", refund.ID, []int{RefundPending, RefundProcessing}, refund.Attempts, now).
+		Updates(map[string]interface{}{
+			"attempts":      attempts,
+			"next_retry_at": now.Add(refundRetryDelay(attempts)),
+		})
+	if result.Error != nil {
+		return false, errors.New("更新退款单失败")
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	// 2. 调用渠道退款（同一退款单号重复请求，渠道保证只退一次）
+	var paid model.Payment
+	if err := model.DB.Where("payment_no = ?", refund.PaymentNo).First(&paid).Error; err != nil {
+		return false, errors.New("查询原支付单失败")
+	}
+	provider, err := payment.Get(refund.Provider)
+	if err != nil {
+		return false, r.failRefund(&refund, attempts, err.Error())
+	}
+	res, err := provider.Refund(payment.RefundRequest{
+		PaymentNo: paid.PaymentNo,
+		TradeNo:   paid.TradeNo,
+		RefundNo:  refund.RefundNo,
+		Amount:    refund.Amount,
+		Total:     paid.Amount,
+		Reason:    refund.Reason,
+	})
+	if err != nil {
+		return false, r.failRefund(&refund, attempts, err.Error())
+	}
+
+	// 3. 按渠道结果更新
+	switch res.Status {
+	case payment.RefundFailed:
+		return false, r.failRefund(&refund, attempts, res.FailReason)
+	case payment.RefundProcessing:
+		if err := model.DB.Model(&model.Refund{}).Where("id = ? AND status IN (?)", refund.ID, []int{RefundPending, RefundProcessing}).Updates(map[string]interface{}{
+			"status":             RefundProcessing,
+			"provider_refund_no": res.ProviderRefundNo,
+		}).Error; err != nil {
+			return false, errors.New("更新退款单失败")
+		}
+		return false, nil
+	}
+	return true, r.succeedRefund(&refund, res.ProviderRefundNo)
+}
+
+// failRefund 记录渠道退款失败：未超过最大重试次数时等待重试，否则标记为退款失败并通知患者
+func (r *RefundService) failRefund(refund *model.Refund, attempts int, reason string) error {
+	status := RefundPending
+	if attempts >= refundMaxAttempts() {
+		status = RefundFailed
+	}
+	if err := model.DB.Model(&model.Refund{}).Where("id = ? AND status IN (?)", refund.ID, []int{RefundPending, RefundProcessing}).Updates(map[string]interface{}{
+		"status":      status,
+		"fail_reason": reason,
+	}).Error; err != nil {
+		return errors.New("更新退款单失败")
+	}
+
+	if status == RefundFailed {
+		log.Printf("退款单%s已重试%d次仍失败，需人工处理：%s", refund.RefundNo, attempts, reason)
+		notify(refund.PatientId, NoticeRefundFailed, "退款遇到问题", "订单"+refund.OrderNo+"的退款（"+refund.Amount.String()+"元）暂未成功，平台将尽快人工处理。", refund.OrderId)
+	}
+	return errors.New("渠道退款失败：" + reason)
+}
+
+// succeedRefund 退款成功：更新退款单并记账（退款在途 → 平台资金清算，款项流出平台）
+func (r *RefundService) succeedRefund(refund *model.Refund, providerRefundNo string) error {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	// 以未完成为条件更新，避免重复记账
+	result := tx.Model(&model.Refund{}).Where("id = ? AND status IN (?)", refund.ID, []int{RefundPending, RefundProcessing}).Updates(map[string]interface{}{
+		"status":             RefundSucceeded,
+		"provider_refund_no": providerRefundNo,
+		"fail_reason":        "",
+		"succeeded_at":       time.Now(),
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		return errors.New("更新退款单失败")
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil
+	}
+
+	if err := postEntry(tx, utils.GenerateSerialNo("RFS"), BizRefundSuccess, refund.RefundNo, "退款"+refund.RefundNo+"已退回原支付账户",
+		Posting{AccountType: AccountRefundInTransit, OwnerId: refund.PatientId, Amount: -refund.Amount},
+		Posting{AccountType: AccountPlatformClearing, Amount: refund.Amount},
+	); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return errors.New("退款成功事务提交失败")
+	}
+
+	notify(refund.PatientId, NoticeRefundSucceeded, "退款成功", "订单"+refund.OrderNo+"的退款"+refund.Amount.String()+"元已原路退回。", refund.OrderId)
+	return nil
+}
+
+// GetOrderRefundList 患者查询订单的退款进度
+func (r *RefundService) GetOrderRefundList(orderId uint64, patientId uint64) ([]model.Refund, error) {
+	var order model.Order
+	if err := model.DB.Where("id = ? AND patient_id = ?", orderId, patientId).First(&order).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, statemachine.ErrNotParticipant
+		}
+		return nil, errors.New("查询订单失败")
+	}
+
+	refundList := []model.Refund{}
+	if err := model.DB.Where("order_id = ?", orderId).Order("id ASC").Find(&refundList).Error; err != nil {
+		return nil, errors.New("查询退款记录失败")
+	}
+	return refundList, nil
+}
+
+// GetRefundList 管理员查询退款单列表（status<0 表示不筛选状态）
+func (r *RefundService) GetRefundList(status int, page int, size int) ([]model.Refund, int64, error) {
+	var refundList []model.Refund
+	var total int64
+
+	query := model.DB.Model(&model.Refund{})
+	if status >= 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.New("查询退款单总数失败")
+	}
+
+	offset := (page - 1) * size
+	if err := query.Order("id DESC").Offset(offset).Limit(size).Find(&refundList).Error; err != nil {
+		return nil, 0, errors.New("查询退款单列表失败")
+	}
+	return refundList, total, nil
+}
+
+// RetryFailedRefund 管理员重新发起退款失败的退款单（重置重试次数后立即调用渠道）
+func (r *RefundService) RetryFailedRefund(refundNo string) error {
+	var refund model.Refund
+	if err := model.DB.Where("refund_no = ?", refundNo).First(&refund).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("退款单不存在")
+		}
+		return errors.New("查询退款单失败")
+	}
+
+	result := model.DB.Model(&model.Refund{}).Where("id = ? AND status = ?", refund.ID, RefundFailed).Updates(map[string]interface{}{
+		"status":        RefundPending,
+		"attempts":      0,
+		"next_retry_at": time.Now().Truncate(time.Second),
+	})
+	if result.Error != nil {
+		return errors.New("更新退款单失败")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("仅退款失败的退款单可重新发起")
+	}
+
+	r.ProcessOrderRefunds(refund.OrderId)
+	return nil
+}
+
+// refundMaxAttempts 渠道退款最大调用次数
+func refundMaxAttempts() int {
+	if n := conf.AppConfig.Refund.MaxAttempts; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// refundRetryDelay 第 attempts 次调用后的重试间隔（按基础间隔指数退避，最长一天）
+func refundRetryDelay(attempts int) time.Duration {
+	delay := time.Duration(conf.AppConfig.Refund.RetryBaseSeconds) * time.Second
+	for i := 1; i < attempts && delay < 24*time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > 24*time.Hour {
+		delay = 24 * time.Hour
+	}
+	return delay
+}
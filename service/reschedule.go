@@ -0,0 +1,286 @@
+// service/reschedule.go
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/statemachine"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 改约申请状态
+const (
+	ReschedulePending   = 0 // 待处理
+	RescheduleAccepted  = 1 // 已同意
+	RescheduleRejected  = 2 // 已拒绝
+	RescheduleWithdrawn = 3 // 已撤回
+	RescheduleExpired   = 4 // 已失效（新服务时间已过仍未处理）
+)
+
+// RescheduleService 改约服务
+type RescheduleService struct{}
+
+// rescheduleOrderStatuses 允许改约的订单状态（服务开始前）
+var rescheduleOrderStatuses = []statemachine.OrderStatus{statemachine.OrderPendingApprove, statemachine.OrderPendingService}
+
+// ProposeReschedule 订单任一方发起改约（新服务时间，可选新医院地址），返回申请ID
+func (r *RescheduleService) ProposeReschedule(orderId uint64, userId uint64, newServiceTimeStr string, newHospitalAddr string, reason string) (uint64, error) {
+	// 1. 解析并校验新服务时间
+	newServiceTime, err := time.ParseInLocation("2006-01-02 15:04:05", newServiceTimeStr, time.Local)
+	if err != nil {
+		return 0, errors.New("服务时间格式错误，请传入：2006-01-02 15:04:05")
+	}
+	if newServiceTime.Before(time.Now()) {
+		return 0, errors.New("服务时间不能早于当前时间")
+	}
+
+	// 开启事务（锁定订单，保证同一订单同时只有一条待处理的改约申请）
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return 0, err
+	}
+
+	// 2. 查询并锁定订单：当前用户参与的、服务开始前的订单
+	var order model.Order
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").
+		Where("id = ? AND (patient_id = ? OR companion_id = ?) AND status IN (?)", orderId, userId, userId, rescheduleOrderStatuses).First(&order).Error; err != nil {
+		tx.Rollback()
+		if gorm.IsRecordNotFoundError(err) {
+			return 0, errors.New("订单不存在或当前状态不可改约")
+		}
+		return 0, errors.New("查询订单失败")
+	}
+
+	// 3. 同一订单同时只能有一条待处理的改约申请（新服务时间已过的申请先置为已失效）
+	if err := expireStaleReschedules(tx, orderId); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	var pendingCount int64
+	if err := tx.Model(&model.RescheduleRequest{}).Where("order_id = ? AND status = ?", orderId, ReschedulePending).Count(&pendingCount).Error; err != nil {
+		tx.Rollback()
+		return 0, errors.New("查询改约申请失败")
+	}
+	if pendingCount > 0 {
+		tx.Rollback()
+		return 0, errors.New("该订单已有待处理的改约申请，请等待对方处理")
+	}
+
+	// 4. 查询需求（记录原服务时间与地址）
+	var demand model.Demand
+	if err := tx.Where("id = ?", order.DemandId).First(&demand).Error; err != nil {
+		tx.Rollback()
+		return 0, errors.New("查询需求失败")
+	}
+	if newServiceTime.Equal(demand.ServiceTime) && (newHospitalAddr == "" || newHospitalAddr == demand.HospitalAddr) {
+		tx.Rollback()
+		return 0, errors.New("新服务时间与地址均未变化")
+	}
+
+	// 5. 创建改约申请
+	proposerRole, counterpartId := statemachine.RolePatient, order.CompanionId
+	if order.CompanionId == userId {
+		proposerRole, counterpartId = statemachine.RoleCompanion, order.PatientId
+	}
+	request := model.RescheduleRequest{
+		OrderId:         orderId,
+		ProposerId:      userId,
+		ProposerRole:    int(proposerRole),
+		OldServiceTime:  demand.ServiceTime,
+		NewServiceTime:  newServiceTime,
+		OldHospitalAddr: demand.HospitalAddr,
+		NewHospitalAddr: newHospitalAddr,
+		Reason:          reason,
+		Status:          ReschedulePending,
+	}
+	if err := tx.Create(&request).Error; err != nil {
+		tx.Rollback()
+		return 0, errors.New("提交改约申请失败")
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return 0, errors.New("改约申请事务提交失败")
+	}
+
+	// 6. 通知对方处理
+	notify(counterpartId, NoticeRescheduleProposed, proposerRole.String()+"申请改约",
+		"订单"+order.OrderNo+"的"+proposerRole.String()+"申请将服务时间改为"+utils.FormatTime(newServiceTime)+"，原因："+reason+"，请及时处理。", request.ID)
+
+	return request.ID, nil
+}
+
+// RespondReschedule 对方处理改约申请（同意时在同一事务内更新需求与订单，并记录改约事件）
+func (r *RescheduleService) RespondReschedule(requestId uint64, userId uint64, accept bool, respondReason string, clientIP string) error {
+	// 开启事务
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	// 1. 查询待处理的改约申请
+	var request model.RescheduleRequest
+	if err := tx.Where("id = ? AND status = ?", requestId, ReschedulePending).First(&request).Error; err != nil {
+		tx.Rollback()
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("改约申请不存在或已处理")
+		}
+		return errors.New("查询改约申请失败")
+	}
+
+	// 2. 查询并锁定订单：处理人必须是订单的另一方（锁定后取消、签到、争议等流转须等待改约处理完成）
+	var order model.Order
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ? AND (patient_id = ? OR companion_id = ?)", request.OrderId, userId, userId).First(&order).Error; err != nil {
+		tx.Rollback()
+		if gorm.IsRecordNotFoundError(err) {
+			return errors.New("改约申请不存在或已处理")
+		}
+		return errors.New("查询订单失败")
+	}
+	if request.ProposerId == userId {
+		tx.Rollback()
+		return errors.New("不能处理自己发起的改约申请")
+	}
+
+	// 3. 更新申请状态（以待处理为条件，避免重复处理；新服务时间已过的申请不可同意，置为已失效）
+	status := RescheduleRejected
+	if accept {
+		status = RescheduleAccepted
+	}
+	stale := accept && !request.NewServiceTime.After(time.Now())
+	if stale {
+		status = RescheduleExpired
+	}
+	result := tx.Model(&model.RescheduleRequest{}).Where("id = ? AND status = ?", requestId, ReschedulePending).Updates(map[string]interface{}{
+		"status":         status,
+		"responder_id":   userId,
+		"respond_reason": respondReason,
+		"respond_at":     time.Now(),
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		return errors.New("更新改约申请失败")
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("改约申请不存在或已处理")
+	}
+	if stale {
+		if err := tx.Commit().Error; err != nil {
+			tx.Rollback()
+			return errors.New("处理改约事务提交失败")
+		}
+		return errors.New("改约申请的新服务时间已过，申请已失效，请重新发起改约")
+	}
+
+	// 4. 同意改约：订单须仍在服务开始前，更新需求的服务时间与地址，并记录改约事件
+	if accept {
+		orderStatus := statemachine.OrderStatus(order.Status)
+		if orderStatus != statemachine.OrderPendingApprove && orderStatus != statemachine.OrderPendingService {
+			tx.Rollback()
+			return errors.New("订单当前状态不可改约")
+		}
+
+		demandUpdates := map[string]interface{}{"service_time": request.NewServiceTime}
+		if request.NewHospitalAddr != "" {
+			demandUpdates["hospital_addr"] = request.NewHospitalAddr
+		}
+		if err := tx.Model(&model.Demand{}).Where("id = ?", order.DemandId).Updates(demandUpdates).Error; err != nil {
+			tx.Rollback()
+			return errors.New("更新需求服务时间失败")
+		}
+		if err := tx.Model(&model.Order{}).Where("id = ?", order.ID).Update("updated_at", time.Now()).Error; err != nil {
+			tx.Rollback()
+			return errors.New("更新订单失败")
+		}
+
+		role := statemachine.RolePatient
+		if order.CompanionId == userId {
+			role = statemachine.RoleCompanion
+		}
+		actor := statemachine.Actor{Id: userId, Role: role, ClientIP: clientIP}
+		reason := "服务时间由" + utils.FormatTime(request.OldServiceTime) + "改为" + utils.FormatTime(request.NewServiceTime)
+		if request.NewHospitalAddr != "" && request.NewHospitalAddr != request.OldHospitalAddr {
+			reason += "，医院地址改为" + request.NewHospitalAddr
+		}
+		if err := statemachine.RecordEvent(tx, order.ID, statemachine.EventReschedule, orderStatus, orderStatus, actor, reason); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return errors.New("处理改约事务提交失败")
+	}
+
+	// 通知发起人
+	resultText := "已被对方拒绝，原因：" + respondReason
+	if accept {
+		resultText = "已被对方同意，新服务时间：" + utils.FormatTime(request.NewServiceTime)
+	}
+	notify(request.ProposerId, NoticeRescheduleAnswered, "改约申请已处理", "订单"+order.OrderNo+"的改约申请"+resultText, request.ID)
+
+	return nil
+}
+
+// expireStaleReschedules 将订单中新服务时间已过仍待处理的改约申请置为已失效
+func expireStaleReschedules(tx *gorm.DB, orderId uint64) error {
+	if err := tx.Model(&model.RescheduleRequest{}).Where("order_id = ? AND status = ? AND new_service_time <= ?", orderId, ReschedulePending, time.Now()).
+		Update("status", RescheduleExpired).Error; err != nil {
+		return errors.New("更新改约申请失败")
+	}
+	return nil
+}
+
+// WithdrawReschedule 发起人撤回待处理的改约申请
+func (r *RescheduleService) WithdrawReschedule(requestId uint64, userId uint64) error {
+	result := model.DB.Model(&model.RescheduleRequest{}).
+		Where("id = ? AND proposer_id = ? AND status = ?", requestId, userId, ReschedulePending).
+		Update("status", RescheduleWithdrawn)
+	if result.Error != nil {
+		return errors.New("撤回改约申请失败")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("改约申请不存在或已处理")
+	}
+	return nil
+}
+
+// GetOrderRescheduleList 查询订单的改约记录（仅订单参与方可查看）
+func (r *RescheduleService) GetOrderRescheduleList(orderId uint64, userId uint64) ([]model.RescheduleRequest, error) {
+	// 1. 校验订单归属
+	var order model.Order
+	if err := model.DB.Where("id = ? AND (patient_id = ? OR companion_id = ?)", orderId, userId, userId).First(&order).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, statemachine.ErrNotParticipant
+		}
+		return nil, errors.New("查询订单失败")
+	}
+
+	// 2. 查询改约记录
+	var requestList []model.RescheduleRequest
+	if err := model.DB.Where("order_id = ?", orderId).Order("created_at DESC").Find(&requestList).Error; err != nil {
+		return nil, errors.New("查询改约记录失败")
+	}
+
+	return requestList, nil
+}
@@ -0,0 +1,583 @@
+// service/statement.go
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// StatementService 陪诊师月度收入对账单服务
+// 对账单完全由账本派生（账户余额 = 可用余额 + 冻结余额），仅可生成已结束的月份，生成时保存签发记录，校验码可用于事后核验
+type StatementService struct{}
+
+// StatementOrder 对账单中的订单收入（订单结算或争议裁决结算）
+type StatementOrder struct {
+	EntryNo     string      `json:"entry_no"`     // 结算凭证编号
+	OrderNo     string      `json:"order_no"`     // 订单编号
+	SettledAt   time.Time   `json:"settled_at"`   // 结算时间
+	OrderAmount utils.Money `json:"order_amount"` // 订单金额
+	Commission  utils.Money `json:"commission"`   // 平台扣除的佣金（争议裁决时为平台保留部分）
+	Income      utils.Money `json:"income"`       // 陪诊师收入
+}
+
+// StatementWithdrawal 对账单中的提现变动（提现申请扣款或提现失败退回）
+type StatementWithdrawal struct {
+	EntryNo  string      `json:"entry_no"`  // 凭证编号
+	SerialNo string      `json:"serial_no"` // 提现编号
+	Time     time.Time   `json:"time"`      // 发生时间
+	Type     int         `json:"type"`      // 变动类型（4-提现申请，3-提现失败退回）
+	Amount   utils.Money `json:"amount"`    // 余额变动金额（申请为负，退回为正）
+	Fee      utils.Money `json:"fee"`       // 手续费（包含在提现金额中，失败退回时随本金退回）
+}
+
+// StatementTip 对账单中的患者打赏（与服务收入分开列示）
+type StatementTip struct {
+	EntryNo string      `json:"entry_no"` // 凭证编号
+	TipNo   string      `json:"tip_no"`   // 打赏编号
+	OrderNo string      `json:"order_no"` // 订单编号
+	Time    time.Time   `json:"time"`     // 到账时间
+	Amount  utils.Money `json:"amount"`   // 打赏金额
+}
+
+// StatementItem 对账单中的其他余额变动（取消补偿、取消违约金、期初余额等）
+type StatementItem struct {
+	EntryNo string      `json:"entry_no"` // 凭证编号
+	Time    time.Time   `json:"time"`     // 发生时间
+	Type    int         `json:"type"`     // 明细类型（取值同余额明细类型）
+	Amount  utils.Money `json:"amount"`   // 余额变动金额
+	Remark  string      `json:"remark"`   // 摘要
+}
+
+// EarningsStatement 陪诊师月度收入对账单
+// 期末余额 = 期初余额 + 服务收入合计 + 打赏合计 - 提现合计 + 其他变动合计
+type EarningsStatement struct {
+	CompanionId      uint64                `json:"companion_id"`
+	CompanionName    string                `json:"companion_name"`     // 陪诊师昵称（不参与校验码计算）
+	Month            string                `json:"month"`              // 对账月份（格式：2006-01）
+	PeriodStart      time.Time             `json:"period_start"`       // 对账期间开始（含）
+	PeriodEnd        time.Time             `json:"period_end"`         // 对账期间结束（不含）
+	OpeningBalance   utils.Money           `json:"opening_balance"`    // 期初余额
+	ClosingBalance   utils.Money           `json:"closing_balance"`    // 期末余额
+	TotalOrderAmount utils.Money           `json:"total_order_amount"` // 订单金额合计
+	TotalCommission  utils.Money           `json:"total_commission"`   // 平台佣金合计
+	TotalIncome      utils.Money           `json:"total_income"`       // 服务收入合计
+	TotalTips        utils.Money           `json:"total_tips"`         // 打赏合计
+	TotalWithdrawn   utils.Money           `json:"total_withdrawn"`    // 提现合计（扣除失败退回后的净额）
+	TotalWithdrawFee utils.Money           `json:"total_withdraw_fee"` // 提现手续费合计（包含在提现合计中）
+	TotalOther       utils.Money           `json:"total_other"`        // 其他变动合计
+	Orders           []StatementOrder      `json:"orders"`
+	Tips             []StatementTip        `json:"tips"`
+	Withdrawals      []StatementWithdrawal `json:"withdrawals"`
+	Others           []StatementItem       `json:"others"`
+	GeneratedAt      time.Time             `json:"generated_at"`     // 生成时间（不参与校验码计算）
+	ChecksumVersion  int                   `json:"checksum_version"` // 校验码格式版本
+	Checksum         string                `json:"checksum"`         // 校验码（HMAC-SHA256，见 statementChecksum）
+}
+
+// 对账单校验码格式版本（已签发的对账单按原版本核验，新增内容只能通过新版本引入）
+const (
+	StatementChecksumV1 = 1 // 初始格式：汇总行 + 订单/提现/其他明细
+	StatementChecksumV2 = 2 // 汇总行增加打赏合计，并增加打赏明细（仅用于有打赏的月份）
+)
+
+// statementPosting 对账期间陪诊师账户的分录行
+type statementPosting struct {
+	EntryId    uint64
+	EntryNo    string
+	BizType    string
+	BizNo      string
+	Remark     string
+	RecordType int
+	Amount     utils.Money
+	CreatedAt  time.Time
+}
+
+// statementEntry 按凭证汇总后的余额变动（可用与冻结之间的划转汇总后为0，不计入对账单）
+type statementEntry struct {
+	statementPosting
+	Net utils.Money
+}
+
+// statementAccountTypes 计入对账单余额的陪诊师账户
+var statementAccountTypes = []string{AccountCompanionAvailable, AccountCompanionFrozen}
+
+// GetCompanionStatement 生成陪诊师指定月份的收入对账单（month 格式：2006-01，仅限已结束的月份）
+func (s *StatementService) GetCompanionStatement(companionId uint64, month string) (*EarningsStatement, error) {
+	// 1. 校验对账月份
+	start, err := time.ParseInLocation("2006-01", month, time.Local)
+	if err != nil {
+		return nil, errors.New("对账月份格式错误，应为：2006-01")
+	}
+	end := start.AddDate(0, 1, 0)
+	if end.After(time.Now()) {
+		return nil, errors.New("仅可生成已结束月份的对账单")
+	}
+
+	// 2. 查询陪诊师信息
+	var companion model.User
+	if err := model.DB.Where("id = ? AND user_type = ?", companionId, 2).First(&companion).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("非陪诊师账户，无对账单")
+		}
+		return nil, errors.New("查询用户信息失败")
+	}
+
+	statement := &EarningsStatement{
+		CompanionId:   companionId,
+		CompanionName: companion.Nickname,
+		Month:         month,
+		PeriodStart:   start,
+		PeriodEnd:     end,
+		Orders:        []StatementOrder{},
+		Tips:          []StatementTip{},
+		Withdrawals:   []StatementWithdrawal{},
+		Others:        []StatementItem{},
+		GeneratedAt:   time.Now(),
+	}
+
+	// 3. 查询陪诊师账户（尚未开立账户时无任何变动）
+	var accountIds []uint64
+	if err := model.DB.Model(&model.LedgerAccount{}).Where("account_type IN (?) AND owner_id = ?", statementAccountTypes, companionId).
+		Pluck("id", &accountIds).Error; err != nil {
+		return nil, errors.New("查询账本账户失败")
+	}
+	if len(accountIds) > 0 {
+		if err := s.fillStatement(statement, accountIds); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. 计算校验码（无打赏的月份沿用 v1 格式，与打赏上线前签发的对账单保持一致）
+	statement.ChecksumVersion = StatementChecksumV1
+	if len(statement.Tips) > 0 || statement.TotalTips != 0 {
+		statement.ChecksumVersion = StatementChecksumV2
+	}
+	statement.Checksum = statementChecksum(statement)
+
+	// 5. 保存签发记录（同一份对账单重复生成时沿用首次生成时间）
+	if err := issueStatement(statement); err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// VerifyStatement 核验对账单校验码（仅与签发记录比对，不生成对账单），校验通过时返回签发时的对账单
+// 公开接口调用，任何不通过的情形（陪诊师或月份不存在、未签发、校验码不符）均只返回校验不通过
+// 签发记录上线前生成的对账单没有记录，需由陪诊师或管理员重新生成一次后方可核验
+func (s *StatementService) VerifyStatement(companionId uint64, month string, checksum string) (bool, *EarningsStatement, error) {
+	// 1. 查询签发记录
+	var issued model.IssuedStatement
+	if err := model.DB.Where("companion_id = ? AND month = ? AND checksum = ?", companionId, month, checksum).First(&issued).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return false, nil, nil
+		}
+		return false, nil, errors.New("查询对账单签发记录失败")
+	}
+
+	// 2. 按签发时的格式版本重算保存内容的校验码（防止记录被篡改）
+	var statement EarningsStatement
+	if err := json.Unmarshal([]byte(issued.Content), &statement); err != nil {
+		log.Printf("对账单签发记录%d内容解析失败：%s", issued.ID, err)
+		return false, nil, nil
+	}
+	if statement.CompanionId != issued.CompanionId || statement.Month != issued.Month || statement.ChecksumVersion != issued.ChecksumVersion ||
+		!hmac.Equal([]byte(statementChecksum(&statement)), []byte(issued.Checksum)) {
+		log.Printf("对账单签发记录%d内容与校验码不符", issued.ID)
+		return false, nil, nil
+	}
+	return true, &statement, nil
+}
+
+// issueStatement 保存对账单签发记录（陪诊师、月份、校验码相同的对账单只保存一次）
+func issueStatement(statement *EarningsStatement) error {
+	// 1. 已签发：沿用首次生成时间，重复生成的对账单与首次签发的完全一致
+	var issued model.IssuedStatement
+	err := model.DB.Where("companion_id = ? AND month = ? AND checksum = ?", statement.CompanionId, statement.Month, statement.Checksum).First(&issued).Error
+	if err == nil {
+		statement.GeneratedAt = issued.IssuedAt
+		return nil
+	}
+	if !gorm.IsRecordNotFoundError(err) {
+		return errors.New("查询对账单签发记录失败")
+	}
+
+	// 2. 首次签发：保存汇总数据与完整内容
+	content, err := json.Marshal(statement)
+	if err != nil {
+		return errors.New("保存对账单签发记录失败")
+	}
+	issued = model.IssuedStatement{
+		CompanionId:     statement.CompanionId,
+		Month:           statement.Month,
+		Checksum:        statement.Checksum,
+		ChecksumVersion: statement.ChecksumVersion,
+		PeriodStart:     statement.PeriodStart,
+		PeriodEnd:       statement.PeriodEnd,
+		OpeningBalance:  statement.OpeningBalance,
+		ClosingBalance:  statement.ClosingBalance,
+		TotalIncome:     statement.TotalIncome,
+		TotalTips:       statement.TotalTips,
+		TotalWithdrawn:  statement.TotalWithdrawn,
+		TotalOther:      statement.TotalOther,
+		Content:         string(content),
+		IssuedAt:        statement.GeneratedAt,
+	}
+	if err := model.DB.Create(&issued).Error; err != nil {
+		// 并发生成同一份对账单时唯一索引冲突，以先保存的记录为准
+		if model.DB.Where("companion_id = ? AND month = ? AND checksum = ?", statement.CompanionId, statement.Month, statement.Checksum).First(&issued).Error == nil {
+			statement.GeneratedAt = issued.IssuedAt
+			return nil
+		}
+		return errors.New("保存对账单签发记录失败")
+	}
+	return nil
+}
+
+// fillStatement 按账本填充期初/期末余额与期间各项变动
+func (s *StatementService) fillStatement(statement *EarningsStatement, accountIds []uint64) error {
+	// 1. 期初、期末余额（截至对应时点的分录行金额之和）
+	opening, err := sumPostingsBefore(accountIds, statement.PeriodStart)
+	if err != nil {
+		return err
+	}
+	closing, err := sumPostingsBefore(accountIds, statement.PeriodEnd)
+	if err != nil {
+		return err
+	}
+	statement.OpeningBalance, statement.ClosingBalance = opening, closing
+
+	// 2. 查询期间分录行并按凭证汇总
+	var postings []statementPosting
+	if err := model.DB.Table("ledger_postings p").
+		Joins("JOIN ledger_entries e ON e.id = p.entry_id").
+		Where("p.account_id IN (?) AND p.created_at >= ? AND p.created_at < ?", accountIds, statement.PeriodStart, statement.PeriodEnd).
+		Select("p.entry_id, e.entry_no, e.biz_type, e.biz_no, e.remark, p.record_type, p.amount, p.created_at").
+		Order("p.id ASC").Scan(&postings).Error; err != nil {
+		return errors.New("查询账本明细失败")
+	}
+	entries := []*statementEntry{}
+	entryMap := map[uint64]*statementEntry{}
+	for _, p := range postings {
+		entry, ok := entryMap[p.EntryId]
+		if !ok {
+			entry = &statementEntry{statementPosting: p}
+			entryMap[p.EntryId] = entry
+			entries = append(entries, entry)
+		}
+		entry.Net += p.Amount
+	}
+
+	// 3. 查询订单收入对应的佣金与订单金额、打赏对应的订单、提现对应的手续费
+	var incomeEntryIds []uint64
+	var orderNos, tipNos, serialNos []string
+	for _, entry := range entries {
+		switch entry.RecordType {
+		case RecordIncome:
+			incomeEntryIds = append(incomeEntryIds, entry.EntryId)
+			orderNos = append(orderNos, entry.BizNo)
+		case RecordTip:
+			tipNos = append(tipNos, entry.BizNo)
+		case RecordWithdrawing, RecordWithdrawFail:
+			serialNos = append(serialNos, entry.BizNo)
+		}
+	}
+	commissions, err := entryCommissions(incomeEntryIds)
+	if err != nil {
+		return err
+	}
+	orderAmounts := map[string]utils.Money{}
+	if len(orderNos) > 0 {
+		var orderList []model.Order
+		if err := model.DB.Where("order_no IN (?)", orderNos).Find(&orderList).Error; err != nil {
+			return errors.New("查询订单失败")
+		}
+		for _, order := range orderList {
+			orderAmounts[order.OrderNo] = order.OrderAmount
+		}
+	}
+	tipOrderNos := map[string]string{}
+	if len(tipNos) > 0 {
+		var tipList []model.Tip
+		if err := model.DB.Where("tip_no IN (?)", tipNos).Find(&tipList).Error; err != nil {
+			return errors.New("查询打赏记录失败")
+		}
+		for _, tip := range tipList {
+			tipOrderNos[tip.TipNo] = tip.OrderNo
+		}
+	}
+	fees := map[string]utils.Money{}
+	if len(serialNos) > 0 {
+		var withdrawalList []model.Withdrawal
+		if err := model.DB.Where("serial_no IN (?)", serialNos).Find(&withdrawalList).Error; err != nil {
+			return errors.New("查询提现单失败")
+		}
+		for _, withdrawal := range withdrawalList {
+			fees[withdrawal.SerialNo] = withdrawal.Fee
+		}
+	}
+
+	// 4. 分类汇总
+	for _, entry := range entries {
+		if entry.Net == 0 {
+			continue
+		}
+		switch entry.RecordType {
+		case RecordIncome:
+			line := StatementOrder{
+				EntryNo:     entry.EntryNo,
+				OrderNo:     entry.BizNo,
+				SettledAt:   entry.CreatedAt,
+				OrderAmount: orderAmounts[entry.BizNo],
+				Commission:  commissions[entry.EntryId],
+				Income:      entry.Net,
+			}
+			statement.Orders = append(statement.Orders, line)
+			statement.TotalOrderAmount += line.OrderAmount
+			statement.TotalCommission += line.Commission
+			statement.TotalIncome += line.Income
+		case RecordTip:
+			statement.Tips = append(statement.Tips, StatementTip{
+				EntryNo: entry.EntryNo,
+				TipNo:   entry.BizNo,
+				OrderNo: tipOrderNos[entry.BizNo],
+				Time:    entry.CreatedAt,
+				Amount:  entry.Net,
+			})
+			statement.TotalTips += entry.Net
+		case RecordWithdrawing, RecordWithdrawFail:
+			line := StatementWithdrawal{
+				EntryNo:  entry.EntryNo,
+				SerialNo: entry.BizNo,
+				Time:     entry.CreatedAt,
+				Type:     entry.RecordType,
+				Amount:   entry.Net,
+				Fee:      fees[entry.BizNo],
+			}
+			statement.Withdrawals = append(statement.Withdrawals, line)
+			statement.TotalWithdrawn -= line.Amount
+			if line.Type == RecordWithdrawFail {
+				statement.TotalWithdrawFee -= line.Fee
+			} else {
+				statement.TotalWithdrawFee += line.Fee
+			}
+		default:
+			statement.Others = append(statement.Others, StatementItem{
+				EntryNo: entry.EntryNo,
+				Time:    entry.CreatedAt,
+				Type:    entry.RecordType,
+				Amount:  entry.Net,
+				Remark:  entry.Remark,
+			})
+			statement.TotalOther += entry.Net
+		}
+	}
+	return nil
+}
+
+// sumPostingsBefore 账户在指定时点之前的分录行金额之和（即该时点的余额）
+func sumPostingsBefore(accountIds []uint64, before time.Time) (utils.Money, error) {
+	var result struct {
+		Total utils.Money
+	}
+	if err := model.DB.Table("ledger_postings").Where("account_id IN (?) AND created_at < ?", accountIds, before).
+		Select("COALESCE(SUM(amount), 0) AS total").Scan(&result).Error; err != nil {
+		return 0, errors.New("查询账户余额失败")
+	}
+	return result.Total, nil
+}
+
+// entryCommissions 结算凭证中计入平台收入的金额（即该笔订单的平台佣金）
+func entryCommissions(entryIds []uint64) (map[uint64]utils.Money, error) {
+	commissions := map[uint64]utils.Money{}
+	if len(entryIds) == 0 {
+		return commissions, nil
+	}
+
+	var rows []struct {
+		EntryId uint64
+		Amount  utils.Money
+	}
+	if err := model.DB.Table("ledger_postings p").
+		Joins("JOIN ledger_accounts a ON a.id = p.account_id").
+		Where("p.entry_id IN (?) AND a.account_type = ?", entryIds, AccountPlatformRevenue).
+		Select("p.entry_id, SUM(p.amount) AS amount").Group("p.entry_id").Scan(&rows).Error; err != nil {
+		return nil, errors.New("查询平台佣金失败")
+	}
+	for _, row := range rows {
+		commissions[row.EntryId] = row.Amount
+	}
+	return commissions, nil
+}
+
+// statementChecksum 对账单校验码：以密钥对对账单内容的规范文本计算 HMAC-SHA256
+// 规范文本仅包含账本派生的内容（不含昵称与生成时间），同一陪诊师同一月份的对账单任何时候生成结果一致
+// 规范文本的布局由 ChecksumVersion 决定，已发布的版本不得修改
+func statementChecksum(statement *EarningsStatement) string {
+	var buf bytes.Buffer
+	if statement.ChecksumVersion >= StatementChecksumV2 {
+		fmt.Fprintf(&buf, "%d|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s\n", statement.CompanionId, statement.Month,
+			statement.OpeningBalance, statement.ClosingBalance, statement.TotalOrderAmount, statement.TotalCommission,
+			statement.TotalIncome, statement.TotalTips, statement.TotalWithdrawn, statement.TotalWithdrawFee, statement.TotalOther)
+	} else {
+		fmt.Fprintf(&buf, "%d|%s|%s|%s|%s|%s|%s|%s|%s|%s\n", statement.CompanionId, statement.Month,
+			statement.OpeningBalance, statement.ClosingBalance, statement.TotalOrderAmount, statement.TotalCommission,
+			statement.TotalIncome, statement.TotalWithdrawn, statement.TotalWithdrawFee, statement.TotalOther)
+	}
+	for _, line := range statement.Orders {
+		fmt.Fprintf(&buf, "O|%s|%s|%d|%s|%s|%s\n", line.EntryNo, line.OrderNo, line.SettledAt.Unix(), line.OrderAmount, line.Commission, line.Income)
+	}
+	if statement.ChecksumVersion >= StatementChecksumV2 {
+		for _, line := range statement.Tips {
+			fmt.Fprintf(&buf, "T|%s|%s|%s|%d|%s\n", line.EntryNo, line.TipNo, line.OrderNo, line.Time.Unix(), line.Amount)
+		}
+	}
+	for _, line := range statement.Withdrawals {
+		fmt.Fprintf(&buf, "W|%s|%s|%d|%d|%s|%s\n", line.EntryNo, line.SerialNo, line.Time.Unix(), line.Type, line.Amount, line.Fee)
+	}
+	for _, line := range statement.Others {
+		fmt.Fprintf(&buf, "X|%s|%d|%d|%s\n", line.EntryNo, line.Time.Unix(), line.Type, line.Amount)
+	}
+
+	h := hmac.New(sha256.New, []byte(conf.AppConfig.Statement.Secret))
+	h.Write(buf.Bytes())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// statementRecordTypeNames 对账单中展示的明细类型名称
+var statementRecordTypeNames = map[int]string{
+	RecordIncome:             "服务收入",
+	RecordWithdrawFail:       "提现失败退回",
+	RecordWithdrawing:        "提现申请",
+	RecordCancelPenalty:      "取消违约金",
+	RecordCancelCompensation: "取消补偿",
+	RecordRefund:             "退款",
+	RecordOpening:            "期初余额",
+	RecordTip:                "打赏",
+	RecordAdjustment:         "余额调整",
+}
+
+// RenderStatementCSV 导出对账单为 CSV（UTF-8 带 BOM，便于 Excel 直接打开）
+func RenderStatementCSV(statement *EarningsStatement) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(&buf)
+
+	rows := [][]string{
+		{"陪诊师月度收入对账单"},
+		{"陪诊师ID", strconv.FormatUint(statement.CompanionId, 10)},
+		{"陪诊师", statement.CompanionName},
+		{"对账月份", statement.Month},
+		{"期初余额", statement.OpeningBalance.String()},
+		{"订单金额合计", statement.TotalOrderAmount.String()},
+		{"平台佣金合计", statement.TotalCommission.String()},
+		{"服务收入合计", statement.TotalIncome.String()},
+		{"打赏合计", statement.TotalTips.String()},
+		{"提现合计", statement.TotalWithdrawn.String()},
+		{"其中提现手续费", statement.TotalWithdrawFee.String()},
+		{"其他变动合计", statement.TotalOther.String()},
+		{"期末余额", statement.ClosingBalance.String()},
+		{},
+		{"订单收入"},
+		{"结算时间", "订单编号", "订单金额", "平台佣金", "服务收入"},
+	}
+	for _, line := range statement.Orders {
+		rows = append(rows, []string{utils.FormatTime(line.SettledAt), line.OrderNo, line.OrderAmount.String(), line.Commission.String(), line.Income.String()})
+	}
+	rows = append(rows, []string{}, []string{"打赏"}, []string{"到账时间", "打赏编号", "订单编号", "金额"})
+	for _, line := range statement.Tips {
+		rows = append(rows, []string{utils.FormatTime(line.Time), line.TipNo, line.OrderNo, line.Amount.String()})
+	}
+	rows = append(rows, []string{}, []string{"提现"}, []string{"时间", "提现编号", "类型", "金额", "手续费"})
+	for _, line := range statement.Withdrawals {
+		rows = append(rows, []string{utils.FormatTime(line.Time), line.SerialNo, statementRecordTypeNames[line.Type], line.Amount.String(), line.Fee.String()})
+	}
+	rows = append(rows, []string{}, []string{"其他变动"}, []string{"时间", "凭证编号", "类型", "金额", "摘要"})
+	for _, line := range statement.Others {
+		rows = append(rows, []string{utils.FormatTime(line.Time), line.EntryNo, statementRecordTypeNames[line.Type], line.Amount.String(), line.Remark})
+	}
+	rows = append(rows, []string{}, []string{"生成时间", utils.FormatTime(statement.GeneratedAt)}, []string{"校验码", statement.Checksum}, []string{"校验码版本", strconv.Itoa(statement.ChecksumVersion)})
+
+	if err := w.WriteAll(rows); err != nil {
+		return nil, errors.New("生成对账单文件失败")
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderStatementPDF 导出对账单为 PDF
+func RenderStatementPDF(statement *EarningsStatement) []byte {
+	doc := utils.NewPdfDoc()
+	left := doc.Left()
+	row := func(texts ...string) {
+		cells := make([]utils.PdfCell, len(texts))
+		for i, text := range texts {
+			cells[i] = utils.PdfCell{X: left + float64(i)*100, Text: text}
+		}
+		doc.Line(9, cells...)
+	}
+	pair := func(label string, value string) {
+		doc.Line(10, utils.PdfCell{X: left, Text: label}, utils.PdfCell{X: left + 120, Text: value})
+	}
+
+	doc.Line(16, utils.PdfCell{X: left, Text: "陪诊师月度收入对账单"})
+	doc.Line(10)
+	pair("陪诊师", fmt.Sprintf("%s（ID：%d）", statement.CompanionName, statement.CompanionId))
+	pair("对账月份", statement.Month)
+	pair("期初余额", statement.OpeningBalance.String())
+	pair("订单金额合计", statement.TotalOrderAmount.String())
+	pair("平台佣金合计", statement.TotalCommission.String())
+	pair("服务收入合计", statement.TotalIncome.String())
+	pair("打赏合计", statement.TotalTips.String())
+	pair("提现合计", statement.TotalWithdrawn.String())
+	pair("其中提现手续费", statement.TotalWithdrawFee.String())
+	pair("其他变动合计", statement.TotalOther.String())
+	pair("期末余额", statement.ClosingBalance.String())
+
+	doc.Line(10)
+	doc.Line(12, utils.PdfCell{X: left, Text: "订单收入"})
+	row("结算时间", "订单编号", "订单金额", "平台佣金", "服务收入")
+	for _, line := range statement.Orders {
+		row(utils.FormatTime(line.SettledAt), line.OrderNo, line.OrderAmount.String(), line.Commission.String(), line.Income.String())
+	}
+
+	doc.Line(10)
+	doc.Line(12, utils.PdfCell{X: left, Text: "打赏"})
+	row("到账时间", "打赏编号", "订单编号", "金额")
+	for _, line := range statement.Tips {
+		row(utils.FormatTime(line.Time), line.TipNo, line.OrderNo, line.Amount.String())
+	}
+
+	doc.Line(10)
+	doc.Line(12, utils.PdfCell{X: left, Text: "提现"})
+	row("时间", "提现编号", "类型", "金额", "手续费")
+	for _, line := range statement.Withdrawals {
+		row(utils.FormatTime(line.Time), line.SerialNo, statementRecordTypeNames[line.Type], line.Amount.String(), line.Fee.String())
+	}
+
+	doc.Line(10)
+	doc.Line(12, utils.PdfCell{X: left, Text: "其他变动"})
+	row("时间", "凭证编号", "类型", "金额", "摘要")
+	for _, line := range statement.Others {
+		row(utils.FormatTime(line.Time), line.EntryNo, statementRecordTypeNames[line.Type], line.Amount.String(), line.Remark)
+	}
+
+	doc.Line(10)
+	pair("生成时间", utils.FormatTime(statement.GeneratedAt))
+	doc.Line(8, utils.PdfCell{X: left, Text: "校验码：" + statement.Checksum})
+	doc.Line(8, utils.PdfCell{X: left, Text: "校验码版本：" + strconv.Itoa(statement.ChecksumVersion)})
+	return doc.Bytes()
+}
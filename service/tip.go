@@ -0,0 +1,262 @@
+// service/tip.go
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/payment"
+	"github.com/X-Colder/companion-backend/statemachine"
+	"github.com/X-Colder/companion-backend/utils"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 打赏状态
+const (
+	TipPending = 0 // 待支付
+	TipPaid    = 1 // 已支付
+)
+
+// TipPayParams 打赏支付参数（打赏编号用于查询打赏支付结果）
+type TipPayParams struct {
+	TipNo string `json:"tip_no"` // 打赏编号
+	PayParams
+}
+
+// TipService 打赏服务（订单完成后患者向陪诊师打赏，经支付渠道付款，全额计入陪诊师可用余额）
+type TipService struct{}
+
+// CreateTip 患者为已完成订单发起打赏（每次调用生成一笔新的打赏及其支付单），返回打赏编号与支付参数
+func (t *TipService) CreateTip(orderId uint64, patientId uint64, amount utils.Money, message string) (*TipPayParams, error) {
+	// 1. 校验打赏金额
+	rule := conf.AppConfig.Tip
+	if amount <= 0 {
+		return nil, errors.New("打赏金额必须大于0")
+	}
+	if minAmount := utils.MoneyFromYuan(rule.MinAmount); minAmount > 0 && amount < minAmount {
+		return nil, errors.New("单笔打赏金额不能低于" + minAmount.String() + "元")
+	}
+	if maxAmount := utils.MoneyFromYuan(rule.MaxAmount); maxAmount > 0 && amount > maxAmount {
+		return nil, errors.New("单笔打赏金额不能超过" + maxAmount.String() + "元")
+	}
+
+	// 2. 查询订单（仅本人的已完成订单）
+	var order model.Order
+	if err := model.DB.Where("id = ? AND patient_id = ?", orderId, patientId).First(&order).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, statemachine.ErrNotParticipant
+		}
+		return nil, errors.New("查询订单失败")
+	}
+	if statemachine.OrderStatus(order.Status) != statemachine.OrderCompleted {
+		return nil, errors.New("订单当前为「" + statemachine.OrderStatus(order.Status).String() + "」状态，仅已完成的订单可以打赏")
+	}
+
+	// 3. 获取当前支付渠道
+	provider, err := payment.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. 生成打赏与支付单（同一事务）
+	tip := model.Tip{
+		TipNo:       utils.GenerateSerialNo("TIP"), // TIP-打赏前缀
+		OrderId:     order.ID,
+		OrderNo:     order.OrderNo,
+		PatientId:   patientId,
+		CompanionId: order.CompanionId,
+		Amount:      amount,
+		Message:     message,
+		PaymentNo:   utils.GenerateSerialNo("PAY"), // PAY-支付前缀
+		Status:      TipPending,
+	}
+	record := model.Payment{
+		PaymentNo: tip.PaymentNo,
+		PatientId: patientId,
+		Amount:    amount,
+		Provider:  provider.Name(),
+		Status:    PaymentPending,
+	}
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return nil, errors.New("开启事务失败")
+	}
+	if err := tx.Create(&tip).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("生成打赏记录失败")
+	}
+	record.TipId = tip.ID
+	if err := tx.Create(&record).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("生成支付单失败")
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.New("打赏事务提交失败")
+	}
+
+	// 5. 渠道下单（失败时关闭支付单）
+	result, err := provider.CreatePayment(payment.CreateRequest{
+		PaymentNo: record.PaymentNo,
+		Amount:    record.Amount,
+		Subject:   "陪诊服务打赏" + order.OrderNo,
+		NotifyUrl: conf.AppConfig.Payment.NotifyUrl + provider.Name(),
+	})
+	if err != nil {
+		model.DB.Model(&model.Payment{}).Where("id = ?", record.ID).Update("status", PaymentClosed)
+		return nil, errors.New("发起支付失败：" + err.Error())
+	}
+	if result.TradeNo != "" {
+		if err := model.DB.Model(&model.Payment{}).Where("id = ?", record.ID).Update("trade_no", result.TradeNo).Error; err != nil {
+			return nil, errors.New("更新支付单失败")
+		}
+	}
+
+	return &TipPayParams{
+		TipNo: tip.TipNo,
+		PayParams: PayParams{
+			PaymentNo: record.PaymentNo,
+			Amount:    record.Amount,
+			Provider:  record.Provider,
+			PayParams: result.PayParams,
+		},
+	}, nil
+}
+
+// SyncTipPayment 患者主动查询打赏支付结果（回调未到达时向渠道查询），返回打赏最新状态
+func (t *TipService) SyncTipPayment(tipNo string, patientId uint64) (*model.Tip, error) {
+	// 1. 查询打赏（仅本人）
+	tip, err := findPatientTip(tipNo, patientId)
+	if err != nil {
+		return nil, err
+	}
+	if tip.Status != TipPending {
+		return tip, nil
+	}
+
+	// 2. 向渠道查询支付单
+	var record model.Payment
+	if err := model.DB.Where("payment_no = ?", tip.PaymentNo).First(&record).Error; err != nil {
+		return nil, errors.New("查询支付单失败")
+	}
+	if record.Status != PaymentPending {
+		return tip, nil
+	}
+	provider, err := payment.Get(record.Provider)
+	if err != nil {
+		return nil, err
+	}
+	result, err := provider.QueryPayment(record.PaymentNo)
+	if err != nil {
+		log.Printf("查询支付单%s失败：%s", record.PaymentNo, err)
+		return tip, nil
+	}
+	if result.Status == payment.StatusPaid {
+		if err := (&PaymentService{}).confirmPaid(record.PaymentNo, result.TradeNo, result.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	// 3. 返回最新打赏
+	return findPatientTip(tipNo, patientId)
+}
+
+// GetOrderTipList 患者查询订单的打赏记录
+func (t *TipService) GetOrderTipList(orderId uint64, patientId uint64) ([]model.Tip, error) {
+	var order model.Order
+	if err := model.DB.Where("id = ? AND patient_id = ?", orderId, patientId).First(&order).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, statemachine.ErrNotParticipant
+		}
+		return nil, errors.New("查询订单失败")
+	}
+
+	tipList := []model.Tip{}
+	if err := model.DB.Where("order_id = ? AND patient_id = ?", orderId, patientId).Order("id DESC").Find(&tipList).Error; err != nil {
+		return nil, errors.New("查询打赏记录失败")
+	}
+	return tipList, nil
+}
+
+// confirmTipPaid 确认打赏支付成功：支付单与打赏置为已支付，平台资金清算 → 陪诊师可用余额（全额，同一事务）
+func (t *TipService) confirmTipPaid(record *model.Payment, tradeNo string, now time.Time) error {
+	// 1. 查询打赏
+	var tip model.Tip
+	if err := model.DB.Where("id = ?", record.TipId).First(&tip).Error; err != nil {
+		return errors.New("查询打赏记录失败")
+	}
+
+	// 2. 开启事务
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := tx.Error; err != nil {
+		return errors.New("开启事务失败")
+	}
+
+	// 3. 更新支付单（以待支付为条件，重复回调只会处理一次）
+	if err := markPayment(tx, record.ID, PaymentPaid, tradeNo, now); err != nil {
+		tx.Rollback()
+		if err == errPaymentHandled {
+			return nil
+		}
+		return err
+	}
+
+	// 4. 更新打赏状态
+	result := tx.Model(&model.Tip{}).Where("id = ? AND status = ?", tip.ID, TipPending).
+		Updates(map[string]interface{}{"status": TipPaid, "paid_at": now})
+	if result.Error != nil {
+		tx.Rollback()
+		return errors.New("更新打赏状态失败")
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil
+	}
+
+	// 5. 记账：平台资金清算 → 陪诊师可用余额（打赏不抽佣、不冻结）
+	// TIP-打赏前缀
+	if err := postEntry(tx, utils.GenerateSerialNo("TIP"), BizTip, tip.TipNo, "订单"+tip.OrderNo+"患者打赏",
+		Posting{AccountType: AccountPlatformClearing, Amount: -tip.Amount},
+		Posting{AccountType: AccountCompanionAvailable, OwnerId: tip.CompanionId, Amount: tip.Amount, RecordType: RecordTip},
+	); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 6. 提交事务
+	if err := tx.Commit().Error; err != nil {
+		return errors.New("打赏事务提交失败")
+	}
+
+	content := "订单" + tip.OrderNo + "的患者向您打赏" + tip.Amount.String() + "元，已计入可用余额。"
+	if tip.Message != "" {
+		content += "留言：" + tip.Message
+	}
+	notify(tip.CompanionId, NoticeTipReceived, "收到患者打赏", content, tip.OrderId)
+	return nil
+}
+
+// findPatientTip 按打赏编号查询患者本人的打赏
+func findPatientTip(tipNo string, patientId uint64) (*model.Tip, error) {
+	var tip model.Tip
+	if err := model.DB.Where("tip_no = ? AND patient_id = ?", tipNo, patientId).First(&tip).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, errors.New("打赏记录不存在")
+		}
+		return nil, errors.New("查询打赏记录失败")
+	}
+	return &tip, nil
+}
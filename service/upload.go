@@ -102,6 +102,23 @@ func (u *UploadService) UploadEvalImgs(fileInfos []struct {
 	return imgUrls, nil
 }
 
+// UploadDisputeImgs 批量上传争议证据图片（返回所有图片的访问路径）
+func (u *UploadService) UploadDisputeImgs(fileInfos []struct {
+	FileName   string
+	FileSize   int64
+	FileReader io.Reader
+}) ([]string, error) {
+	var imgUrls []string
+	for _, fileInfo := range fileInfos {
+		url, err := u.uploadFile(fileInfo.FileName, fileInfo.FileSize, fileInfo.FileReader, "dispute")
+		if err != nil {
+			return nil, err
+		}
+		imgUrls = append(imgUrls, url)
+	}
+	return imgUrls, nil
+}
+
 // -------------------------- 文件删除方法 --------------------------
 // DeleteFile 根据前端访问路径删除本地文件
 func (u *UploadService) DeleteFile(accessPath string) error {
@@ -0,0 +1,152 @@
+// service/withdraw_policy.go
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/X-Colder/companion-backend/conf"
+	"github.com/X-Colder/companion-backend/model"
+	"github.com/X-Colder/companion-backend/utils"
+	"github.com/jinzhu/gorm"
+)
+
+// calcWithdrawFee 计算提现手续费（固定手续费 + 比例手续费）
+func calcWithdrawFee(amount utils.Money) utils.Money {
+	rule := conf.AppConfig.Withdraw
+	return amount.MulRate(rule.FeeRate) + utils.MoneyFromYuan(rule.FeeAmount)
+}
+
+// checkWithdrawLimits 校验单笔限额及日/月累计笔数与金额（提现失败的不计入累计；调用方需已锁定陪诊师可用余额账户，保证并发申请串行校验）
+func checkWithdrawLimits(tx *gorm.DB, companionId uint64, amount utils.Money, now time.Time) error {
+	rule := conf.AppConfig.Withdraw
+
+	// 1. 单笔限额
+	if minAmount := utils.MoneyFromYuan(rule.MinAmount); minAmount > 0 && amount < minAmount {
+		return errors.New("单笔提现金额不能低于" + minAmount.String() + "元")
+	}
+	if maxAmount := utils.MoneyFromYuan(rule.MaxAmount); maxAmount > 0 && amount > maxAmount {
+		return errors.New("单笔提现金额不能超过" + maxAmount.String() + "元")
+	}
+
+	// 2. 日/月累计限额
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	periods := []struct {
+		name      string
+		since     time.Time
+		maxCount  int
+		maxAmount utils.Money
+	}{
+		{"今日", dayStart, rule.DailyCount, utils.MoneyFromYuan(rule.DailyAmount)},
+		{"本月", monthStart, rule.MonthlyCount, utils.MoneyFromYuan(rule.MonthlyAmount)},
+	}
+	for _, period := range periods {
+		if period.maxCount <= 0 && period.maxAmount <= 0 {
+			continue
+		}
+		var stat struct {
+			Count int
+			Total utils.Money
+		}
+		if err := tx.Model(&model.Withdrawal{}).
+			Select("COUNT(*) AS count, IFNULL(SUM(amount), 0) AS total").
+			Where("companion_id = ? AND status <> ? AND created_at >= ?", companionId, RecordWithdrawFail, period.since).
+			Scan(&stat).Error; err != nil {
+			return errors.New("查询累计提现记录失败")
+		}
+		if period.maxCount > 0 && stat.Count >= period.maxCount {
+			return errors.New(period.name + "提现次数已达上限（" + strconv.Itoa(period.maxCount) + "笔）")
+		}
+		if period.maxAmount > 0 && stat.Total+amount > period.maxAmount {
+			return errors.New(period.name + "累计提现金额不能超过" + period.maxAmount.String() + "元，已提现" + stat.Total.String() + "元")
+		}
+	}
+	return nil
+}
+
+// incomePosting 陪诊师服务收入的分录行：配置了冻结期时计入冻结余额并登记解冻时间（T+N 日零点），否则直接计入可用余额
+func incomePosting(tx *gorm.DB, companionId uint64, orderNo string, amount utils.Money) (Posting, error) {
+	freezeDays := conf.AppConfig.Withdraw.IncomeFreezeDays
+	if freezeDays <= 0 || amount <= 0 {
+		return Posting{AccountType: AccountCompanionAvailable, OwnerId: companionId, Amount: amount, RecordType: RecordIncome}, nil
+	}
+
+	now := time.Now()
+	freeze := model.IncomeFreeze{
+		CompanionId: companionId,
+		OrderNo:     orderNo,
+		Amount:      amount,
+		ReleaseAt:   time.Date(now.Year(), now.Month(), now.Day()+freezeDays, 0, 0, 0, 0, now.Location()),
+	}
+	if err := tx.Create(&freeze).Error; err != nil {
+		return Posting{}, errors.New("登记冻结收入失败")
+	}
+	return Posting{AccountType: AccountCompanionFrozen, OwnerId: companionId, Amount: amount, RecordType: RecordIncome}, nil
+}
+
+// ReleaseFrozenIncome 将到期的冻结收入转入陪诊师可用余额（返回本次解冻笔数）
+func (b *BalanceService) ReleaseFrozenIncome() (int, error) {
+	// 1. 查询已到期未解冻的收入
+	var freezeList []model.IncomeFreeze
+	if err := model.DB.Where("released_at IS NULL AND release_at <= ?", time.Now()).
+		Order("id ASC").Limit(100).Find(&freezeList).Error; err != nil {
+		return 0, errors.New("查询到期冻结收入失败")
+	}
+
+	// 2. 逐笔解冻（单笔失败不影响其他）
+	released := 0
+	for _, freeze := range freezeList {
+		ok, err := b.releaseIncome(freeze)
+		if err != nil {
+			log.Printf("冻结收入%d解冻失败：%s", freeze.ID, err)
+			continue
+		}
+		if ok {
+			released++
+		}
+	}
+	return released, nil
+}
+
+// releaseIncome 解冻单笔收入（以未解冻为条件更新，多实例并发执行时只会解冻一次）
+func (b *BalanceService) releaseIncome(freeze model.IncomeFreeze) (bool, error) {
+	tx := model.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return false, err
+	}
+
+	result := tx.Model(&model.IncomeFreeze{}).Where("id = ? AND released_at IS NULL", freeze.ID).Update("released_at", time.Now())
+	if result.Error != nil {
+		tx.Rollback()
+		return false, errors.New("更新冻结收入状态失败")
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	// 记账：冻结余额 → 可用余额
+	if err := postEntry(tx, utils.GenerateSerialNo("UFZ"), BizIncomeRelease, freeze.OrderNo, fmt.Sprintf("订单%s服务收入解冻", freeze.OrderNo),
+		Posting{AccountType: AccountCompanionFrozen, OwnerId: freeze.CompanionId, Amount: -freeze.Amount},
+		Posting{AccountType: AccountCompanionAvailable, OwnerId: freeze.CompanionId, Amount: freeze.Amount, RecordType: RecordIncome},
+	); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return false, errors.New("解冻收入事务提交失败")
+	}
+	return true, nil
+}
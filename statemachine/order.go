@@ -0,0 +1,249 @@
+// statemachine/order.go
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/X-Colder/companion-backend/model"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Event 订单状态流转事件
+type Event string
+
+const (
+	EventTake             Event = "take"              // 陪诊师接单，生成订单
+	EventPatientApprove   Event = "patient_approve"   // 患者确认陪诊师
+	EventPatientReject    Event = "patient_reject"    // 患者拒绝陪诊师，需求退回订单大厅
+	EventApproveTimeout   Event = "approve_timeout"   // 患者超时未确认，系统自动确认陪诊师
+	EventReleaseTimeout   Event = "release_timeout"   // 患者超时未确认，系统将需求退回订单大厅
+	EventCheckIn          Event = "check_in"          // 陪诊师到场签到，开始服务
+	EventCompanionConfirm Event = "companion_confirm" // 陪诊师确认服务完成
+	EventPatientConfirm   Event = "patient_confirm"   // 患者确认服务完成（结算）
+	EventAutoConfirm      Event = "auto_confirm"      // 患者超时未确认，系统自动确认完成（结算）
+	EventPatientCancel    Event = "patient_cancel"    // 患者取消订单
+	EventCompanionCancel  Event = "companion_cancel"  // 陪诊师取消订单
+	EventOpenDispute      Event = "open_dispute"      // 发起争议（冻结结算）
+	EventDisputePayout    Event = "dispute_payout"    // 争议裁决：全额结算给陪诊师
+	EventDisputePartial   Event = "dispute_partial"   // 争议裁决：部分结算，其余退还患者
+	EventDisputeRefund    Event = "dispute_refund"    // 争议裁决：全额退款给患者
+	EventReschedule       Event = "reschedule"        // 双方协商改约（不改变订单状态）
+	EventPaid             Event = "paid"              // 患者预付成功（订单金额进入托管）
+	EventPayTimeout       Event = "pay_timeout"       // 患者超时未支付，系统取消订单
+)
+
+// eventNames 事件中文名称（用于错误提示）
+var eventNames = map[Event]string{
+	EventTake:             "陪诊师接单",
+	EventPatientApprove:   "患者确认陪诊师",
+	EventPatientReject:    "患者拒绝陪诊师",
+	EventApproveTimeout:   "超时自动确认陪诊师",
+	EventReleaseTimeout:   "超时退回订单大厅",
+	EventCheckIn:          "签到开始服务",
+	EventCompanionConfirm: "陪诊师确认完成",
+	EventPatientConfirm:   "患者确认完成",
+	EventAutoConfirm:      "系统自动确认完成",
+	EventPatientCancel:    "患者取消订单",
+	EventCompanionCancel:  "陪诊师取消订单",
+	EventOpenDispute:      "发起争议",
+	EventDisputePayout:    "争议裁决全额结算",
+	EventDisputePartial:   "争议裁决部分结算",
+	EventDisputeRefund:    "争议裁决全额退款",
+	EventReschedule:       "改约",
+	EventPaid:             "患者支付",
+	EventPayTimeout:       "超时未支付取消",
+}
+
+// String 返回事件中文名称
+func (e Event) String() string {
+	if name, ok := eventNames[e]; ok {
+		return name
+	}
+	return string(e)
+}
+
+// Effect 状态流转的副作用（具体实现由服务层注册）
+type Effect string
+
+const (
+	EffectResetDemand     Effect = "reset_demand"     // 需求退回订单大厅（已接单 → 待接单，清空订单ID）
+	EffectConfirmDemand   Effect = "confirm_demand"   // 需求确认接单（待确认 → 已接单）
+	EffectCreditCompanion Effect = "credit_companion" // 陪诊师入账（累加余额 + 生成收入明细）
+	EffectCancelPenalty   Effect = "cancel_penalty"   // 按取消违约规则扣收违约金（已预付订单同时退回预付款）
+	EffectSettleDispute   Effect = "settle_dispute"   // 按争议裁决结果结算（陪诊师入账 / 患者原路退款）
+	EffectAwaitPayment    Effect = "await_payment"    // 设置支付截止时间，等待患者预付
+	EffectHoldEscrow      Effect = "hold_escrow"      // 预付款转入平台托管（优惠金额由平台补足）
+	EffectReleaseCoupon   Effect = "release_coupon"   // 未支付订单取消，退回锁定的优惠券
+	EffectUseCoupon       Effect = "use_coupon"       // 订单支付成功，核销锁定的优惠券
+)
+
+// EffectFunc 副作用实现（在流转所在事务内执行，返回错误则整体回滚）
+type EffectFunc func(tx *gorm.DB, order *model.Order, actor Actor) error
+
+// Transition 状态流转定义
+type Transition struct {
+	Event   Event       // 触发事件
+	From    OrderStatus // 起始状态
+	To      OrderStatus // 目标状态
+	Roles   []Role      // 允许触发的角色
+	Effects []Effect    // 流转成功后依次执行的副作用
+}
+
+// orderTransitions 订单状态流转表（新增流转只需在此追加一行并实现对应副作用）
+var orderTransitions = []Transition{
+	{Event: EventPatientApprove, From: OrderPendingApprove, To: OrderPendingPayment, Roles: []Role{RolePatient}, Effects: []Effect{EffectConfirmDemand, EffectAwaitPayment}},
+	{Event: EventPatientReject, From: OrderPendingApprove, To: OrderCancelled, Roles: []Role{RolePatient}, Effects: []Effect{EffectResetDemand, EffectReleaseCoupon}},
+	{Event: EventApproveTimeout, From: OrderPendingApprove, To: OrderPendingPayment, Roles: []Role{RoleSystem}, Effects: []Effect{EffectConfirmDemand, EffectAwaitPayment}},
+	{Event: EventReleaseTimeout, From: OrderPendingApprove, To: OrderCancelled, Roles: []Role{RoleSystem}, Effects: []Effect{EffectResetDemand, EffectReleaseCoupon}},
+	{Event: EventCompanionCancel, From: OrderPendingApprove, To: OrderCancelled, Roles: []Role{RoleCompanion}, Effects: []Effect{EffectResetDemand, EffectReleaseCoupon}},
+	{Event: EventPaid, From: OrderPendingPayment, To: OrderPendingService, Roles: []Role{RoleSystem}, Effects: []Effect{EffectHoldEscrow, EffectUseCoupon}},
+	{Event: EventPayTimeout, From: OrderPendingPayment, To: OrderCancelled, Roles: []Role{RoleSystem}, Effects: []Effect{EffectResetDemand, EffectReleaseCoupon}},
+	{Event: EventPatientCancel, From: OrderPendingPayment, To: OrderCancelled, Roles: []Role{RolePatient}, Effects: []Effect{EffectResetDemand, EffectReleaseCoupon}},
+	{Event: EventCompanionCancel, From: OrderPendingPayment, To: OrderCancelled, Roles: []Role{RoleCompanion}, Effects: []Effect{EffectResetDemand, EffectReleaseCoupon}},
+	{Event: EventCheckIn, From: OrderPendingService, To: OrderInService, Roles: []Role{RoleCompanion}},
+	{Event: EventCompanionConfirm, From: OrderInService, To: OrderPendingSettle, Roles: []Role{RoleCompanion}},
+	{Event: EventPatientConfirm, From: OrderPendingSettle, To: OrderCompleted, Roles: []Role{RolePatient}, Effects: []Effect{EffectCreditCompanion}},
+	{Event: EventAutoConfirm, From: OrderPendingSettle, To: OrderCompleted, Roles: []Role{RoleSystem}, Effects: []Effect{EffectCreditCompanion}},
+	{Event: EventPatientCancel, From: OrderPendingService, To: OrderCancelled, Roles: []Role{RolePatient}, Effects: []Effect{EffectResetDemand, EffectCancelPenalty}},
+	{Event: EventCompanionCancel, From: OrderPendingService, To: OrderCancelled, Roles: []Role{RoleCompanion}, Effects: []Effect{EffectResetDemand, EffectCancelPenalty}},
+	{Event: EventOpenDispute, From: OrderInService, To: OrderDisputed, Roles: []Role{RolePatient, RoleCompanion}},
+	{Event: EventOpenDispute, From: OrderPendingSettle, To: OrderDisputed, Roles: []Role{RolePatient, RoleCompanion}},
+	{Event: EventDisputePayout, From: OrderDisputed, To: OrderCompleted, Roles: []Role{RoleAdmin}, Effects: []Effect{EffectSettleDispute}},
+	{Event: EventDisputePartial, From: OrderDisputed, To: OrderCompleted, Roles: []Role{RoleAdmin}, Effects: []Effect{EffectSettleDispute}},
+	{Event: EventDisputeRefund, From: OrderDisputed, To: OrderCancelled, Roles: []Role{RoleAdmin}, Effects: []Effect{EffectSettleDispute}},
+}
+
+// ErrNotParticipant 操作人不是订单参与方
+var ErrNotParticipant = errors.New("订单不存在或非本人订单")
+
+// TransitionError 非法状态流转错误
+type TransitionError struct {
+	Event Event       // 触发事件
+	From  OrderStatus // 订单当前状态
+	Role  Role        // 操作角色
+	Cause string      // 拒绝原因
+}
+
+// Error 实现 error 接口
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("订单当前为「%s」状态，%s无法执行「%s」：%s", e.From, e.Role, e.Event, e.Cause)
+}
+
+// IsTransitionError 判断错误是否为非法状态流转
+func IsTransitionError(err error) bool {
+	var te *TransitionError
+	return errors.As(err, &te)
+}
+
+// OrderMachine 订单状态机
+type OrderMachine struct {
+	transitions []Transition
+	effects     map[Effect]EffectFunc
+}
+
+// NewOrderMachine 创建订单状态机（effects 为副作用实现，流转表中引用的副作用必须全部注册）
+func NewOrderMachine(effects map[Effect]EffectFunc) *OrderMachine {
+	for _, t := range orderTransitions {
+		for _, effect := range t.Effects {
+			if _, ok := effects[effect]; !ok {
+				panic("statemachine: 未注册副作用 " + string(effect))
+			}
+		}
+	}
+	return &OrderMachine{transitions: orderTransitions, effects: effects}
+}
+
+// Lookup 查找事件在指定状态、指定角色下的合法流转
+func (m *OrderMachine) Lookup(event Event, from OrderStatus, role Role) (*Transition, error) {
+	matched := false
+	for i := range m.transitions {
+		t := &m.transitions[i]
+		if t.Event != event {
+			continue
+		}
+		matched = true
+		if t.From != from {
+			continue
+		}
+		for _, r := range t.Roles {
+			if r == role {
+				return t, nil
+			}
+		}
+		return nil, &TransitionError{Event: event, From: from, Role: role, Cause: "无操作权限"}
+	}
+	if !matched {
+		return nil, &TransitionError{Event: event, From: from, Role: role, Cause: "未定义的操作"}
+	}
+	return nil, &TransitionError{Event: event, From: from, Role: role, Cause: "当前状态不允许该操作"}
+}
+
+// Check 校验操作人能否对订单触发事件（参与方校验 + 流转合法性校验），不修改任何数据
+func (m *OrderMachine) Check(order *model.Order, event Event, actor Actor) (*Transition, error) {
+	// 1. 校验操作人是否为订单参与方（系统操作不校验）
+	if (actor.Role == RolePatient && order.PatientId != actor.Id) ||
+		(actor.Role == RoleCompanion && order.CompanionId != actor.Id) {
+		return nil, ErrNotParticipant
+	}
+
+	// 2. 查找合法流转
+	return m.Lookup(event, OrderStatus(order.Status), actor.Role)
+}
+
+// Fire 在事务内执行状态流转：校验 → 更新订单状态（附带 fields 中的字段）→ 记录流转事件 → 执行副作用
+func (m *OrderMachine) Fire(tx *gorm.DB, order *model.Order, event Event, actor Actor, reason string, fields map[string]interface{}) (*Transition, error) {
+	// 1. 校验参与方与流转合法性
+	t, err := m.Check(order, event, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 更新订单状态（以当前状态为条件，避免覆盖其他流转的结果）
+	updates := map[string]interface{}{"status": int(t.To)}
+	for k, v := range fields {
+		updates[k] = v
+	}
+	result := tx.Model(&model.Order{}).Where("id = ? AND status = ?", order.ID, int(t.From)).Updates(updates)
+	if result.Error != nil {
+		return nil, errors.New("更新订单状态失败")
+	}
+	if result.RowsAffected == 0 {
+		return nil, &TransitionError{Event: event, From: t.From, Role: actor.Role, Cause: "订单状态已变更，请刷新后重试"}
+	}
+	order.Status = int(t.To)
+
+	// 3. 记录流转事件
+	if err := RecordEvent(tx, order.ID, event, t.From, t.To, actor, reason); err != nil {
+		return nil, err
+	}
+
+	// 4. 依次执行副作用
+	for _, effect := range t.Effects {
+		if err := m.effects[effect](tx, order, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// RecordEvent 记录订单流转事件（Fire 自动调用；订单创建等不经过流转表的变更需手动调用）
+func RecordEvent(tx *gorm.DB, orderId uint64, event Event, from OrderStatus, to OrderStatus, actor Actor, reason string) error {
+	orderEvent := model.OrderEvent{
+		OrderId:    orderId,
+		Event:      string(event),
+		EventName:  event.String(),
+		FromStatus: int(from),
+		ToStatus:   int(to),
+		ActorId:    actor.Id,
+		ActorRole:  int(actor.Role),
+		Reason:     reason,
+		ClientIp:   actor.ClientIP,
+	}
+	if err := tx.Create(&orderEvent).Error; err != nil {
+		return errors.New("记录订单流转事件失败")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// statemachine/status.go
+package statemachine
+
+// OrderStatus 订单状态（与 orders.status 字段取值一致）
+type OrderStatus int
+
+const (
+	OrderNone           OrderStatus = 0 // 未创建（接单前）
+	OrderPendingService OrderStatus = 1 // 待服务
+	OrderInService      OrderStatus = 2 // 服务中
+	OrderPendingSettle  OrderStatus = 3 // 待结算
+	OrderCompleted      OrderStatus = 4 // 已完成
+	OrderCancelled      OrderStatus = 5 // 已取消
+	OrderPendingApprove OrderStatus = 6 // 待确认（陪诊师已接单，等待患者确认）
+	OrderDisputed       OrderStatus = 7 // 争议中（结算冻结，等待管理员裁决）
+	OrderPendingPayment OrderStatus = 8 // 待支付（陪诊师已确定，等待患者预付订单金额）
+)
+
+// orderStatusNames 订单状态中文名称（用于错误提示）
+var orderStatusNames = map[OrderStatus]string{
+	OrderNone:           "未创建",
+	OrderPendingService: "待服务",
+	OrderInService:      "服务中",
+	OrderPendingSettle:  "待结算",
+	OrderCompleted:      "已完成",
+	OrderCancelled:      "已取消",
+	OrderPendingApprove: "待确认",
+	OrderDisputed:       "争议中",
+	OrderPendingPayment: "待支付",
+}
+
+// String 返回订单状态中文名称
+func (s OrderStatus) String() string {
+	if name, ok := orderStatusNames[s]; ok {
+		return name
+	}
+	return "未知状态"
+}
+
+// DemandStatus 需求状态（与 demands.status 字段取值一致）
+type DemandStatus int
+
+const (
+	DemandPending   DemandStatus = 0 // 待接单
+	DemandTaken     DemandStatus = 1 // 已接单
+	DemandWaiting   DemandStatus = 2 // 待服务
+	DemandServing   DemandStatus = 3 // 服务中
+	DemandFinished  DemandStatus = 4 // 已完成
+	DemandCancelled DemandStatus = 5 // 已取消
+	DemandExpired   DemandStatus = 6 // 已过期（服务时间已过仍未被接单）
+	DemandApproving DemandStatus = 7 // 待确认（陪诊师已接单，等待患者确认）
+)
+
+// Role 触发状态流转的角色（取值与 users.user_type 对齐，系统任务单独编号）
+type Role int
+
+const (
+	RolePatient   Role = 1 // 患者/家属
+	RoleCompanion Role = 2 // 陪诊师
+	RoleAdmin     Role = 3 // 平台管理员
+	RoleSystem    Role = 9 // 系统（定时任务等）
+)
+
+// roleNames 角色中文名称（用于错误提示）
+var roleNames = map[Role]string{
+	RolePatient:   "患者",
+	RoleCompanion: "陪诊师",
+	RoleAdmin:     "管理员",
+	RoleSystem:    "系统",
+}
+
+// String 返回角色中文名称
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return "未知角色"
+}
+
+// Actor 状态流转的操作人
+type Actor struct {
+	Id       uint64 // 用户ID（系统操作为0）
+	Role     Role   // 操作角色
+	ClientIP string // 客户端IP（系统操作为空）
+}
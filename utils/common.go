@@ -2,7 +2,9 @@
 package utils
 
 import (
+	crand "crypto/rand"
 	"math"
+	"math/big"
 	"math/rand"
 	"strconv"
 	"strings"
@@ -68,8 +70,19 @@ func GenerateSerialNo(prefix string) string {
 	return prefix + timeStr + strconv.Itoa(randomNum)
 }
 
-// KeepTwoDecimal 金额保留两位小数（避免浮点型计算精度问题）
-// amount：原始金额
+// GenerateCheckinCode 生成签到码（6位数字，供患者出示给陪诊师核验）
+// 签到码是一次性凭证，使用 crypto/rand 生成，不可由其他编号推测
+// 返回：签到码字符串
+func GenerateCheckinCode() string {
+	n, err := crand.Int(crand.Reader, big.NewInt(900000))
+	if err != nil {
+		panic("生成签到码失败：" + err.Error())
+	}
+	return strconv.FormatInt(n.Int64()+100000, 10) // 生成100000-999999的随机数
+}
+
+// KeepTwoDecimal 数值保留两位小数（用于评分等展示数值；金额请使用 Money）
+// amount：原始数值
 // 返回：保留两位小数后的金额
 func KeepTwoDecimal(amount float64) float64 {
 	return math.Round(amount*100) / 100
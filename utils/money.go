@@ -0,0 +1,172 @@
+// utils/money.go
+package utils
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money 金额（以分为单位的整数存储，避免浮点运算误差）
+// 舍入规则：所有换算与按比例计算均四舍五入到分（0.5 分远离零进位）
+// 数据库：对应 decimal(10,2) 字段；JSON：编码为两位小数的数字（如 12.30），兼容原浮点金额格式
+type Money int64
+
+// MoneyFromYuan 元（浮点）转金额，四舍五入到分（仅用于配置项等非精确来源）
+func MoneyFromYuan(yuan float64) Money {
+	return Money(math.Round(yuan * 100))
+}
+
+// ParseMoney 解析十进制金额字符串（如 "12.3"、"-0.05"），超过两位的小数四舍五入到分
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("金额不能为空")
+	}
+	// 科学计数法等非常规格式按浮点解析
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, errors.New("金额格式错误：" + s)
+		}
+		return MoneyFromYuan(f), nil
+	}
+
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if (intPart == "" && fracPart == "") || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, errors.New("金额格式错误：" + s)
+	}
+
+	var cents int64
+	if intPart != "" {
+		yuan, err := strconv.ParseInt(intPart, 10, 64)
+		if err != nil || yuan > math.MaxInt64/100-1 {
+			return 0, errors.New("金额超出范围：" + s)
+		}
+		cents = yuan * 100
+	}
+	fracPart += "00"
+	cents += int64(fracPart[0]-'0')*10 + int64(fracPart[1]-'0')
+	if len(fracPart) > 2 && fracPart[2] >= '5' {
+		cents++ // 第三位小数四舍五入
+	}
+	if negative {
+		cents = -cents
+	}
+	return Money(cents), nil
+}
+
+// isDigits 判断字符串是否全为数字（空串视为合法）
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Cents 返回以分为单位的整数
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// Yuan 返回以元为单位的浮点数（仅用于展示，不应参与金额计算）
+func (m Money) Yuan() float64 {
+	return float64(m) / 100
+}
+
+// String 格式化为两位小数的字符串（如 12.30、-0.05）
+func (m Money) String() string {
+	sign, cents := "", int64(m)
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MulRate 按比例计算金额（如佣金、违约金），以十进制精确计算后四舍五入到分
+func (m Money) MulRate(rate float64) Money {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(rate, 'f', -1, 64))
+	if !ok {
+		return 0
+	}
+	num := new(big.Int).Mul(big.NewInt(int64(m)), r.Num())
+	den := r.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	// |余数| * 2 >= 分母时远离零进位
+	if rem.Sign() != 0 && new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	return Money(quo.Int64())
+}
+
+// MarshalJSON 编码为两位小数的JSON数字
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON 支持JSON数字或字符串（如 12.3 或 "12.30"）
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), "\"")
+	if s == "null" || s == "" {
+		*m = 0
+		return nil
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Scan 从数据库 decimal 字段读取
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		*m = Money(v * 100)
+		return nil
+	case float64:
+		*m = MoneyFromYuan(v)
+		return nil
+	}
+	return fmt.Errorf("无法将 %T 转换为金额", value)
+}
+
+func (m *Money) scanString(s string) error {
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Value 写入数据库 decimal 字段（以两位小数字符串传递，避免浮点误差）
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
@@ -0,0 +1,114 @@
+// utils/pdf.go
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf16"
+)
+
+// PDF 页面参数（A4，单位：点）
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+// PdfCell 一行中的一段文本（X 为距页面左边缘的位置）
+type PdfCell struct {
+	X    float64
+	Text string
+}
+
+// PdfDoc 简易 PDF 文档（仅支持逐行输出文本，满页自动分页）
+// 使用 PDF 阅读器内置的 STSong-Light 中文字体（Adobe-GB1），无需嵌入字体文件
+type PdfDoc struct {
+	pages []*bytes.Buffer
+	y     float64 // 当前行基线位置（距页面下边缘）
+}
+
+// NewPdfDoc 创建空白 PDF 文档
+func NewPdfDoc() *PdfDoc {
+	d := &PdfDoc{}
+	d.newPage()
+	return d
+}
+
+// Line 输出一行文本（size：字号；cells 为空时输出空行），剩余空间不足时自动换页
+func (d *PdfDoc) Line(size float64, cells ...PdfCell) {
+	leading := size * 1.6
+	if d.y-leading < pdfMargin {
+		d.newPage()
+	}
+	d.y -= leading
+
+	page := d.pages[len(d.pages)-1]
+	for _, cell := range cells {
+		if cell.Text == "" {
+			continue
+		}
+		fmt.Fprintf(page, "BT /F1 %.1f Tf 1 0 0 1 %.2f %.2f Tm <%s> Tj ET\n", size, cell.X, d.y, pdfHexText(cell.Text))
+	}
+}
+
+// Left 页面左边距（输出文本的起始位置）
+func (d *PdfDoc) Left() float64 {
+	return pdfMargin
+}
+
+// Bytes 生成 PDF 文件内容
+func (d *PdfDoc) Bytes() []byte {
+	var buf bytes.Buffer
+	offsets := []int{}
+	writeObj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// 1-目录，2-页面树，3~5-字体，之后每页依次为页面对象与内容流
+	kids := &bytes.Buffer{}
+	for i := range d.pages {
+		fmt.Fprintf(kids, "%d 0 R ", 6+i*2)
+	}
+	writeObj("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(d.pages)))
+	writeObj("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>")
+	writeObj("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>")
+	writeObj("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	for i, page := range d.pages {
+		writeObj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 7+i*2))
+		writeObj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	// 交叉引用表
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// newPage 新起一页
+func (d *PdfDoc) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+// pdfHexText 文本转 UCS-2 大端十六进制串（UniGB-UCS2-H 编码，基本平面以外的字符以 ? 代替）
+func pdfHexText(s string) string {
+	var buf bytes.Buffer
+	for _, r := range s {
+		if r > 0xFFFF || utf16.IsSurrogate(r) {
+			r = '?'
+		}
+		fmt.Fprintf(&buf, "%04X", r)
+	}
+	return buf.String()
+}